@@ -0,0 +1,153 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProxyType is the proxy software that runs a Network
+// +kubebuilder:validation:Enum=Velocity;BungeeCord
+type ProxyType string
+
+const (
+	// ProxyVelocity runs Velocity
+	ProxyVelocity ProxyType = "Velocity"
+	// ProxyBungeeCord runs BungeeCord, or a fork of it like Waterfall
+	ProxyBungeeCord ProxyType = "BungeeCord"
+)
+
+// ForwardingMode is how the proxy passes the players' identity on to the Servers
+// +kubebuilder:validation:Enum=Modern;Legacy;None
+type ForwardingMode string
+
+const (
+	// ForwardingModern is Velocity's forwarding, signed with the forwarding secret. Paper and Fabric (with the
+	// FabricProxy-Lite mod) support it
+	ForwardingModern ForwardingMode = "Modern"
+	// ForwardingLegacy is BungeeCord's forwarding, which the Bukkit family supports
+	ForwardingLegacy ForwardingMode = "Legacy"
+	// ForwardingNone doesn't forward, the Servers see the players as offline players
+	ForwardingNone ForwardingMode = "None"
+)
+
+// NetworkSpec defines the desired state of Network
+type NetworkSpec struct {
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// Type is the proxy to run
+	// +kubebuilder:default=Velocity
+	// +optional
+	Type ProxyType `json:"type,omitempty"`
+
+	// Image is the image of the proxy. The generated config is mounted on /config, that the image should copy
+	// into the directory it runs the proxy from. Defaults to itzg/mc-proxy
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ServerSelector selects the Servers in the namespace that the proxy sends players to
+	ServerSelector metav1.LabelSelector `json:"serverSelector"`
+
+	// Forwarding is how the proxy passes the players' identity on to the Servers. Defaults to Modern for Velocity
+	// and Legacy for BungeeCord, which doesn't support Modern
+	// +optional
+	Forwarding ForwardingMode `json:"forwarding,omitempty"`
+
+	// Try are the Servers players join, in order. Servers that aren't running are skipped.
+	// Defaults to the running Servers by name
+	// +optional
+	Try []string `json:"try,omitempty"`
+
+	// Motd is the message of the day of the proxy
+	// +optional
+	Motd string `json:"motd,omitempty"`
+
+	// MaxPlayers is the number of players the server list shows the proxy can take
+	// +kubebuilder:default=20
+	// +optional
+	MaxPlayers int32 `json:"maxPlayers,omitempty"`
+
+	// HostPort defines the host port the proxy binds to. Defaults to empty/disabled
+	// +optional
+	HostPort int32 `json:"hostPort,omitempty"`
+
+	// MaxMemory is the memory of the proxy, in MB
+	// +kubebuilder:default=512
+	// +optional
+	MaxMemory int32 `json:"maxMemoryMB,omitempty"`
+}
+
+// NetworkServer is a Server of the Network
+type NetworkServer struct {
+	// Name is the name of the Server, players switch to it with /server <name>
+	Name string `json:"name"`
+
+	// Address is where the proxy reaches the Server
+	Address string `json:"address"`
+
+	// Running tells if the Server is running, only running Servers are in the config of the proxy
+	Running bool `json:"running"`
+}
+
+// NetworkStatus defines the observed state of Network
+type NetworkStatus struct {
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// Servers are the Servers the Network selects
+	// +optional
+	Servers []NetworkServer `json:"servers,omitempty"`
+
+	// ConfigHash is the hash of the config of the proxy, which restarts the proxy when it changes
+	// +optional
+	ConfigHash string `json:"configHash,omitempty"`
+
+	// Ready tells if the proxy is running
+	Ready bool `json:"ready"`
+
+	// Conditions hold warnings about the Network, like settings that can't be applied
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+//+kubebuilder:printcolumn:name="Ready",type=boolean,JSONPath=`.status.ready`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Network is the Schema for the networks API. It runs a proxy in front of the Servers it selects, so players
+// can switch between them with /server.
+type Network struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NetworkSpec   `json:"spec,omitempty"`
+	Status NetworkStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NetworkList contains a list of Network
+type NetworkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Network `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Network{}, &NetworkList{})
+}
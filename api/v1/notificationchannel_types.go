@@ -0,0 +1,132 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NotificationChannelType is the kind of chat service a NotificationChannel sends to
+// +kubebuilder:validation:Enum=Discord;Slack;Matrix;Webhook
+type NotificationChannelType string
+
+const (
+	// NotificationChannelDiscord sends to a Discord webhook
+	NotificationChannelDiscord NotificationChannelType = "Discord"
+	// NotificationChannelSlack sends to a Slack incoming webhook
+	NotificationChannelSlack NotificationChannelType = "Slack"
+	// NotificationChannelMatrix sends to a Matrix room, through the client API of the homeserver
+	NotificationChannelMatrix NotificationChannelType = "Matrix"
+	// NotificationChannelWebhook posts the notification as JSON to any URL
+	NotificationChannelWebhook NotificationChannelType = "Webhook"
+)
+
+// NotificationEvent is something that happened to a Server, that can be notified
+// +kubebuilder:validation:Enum=PlayerJoined;PlayerLeft;ServerOnline;ServerOffline;IdleShutdown;Crash;Backup
+type NotificationEvent string
+
+const (
+	// NotificationPlayerJoined is sent when a player shows up in the Server status
+	NotificationPlayerJoined NotificationEvent = "PlayerJoined"
+	// NotificationPlayerLeft is sent when a player is gone from the Server status
+	NotificationPlayerLeft NotificationEvent = "PlayerLeft"
+	// NotificationServerOnline is sent when the Server starts answering pings
+	NotificationServerOnline NotificationEvent = "ServerOnline"
+	// NotificationServerOffline is sent when the Server stops answering pings
+	NotificationServerOffline NotificationEvent = "ServerOffline"
+	// NotificationIdleShutdown is sent when the Server is stopped because nobody played on it
+	NotificationIdleShutdown NotificationEvent = "IdleShutdown"
+	// NotificationCrash is sent when the Server crashed
+	NotificationCrash NotificationEvent = "Crash"
+	// NotificationBackup is sent with the result of a backup of the Server
+	NotificationBackup NotificationEvent = "Backup"
+)
+
+// NotificationChannelSpec defines the desired state of NotificationChannel
+type NotificationChannelSpec struct {
+	// Important: Run "make" to regenerate code after modifying this file
+
+	// Type is the kind of service to send to
+	Type NotificationChannelType `json:"type"`
+
+	// URL is the webhook URL to send to, or the URL of the homeserver for Matrix
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// URLSecretKeyRef takes the URL from a Secret, as webhook URLs hold their credentials
+	// +optional
+	URLSecretKeyRef *corev1.SecretKeySelector `json:"urlSecretKeyRef,omitempty"`
+
+	// Room is the ID of the Matrix room to send to (e.g.: !abcdef:example.org)
+	// +optional
+	Room string `json:"room,omitempty"`
+
+	// TokenSecretKeyRef takes the access token of the Matrix user that sends the messages from a Secret
+	// +optional
+	TokenSecretKeyRef *corev1.SecretKeySelector `json:"tokenSecretKeyRef,omitempty"`
+
+	// Servers are the names of the Servers to notify about. Defaults to all Servers in the namespace
+	// +optional
+	Servers []string `json:"servers,omitempty"`
+
+	// Events are the events to notify about. Defaults to all events
+	// +optional
+	Events []NotificationEvent `json:"events,omitempty"`
+
+	// Templates replace the message for an event, as a Go template (e.g.: PlayerJoined: "{{ .Player }} is online").
+	// The templates get the Type, Namespace, Server, Player and Message of the event
+	// +optional
+	Templates map[NotificationEvent]string `json:"templates,omitempty"`
+
+	// RateLimitPerMinute is the number of messages the channel sends per minute at most, the rest is dropped.
+	// Defaults to 10
+	// +optional
+	RateLimitPerMinute int32 `json:"rateLimitPerMinute,omitempty"`
+}
+
+// NotificationChannelStatus defines the observed state of NotificationChannel
+type NotificationChannelStatus struct {
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// NotificationChannel is the Schema for the notificationchannels API
+type NotificationChannel struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NotificationChannelSpec   `json:"spec,omitempty"`
+	Status NotificationChannelStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NotificationChannelList contains a list of NotificationChannel
+type NotificationChannelList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationChannel `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationChannel{}, &NotificationChannelList{})
+}
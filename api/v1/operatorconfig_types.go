@@ -35,12 +35,80 @@ type OperatorConfigSpec struct {
 	// ModJarsPVC is the name of the PVC that holds the mod JARs
 	ModJarsPVC string `json:"mod-jars-pvc"`
 
+	// PluginJarsPVC is the name of the PVC that holds the plugin JARs. Defaults to none
+	// +optional
+	PluginJarsPVC string `json:"plugin-jars-pvc,omitempty"`
+
 	// ServersPV is the name of the PV that holds the PVCs for the Servers
 	ServersPV *v1.PersistentVolume `json:"servers-pv"`
 
-	// InitContainerImage is the name of the docker image to use for the init container. Defaults to busybox
+	// InitContainerImage is the name of the docker image to use for helper containers, like world transfers. Defaults to busybox
 	// +optional
 	InitContainerImage string `json:"init-container-image"`
+
+	// InitImage is the name of the docker image with the init binary, which is the operator image. Defaults to hsmade/minecraft-operator:latest
+	// +optional
+	InitImage string `json:"init-image,omitempty"`
+
+	// ResourcePackURL is the URL where players reach the web UI of the operator (e.g.: http://minecraft.example.com:8082),
+	// which serves the resource packs of the Servers. Resource packs need it
+	// +optional
+	ResourcePackURL string `json:"resource-pack-url,omitempty"`
+
+	// Capacity limits the Servers that can run at the same time. Defaults to no limits
+	// +optional
+	Capacity *CapacitySpec `json:"capacity,omitempty"`
+
+	// JavaImages map Minecraft versions to the Java runtime image for Servers without an Image.
+	// The first matching rule is used. Defaults to Java 8 before 1.17, Java 17 up to 1.20.4 and Java 21 from 1.20.5
+	// +optional
+	JavaImages []JavaImageRule `json:"javaImages,omitempty"`
+}
+
+// JavaImageRule maps a range of Minecraft versions to a Java runtime image
+type JavaImageRule struct {
+	// MinVersion is the first Minecraft version of the range (e.g.: 1.17). Defaults to no lower bound
+	// +optional
+	MinVersion string `json:"minVersion,omitempty"`
+
+	// MaxVersion is the first Minecraft version after the range (e.g.: 1.20.5). Defaults to no upper bound
+	// +optional
+	MaxVersion string `json:"maxVersion,omitempty"`
+
+	// JavaVersion is the major Java version the range needs (e.g.: 17)
+	JavaVersion int32 `json:"javaVersion"`
+
+	// Image is the Java runtime image for the range (e.g.: eclipse-temurin:17-jre)
+	Image string `json:"image"`
+}
+
+// PreemptionPolicy defines which running Servers a queued Server may stop to make room
+// +kubebuilder:validation:Enum=Never;LowerPriority;EqualOrLowerPriority
+type PreemptionPolicy string
+
+const (
+	// PreemptNever never stops running Servers, queued Servers wait for room
+	PreemptNever PreemptionPolicy = "Never"
+	// PreemptLowerPriority stops idle Servers with a lower priority than the queued Server
+	PreemptLowerPriority PreemptionPolicy = "LowerPriority"
+	// PreemptEqualOrLowerPriority stops idle Servers with the same or a lower priority than the queued Server
+	PreemptEqualOrLowerPriority PreemptionPolicy = "EqualOrLowerPriority"
+)
+
+// CapacitySpec limits the Servers that can run at the same time
+type CapacitySpec struct {
+	// MaxRunningServers is the maximum number of Servers running at the same time. Defaults to 0/no limit
+	// +optional
+	MaxRunningServers int32 `json:"maxRunningServers,omitempty"`
+
+	// MemoryBudgetMB is the maximum memory of all running Servers together, in MB. Defaults to 0/no limit
+	// +optional
+	MemoryBudgetMB int64 `json:"memoryBudgetMB,omitempty"`
+
+	// Preemption defines which running Servers without players get stopped to make room for a queued Server,
+	// starting with the one that's been idle the longest. Defaults to Never
+	// +optional
+	Preemption PreemptionPolicy `json:"preemption,omitempty"`
 }
 
 // OperatorConfigStatus defines the observed state of OperatorConfig
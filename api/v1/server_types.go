@@ -17,6 +17,7 @@ limitations under the License.
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -24,27 +25,37 @@ import (
 type ServerSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Image is the docker image to run.
-	Image string `json:"image"`
+	// TemplateRef is the name of a ServerTemplate in the Server's namespace, which fills in the spec.
+	// The Server's own fields override the template, as far as the template allows
+	// +optional
+	TemplateRef string `json:"templateRef,omitempty"`
+
+	// Image is the docker image to run. Defaults to the Java runtime image for the ServerVersion from the OperatorConfig
+	// +optional
+	Image string `json:"image,omitempty"`
 
 	// ModJars is a list of minecraft mods to be installed on the Server. Defaults to empty
 	// +optional
 	ModJars []string `json:"mod-jars,omitempty"`
 
 	// Enabled defines if the Server should be running or not. Defaults to false
+	// +optional
 	Enabled bool `json:"enabled"`
 
 	// Properties file settings
 	// +optional
 	Properties map[string]string `json:"properties"`
 
-	// Max memory (Xmx), in MB
+	// Max memory (Xmx), in MB. Required, unless the template sets it
+	// +optional
 	MaxMemory int32 `json:"maxMemoryMB"`
 
-	// Initial memory (Xms), in MB
+	// Initial memory (Xms), in MB. Defaults to MaxMemory
+	// +optional
 	InitMemory int32 `json:"initMemoryMB"`
 
-	// The server version to run (e.g.: vanilla-1.16.5, forge-1.12.2)
+	// The server version to run (e.g.: vanilla-1.16.5, forge-1.12.2). Required, unless the template sets it
+	// +optional
 	ServerVersion string `json:"server-version"`
 
 	// HostPort defines the host port to bind to. Defaults to empty/disabled
@@ -55,6 +66,502 @@ type ServerSpec struct {
 	// When it's not set (which is the default), it will not automatically disable the server, and it will keep running.
 	// +optional
 	IdleTimeoutSeconds int64 `json:"idleTimeoutSeconds,omitempty"`
+
+	// World defines how the default world is generated. These settings only apply when the world is created.
+	// +optional
+	World *WorldSpec `json:"world,omitempty"`
+
+	// Worlds are additional worlds for the Server, next to the default world. Each is stored separately on the volume.
+	// +optional
+	Worlds []NamedWorld `json:"worlds,omitempty"`
+
+	// ActiveWorld is the name of the world to run. Defaults to the default world
+	// +optional
+	ActiveWorld string `json:"activeWorld,omitempty"`
+
+	// StopGracePeriodSeconds is the time players get warned before the Server stops, after it got disabled.
+	// The warning is skipped when there are no players. Defaults to 30
+	// +optional
+	StopGracePeriodSeconds *int64 `json:"stopGracePeriodSeconds,omitempty"`
+
+	// Schedule limits the times the Server may run. Outside of its windows, the Server is stopped.
+	// When it's not set (which is the default), the Server may run at any time
+	// +optional
+	Schedule *Schedule `json:"schedule,omitempty"`
+
+	// Priority decides the order in which queued Servers start, and which Servers they may stop to make room,
+	// when the operator limits the Servers that can run at the same time. Higher goes first. Defaults to 0
+	// +optional
+	Priority int32 `json:"priority,omitempty"`
+
+	// JVM holds the settings for the java process and the resources of its container
+	// +optional
+	JVM *JVMSpec `json:"jvm,omitempty"`
+
+	// Files are extra files for the Server, like mod configs, bukkit.yml or datapacks. Changes restart the Server
+	// +optional
+	Files []ServerFile `json:"files,omitempty"`
+
+	// Plugins are installed in plugins/, for Bukkit-family servers (e.g.: paper, spigot, purpur). Defaults to empty
+	// +optional
+	Plugins []Plugin `json:"plugins,omitempty"`
+
+	// ResourcePack is offered to the players when they join. The web UI of the operator serves it
+	// +optional
+	ResourcePack *ResourcePack `json:"resourcePack,omitempty"`
+
+	// Bedrock lets Bedrock Edition players (e.g.: on tablets and consoles) join, with Geyser. Defaults to disabled
+	// +optional
+	Bedrock *BedrockSpec `json:"bedrock,omitempty"`
+
+	// PlaytimeLimit limits the time players may play on the Server per day. Defaults to no limit
+	// +optional
+	PlaytimeLimit *PlaytimeLimit `json:"playtimeLimit,omitempty"`
+
+	// CrashPolicy decides what happens when the Server keeps crashing.
+	// Defaults to keeping it from running for 10 minutes, after 3 crashes in 10 minutes
+	// +optional
+	CrashPolicy *CrashPolicy `json:"crashPolicy,omitempty"`
+
+	// PersistedPaths are extra files and directories, relative to the server directory, that keep what the game
+	// changes in them across restarts (e.g.: config/ or a mod's data file), next to the ones of the server type.
+	// Directories end in a slash
+	// +optional
+	PersistedPaths []string `json:"persistedPaths,omitempty"`
+
+	// UpdatePolicy decides how changes of the ServerVersion are applied. Defaults to refusing downgrades,
+	// checking the mods and keeping 3 snapshots
+	// +optional
+	UpdatePolicy *UpdatePolicy `json:"updatePolicy,omitempty"`
+
+	// Rollback restores the world snapshot with this name from the version history, which was taken before an
+	// update. The ServerVersion has to be set to the version the snapshot was taken of
+	// +optional
+	Rollback string `json:"rollback,omitempty"`
+}
+
+// UpdatePolicy decides how the Server moves to another server version. Before starting on another version,
+// a snapshot of the world is taken, which can be restored with a rollback.
+type UpdatePolicy struct {
+	// AllowDowngrade starts the Server on an older Minecraft version than it ran before, which most worlds don't
+	// survive. Without it, the Server keeps running the version it ran. Defaults to false
+	// +optional
+	AllowDowngrade bool `json:"allowDowngrade,omitempty"`
+
+	// SkipModCheck starts the new version, even when the mods don't declare to support its Minecraft version.
+	// Defaults to false
+	// +optional
+	SkipModCheck bool `json:"skipModCheck,omitempty"`
+
+	// KeepSnapshots is the number of world snapshots of earlier updates to keep. Defaults to 3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	KeepSnapshots int32 `json:"keepSnapshots,omitempty"`
+}
+
+// CrashAction is what happens to a Server that crashed too often
+// +kubebuilder:validation:Enum=Backoff;Disable
+type CrashAction string
+
+const (
+	// CrashActionBackoff keeps the Server from running for a while, and then starts it again
+	CrashActionBackoff CrashAction = "Backoff"
+	// CrashActionDisable disables the Server
+	CrashActionDisable CrashAction = "Disable"
+)
+
+// CrashPolicy decides what happens when a Server keeps crashing
+type CrashPolicy struct {
+	// MaxCrashes is the number of crashes within the window that triggers the action. Defaults to 3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxCrashes int32 `json:"maxCrashes,omitempty"`
+
+	// WindowMinutes is the period the crashes are counted in. Defaults to 10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	WindowMinutes int32 `json:"windowMinutes,omitempty"`
+
+	// Action is what happens when the Server crashed too often (Backoff, Disable). Defaults to Backoff
+	// +optional
+	Action CrashAction `json:"action,omitempty"`
+
+	// BackoffMinutes is how long the Server is kept from running by the Backoff action. Defaults to 10
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	BackoffMinutes int32 `json:"backoffMinutes,omitempty"`
+}
+
+// PlaytimeLimit limits the time players may play on a Server per day. Players that reach it are kicked
+type PlaytimeLimit struct {
+	// DailyMinutes is the time each player may play per day, in minutes. Defaults to 0/no limit
+	// +optional
+	DailyMinutes int32 `json:"dailyMinutes,omitempty"`
+
+	// Players set the daily minutes for specific players, instead of DailyMinutes. 0 means no limit for the player
+	// +optional
+	Players map[string]int32 `json:"players,omitempty"`
+
+	// Timezone is the IANA name of the timezone the days start in (e.g.: Europe/Amsterdam).
+	// Defaults to the timezone of the schedule, or UTC
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Message is what kicked players see. Defaults to "You reached your playtime for today, see you tomorrow!"
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// BedrockMode is how Geyser runs next to the Server
+// +kubebuilder:validation:Enum=Plugin;Sidecar
+type BedrockMode string
+
+const (
+	// BedrockPlugin installs Geyser (and Floodgate) as plugins, for Bukkit-family servers
+	BedrockPlugin BedrockMode = "Plugin"
+	// BedrockSidecar runs Geyser Standalone in a container next to the server, for any server type
+	BedrockSidecar BedrockMode = "Sidecar"
+)
+
+// BedrockSpec sets up Geyser, which translates between Bedrock Edition players and the Java Edition Server.
+// Bedrock players connect over UDP on port 19132.
+type BedrockSpec struct {
+	// Enabled installs and runs Geyser. Defaults to false
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Mode is how Geyser runs (Plugin, Sidecar). Defaults to Plugin for server types that load plugins,
+	// and Sidecar for the others
+	// +optional
+	Mode BedrockMode `json:"mode,omitempty"`
+
+	// Geyser is the Geyser jar: Geyser-Spigot for the Plugin mode, Geyser-Standalone for the Sidecar mode
+	Geyser BedrockJar `json:"geyser"`
+
+	// Floodgate is the Floodgate plugin jar, that lets Bedrock players join without a Java Edition account.
+	// Only for the Plugin mode. Without it, Bedrock players log in with a Java Edition account
+	// +optional
+	Floodgate *BedrockJar `json:"floodgate,omitempty"`
+
+	// Image is the image of the Geyser container in the Sidecar mode. Defaults to eclipse-temurin:21-jre
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// HostPort defines the host port Geyser binds to, for UDP. Defaults to empty/disabled
+	// +optional
+	HostPort int32 `json:"hostPort,omitempty"`
+}
+
+// BedrockJar is a jar for Bedrock support, taken from the plugin jars PVC or downloaded from a URL
+type BedrockJar struct {
+	// Jar is the name of the jar on the plugin jars PVC
+	// +optional
+	Jar string `json:"jar,omitempty"`
+
+	// URL is where to download the jar from, instead of the PVC. It needs SHA256
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// SHA256 is the checksum of the jar
+	// +kubebuilder:validation:Pattern=`^[a-fA-F0-9]{64}$`
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// ResourcePack is a resource pack zip, from a ConfigMap or from the resource packs directory of the operator
+type ResourcePack struct {
+	// ConfigMapKeyRef takes the pack from a key of a ConfigMap in the Server's namespace, preferably in binaryData
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// Path is the path of the pack in the resource packs directory of the operator (e.g.: faithful-32x.zip)
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Required makes players accept the pack to join. Defaults to false
+	// +optional
+	Required bool `json:"required,omitempty"`
+
+	// Prompt is the message players see when they're asked to accept the pack
+	// +optional
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// Plugin is a Bukkit-family plugin, taken from the plugin jars PVC or downloaded from a URL
+type Plugin struct {
+	// Name is the name of the plugin, which is also the name of its directory under plugins/ (e.g.: EssentialsX)
+	Name string `json:"name"`
+
+	// Jar is the name of the plugin jar on the plugin jars PVC
+	// +optional
+	Jar string `json:"jar,omitempty"`
+
+	// URL is where to download the plugin jar from, instead of the PVC. It needs SHA256
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// SHA256 is the checksum of the plugin jar
+	// +kubebuilder:validation:Pattern=`^[a-fA-F0-9]{64}$`
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+
+	// Files are config files for the plugin, with paths relative to its directory (e.g.: config.yml)
+	// +optional
+	Files []ServerFile `json:"files,omitempty"`
+}
+
+// ServerFile is a file that's placed in the Server's directory before it starts.
+// Its content is either inline, or comes from a ConfigMap or Secret.
+type ServerFile struct {
+	// Path is the path of the file, relative to the Server's directory (e.g.: config/jei-client.toml, world/datapacks/pack.zip)
+	Path string `json:"path"`
+
+	// Content is the inline content of the file
+	// +optional
+	Content string `json:"content,omitempty"`
+
+	// ConfigMapKeyRef takes the content from a key of a ConfigMap in the Server's namespace
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+
+	// SecretKeyRef takes the content from a key of a Secret in the Server's namespace
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+
+	// Template renders the content as a Go template, with the Server as data (e.g.: {{ .Name }}, {{ .Spec.ServerVersion }}).
+	// Defaults to false
+	// +optional
+	Template bool `json:"template,omitempty"`
+}
+
+// JVMPreset is a set of JVM flags
+// +kubebuilder:validation:Enum=none;g1;aikar
+type JVMPreset string
+
+const (
+	// JVMPresetNone adds no flags
+	JVMPresetNone JVMPreset = "none"
+	// JVMPresetG1 enables the G1 garbage collector
+	JVMPresetG1 JVMPreset = "g1"
+	// JVMPresetAikar adds Aikar's tuned G1 flags, see https://mcflags.emc.gs
+	JVMPresetAikar JVMPreset = "aikar"
+)
+
+// JVMSpec defines the settings for the java process and the resources of its container
+type JVMSpec struct {
+	// Preset is a set of JVM flags to add (none, g1, aikar). Defaults to none
+	// +optional
+	Preset JVMPreset `json:"preset,omitempty"`
+
+	// ExtraFlags are added to the JVM flags, after the preset
+	// +optional
+	ExtraFlags []string `json:"extraFlags,omitempty"`
+
+	// MemoryOverheadMB is the memory for the JVM on top of the heap (MaxMemory), in MB.
+	// The container's memory request and limit are the heap plus the overhead. Defaults to 512
+	// +optional
+	MemoryOverheadMB *int32 `json:"memoryOverheadMB,omitempty"`
+
+	// CPURequest is the CPU request of the container (e.g.: 500m, 2). Defaults to none
+	// +kubebuilder:validation:Pattern=`^(\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))(([KMGTPE]i)|[numkMGTPE]|([eE](\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))))?$`
+	// +optional
+	CPURequest string `json:"cpuRequest,omitempty"`
+
+	// CPULimit is the CPU limit of the container (e.g.: 500m, 2). Defaults to none
+	// +kubebuilder:validation:Pattern=`^(\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))(([KMGTPE]i)|[numkMGTPE]|([eE](\+|-)?(([0-9]+(\.[0-9]*)?)|(\.[0-9]+))))?$`
+	// +optional
+	CPULimit string `json:"cpuLimit,omitempty"`
+}
+
+// Schedule defines when a Server may run
+type Schedule struct {
+	// Timezone is the IANA name of the timezone the windows are in (e.g.: Europe/Amsterdam). Defaults to UTC
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Windows are the periods in which the Server may run
+	Windows []ScheduleWindow `json:"windows"`
+}
+
+// ScheduleWindow is a period in which a Server may run. It's either defined by days with a start and end time,
+// or by a cron expression for the start with a duration.
+type ScheduleWindow struct {
+	// Days are the days the window starts on (Mon, Tue, Wed, Thu, Fri, Sat, Sun). Defaults to every day
+	// +optional
+	Days []string `json:"days,omitempty"`
+
+	// Start is the time the window starts, as HH:MM
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	// +optional
+	Start string `json:"start,omitempty"`
+
+	// End is the time the window ends, as HH:MM. When it's not after Start, the window ends on the next day
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	// +optional
+	End string `json:"end,omitempty"`
+
+	// Cron is a cron expression (minute hour day-of-month month day-of-week) for the start of the window,
+	// instead of Days and Start
+	// +optional
+	Cron string `json:"cron,omitempty"`
+
+	// DurationMinutes is the length of a window that starts by Cron
+	// +optional
+	DurationMinutes int32 `json:"durationMinutes,omitempty"`
+
+	// AutoStart starts the Server when the window opens, and keeps the idle timeout from stopping it during the window.
+	// Without it, the Server can be started on demand during the window. Defaults to false
+	// +optional
+	AutoStart bool `json:"autoStart,omitempty"`
+}
+
+// NamedWorld is an additional world for a Server
+type NamedWorld struct {
+	// Name is the name of the world. "default" is the name of the default world, so it can't be used
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	WorldSpec `json:",inline"`
+}
+
+// WorldGeneration holds the settings a world is generated with
+type WorldGeneration struct {
+	// Seed is the seed for the world generator. Defaults to a random seed
+	// +optional
+	Seed string `json:"seed,omitempty"`
+
+	// LevelType is the type of world to generate (e.g.: minecraft:normal, minecraft:flat, minecraft:amplified). Defaults to the server default
+	// +optional
+	LevelType string `json:"levelType,omitempty"`
+
+	// GeneratorSettings are the settings for the world generator, e.g. the layers of a flat world
+	// +optional
+	GeneratorSettings string `json:"generatorSettings,omitempty"`
+}
+
+// WorldSpec defines how the world of a Server is generated
+type WorldSpec struct {
+	WorldGeneration `json:",inline"`
+
+	// PregenerateRadius is the radius in blocks around spawn to generate when the world is created.
+	// This needs the Chunky plugin or mod on the Server. Defaults to 0/disabled
+	// +optional
+	PregenerateRadius int32 `json:"pregenerateRadius,omitempty"`
+
+	// Regenerate archives the current world and generates a new one when it's set to a value that differs from the
+	// last regeneration (e.g. a timestamp)
+	// +optional
+	Regenerate string `json:"regenerate,omitempty"`
+}
+
+// WorldStatus defines the observed state of a world of a Server
+type WorldStatus struct {
+	// Name is the name of the world
+	Name string `json:"name"`
+
+	// Thumbnail is base64 of the thumbnail image, as last seen while the world was active
+	// +optional
+	Thumbnail string `json:"thumbnail,omitempty"`
+
+	// Generated holds the settings the current world was generated with
+	// +optional
+	Generated *WorldGeneration `json:"generated,omitempty"`
+
+	// Regenerated is the value of Regenerate at the last regeneration
+	// +optional
+	Regenerated string `json:"regenerated,omitempty"`
+
+	// Pregenerated shows if the pre-generation for the current world has been started
+	// +optional
+	Pregenerated bool `json:"pregenerated,omitempty"`
+}
+
+// CrashStatus describes a crash of a Server. Its log and crash report are kept in the <server>-crash ConfigMap
+type CrashStatus struct {
+	// Time is the timestamp when the Server exited
+	Time int64 `json:"time"`
+
+	// ExitCode is the exit code of the Server
+	ExitCode int32 `json:"exitCode"`
+
+	// Reason is the reason the container stopped (e.g.: Error, OOMKilled)
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message tells what went wrong, as far as the log shows
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ReportPath is the path of the crash report the Server wrote
+	// +optional
+	ReportPath string `json:"reportPath,omitempty"`
+
+	// ReportCaptured shows if the crash report has been copied into the ConfigMap
+	// +optional
+	ReportCaptured bool `json:"reportCaptured,omitempty"`
+}
+
+// VersionChange is a move of the Server from one server version to another
+type VersionChange struct {
+	// From is the server version the Server ran before
+	From string `json:"from"`
+
+	// To is the server version the Server moved to
+	To string `json:"to"`
+
+	// Time is the timestamp when the change was started
+	Time int64 `json:"time"`
+
+	// Snapshot is the name of the world snapshot taken before the change. It's cleared when the snapshot is removed
+	// +optional
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// Restored is the name of the snapshot that was restored with the change, for rollbacks
+	// +optional
+	Restored string `json:"restored,omitempty"`
+}
+
+// ServerPhase is the lifecycle phase of a Server
+type ServerPhase string
+
+const (
+	// ServerPhaseStopped means the Server is disabled and not running
+	ServerPhaseStopped ServerPhase = "Stopped"
+	// ServerPhaseQueued means the Server is enabled, but waits for room to run
+	ServerPhaseQueued ServerPhase = "Queued"
+	// ServerPhaseStarting means the Server is enabled, but can't be reached yet
+	ServerPhaseStarting ServerPhase = "Starting"
+	// ServerPhaseRunning means the Server is enabled and can be reached
+	ServerPhaseRunning ServerPhase = "Running"
+	// ServerPhaseStopping means the Server is disabled, and is warning players, saving and stopping
+	ServerPhaseStopping ServerPhase = "Stopping"
+)
+
+// ServerNetworkStatus is the Network a Server is behind
+type ServerNetworkStatus struct {
+	// Name is the name of the Network
+	Name string `json:"name"`
+
+	// Forwarding is how the proxy of the Network passes the players' identity on to the Server
+	Forwarding ForwardingMode `json:"forwarding"`
+}
+
+// BedrockStatus is what Geyser answered to the last Bedrock ping
+type BedrockStatus struct {
+	// Reachable tells if Geyser answered the last Bedrock ping
+	Reachable bool `json:"reachable"`
+
+	// Version is the Bedrock Edition version Geyser takes
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Players is the number of players online, as Geyser reports it
+	// +optional
+	Players int32 `json:"players,omitempty"`
+
+	// LastPong is the timestamp of the last Bedrock pong
+	// +optional
+	LastPong int64 `json:"lastPong,omitempty"`
 }
 
 // ServerStatus defines the observed state of Server
@@ -64,6 +571,42 @@ type ServerStatus struct {
 	// Running shows if the Server is running
 	Running bool `json:"running"`
 
+	// Phase is the lifecycle phase of the Server
+	// +optional
+	Phase ServerPhase `json:"phase,omitempty"`
+
+	// StopRequestedAt is the timestamp when the stop sequence started
+	// +optional
+	StopRequestedAt int64 `json:"stopRequestedAt,omitempty"`
+
+	// StopAnnounced is the number of seconds left at the last warning to the players
+	// +optional
+	StopAnnounced int64 `json:"stopAnnounced,omitempty"`
+
+	// StopIssuedAt is the timestamp when the Server got the stop command
+	// +optional
+	StopIssuedAt int64 `json:"stopIssuedAt,omitempty"`
+
+	// Image is the docker image the Server runs
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// QueuedAt is the timestamp when the Server got queued
+	// +optional
+	QueuedAt int64 `json:"queuedAt,omitempty"`
+
+	// FilesHash is the hash of the content of the Files, which restarts the Server when it changes
+	// +optional
+	FilesHash string `json:"filesHash,omitempty"`
+
+	// ResourcePackSHA1 is the SHA1 of the resource pack, which the players' clients check it against
+	// +optional
+	ResourcePackSHA1 string `json:"resourcePackSHA1,omitempty"`
+
+	// ScheduledStart is the start of the schedule window the Server was last started for automatically
+	// +optional
+	ScheduledStart int64 `json:"scheduledStart,omitempty"`
+
 	// Thumbnail is base64 of the thumbnail image for the loaded world
 	// +optional
 	Thumbnail string `json:"thumbnail,omitempty"`
@@ -76,13 +619,64 @@ type ServerStatus struct {
 	// +optional
 	LastPong int64 `json:"lastPong,omitempty"`
 
+	// FailedPings is the number of pings in a row the enabled Server didn't answer
+	// +optional
+	FailedPings int32 `json:"failedPings,omitempty"`
+
 	//IdleTime is the timestamp when we last saw players
 	// +optional
 	IdleTime int64 `json:"idleTime,omitempty"`
+
+	// Worlds is the observed state of the worlds
+	// +optional
+	Worlds []WorldStatus `json:"worlds,omitempty"`
+
+	// LastCrash is the last time the Server crashed
+	// +optional
+	LastCrash *CrashStatus `json:"lastCrash,omitempty"`
+
+	// CrashTimes are the timestamps of the crashes within the window of the crash policy
+	// +optional
+	CrashTimes []int64 `json:"crashTimes,omitempty"`
+
+	// CrashBackoffUntil is the timestamp until which the Server is kept from running, after crashing too often
+	// +optional
+	CrashBackoffUntil int64 `json:"crashBackoffUntil,omitempty"`
+
+	// ServerVersion is the server version the Server last ran
+	// +optional
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// PendingUpdate is the change of the server version that's being applied, until the Server runs the new version
+	// +optional
+	PendingUpdate *VersionChange `json:"pendingUpdate,omitempty"`
+
+	// VersionHistory are the changes of the server version, oldest first
+	// +optional
+	VersionHistory []VersionChange `json:"versionHistory,omitempty"`
+
+	// Network is the Network whose proxy the Server is behind
+	// +optional
+	Network *ServerNetworkStatus `json:"network,omitempty"`
+
+	// Bedrock is what Geyser answers to a Bedrock ping, for Servers with Bedrock enabled
+	// +optional
+	Bedrock *BedrockStatus `json:"bedrock,omitempty"`
+
+	// EffectiveSpec is the spec the Server runs with, after merging its ServerTemplate into it.
+	// It's only set for Servers with a TemplateRef
+	// +optional
+	EffectiveSpec *ServerSpec `json:"effectiveSpec,omitempty"`
+
+	// Conditions hold warnings about the Server, like settings that can't be applied
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Server is the Schema for the servers API
 type Server struct {
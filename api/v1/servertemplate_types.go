@@ -0,0 +1,71 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServerTemplateSpec defines the desired state of ServerTemplate
+type ServerTemplateSpec struct {
+	// Description tells what the template is for, it's shown when adding a Server in the web UI
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Defaults is the spec of the Servers that use the template. Enabled, Rollback and TemplateRef are up to
+	// the Server
+	Defaults ServerSpec `json:"defaults"`
+
+	// AllowedOverrides are the fields the Servers may set to something else than the template, as they're named in
+	// the manifest (e.g.: maxMemoryMB, properties.motd, or properties for all properties). Fields the template
+	// doesn't set can always be set by the Server
+	// +optional
+	AllowedOverrides []string `json:"allowedOverrides,omitempty"`
+}
+
+// ServerTemplateStatus defines the observed state of ServerTemplate
+type ServerTemplateStatus struct {
+	// Important: Run "make" to regenerate code after modifying this file
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.spec.defaults.server-version`
+//+kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ServerTemplate is the Schema for the servertemplates API
+type ServerTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerTemplateSpec   `json:"spec,omitempty"`
+	Status ServerTemplateStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ServerTemplateList contains a list of ServerTemplate
+type ServerTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServerTemplate{}, &ServerTemplateList{})
+}
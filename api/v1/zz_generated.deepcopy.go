@@ -23,9 +23,400 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BedrockJar) DeepCopyInto(out *BedrockJar) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BedrockJar.
+func (in *BedrockJar) DeepCopy() *BedrockJar {
+	if in == nil {
+		return nil
+	}
+	out := new(BedrockJar)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BedrockSpec) DeepCopyInto(out *BedrockSpec) {
+	*out = *in
+	out.Geyser = in.Geyser
+	if in.Floodgate != nil {
+		in, out := &in.Floodgate, &out.Floodgate
+		*out = new(BedrockJar)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BedrockSpec.
+func (in *BedrockSpec) DeepCopy() *BedrockSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BedrockSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BedrockStatus) DeepCopyInto(out *BedrockStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BedrockStatus.
+func (in *BedrockStatus) DeepCopy() *BedrockStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BedrockStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CapacitySpec) DeepCopyInto(out *CapacitySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CapacitySpec.
+func (in *CapacitySpec) DeepCopy() *CapacitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CapacitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CrashPolicy) DeepCopyInto(out *CrashPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrashPolicy.
+func (in *CrashPolicy) DeepCopy() *CrashPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CrashPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CrashStatus) DeepCopyInto(out *CrashStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CrashStatus.
+func (in *CrashStatus) DeepCopy() *CrashStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(CrashStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JVMSpec) DeepCopyInto(out *JVMSpec) {
+	*out = *in
+	if in.ExtraFlags != nil {
+		in, out := &in.ExtraFlags, &out.ExtraFlags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.MemoryOverheadMB != nil {
+		in, out := &in.MemoryOverheadMB, &out.MemoryOverheadMB
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JVMSpec.
+func (in *JVMSpec) DeepCopy() *JVMSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(JVMSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JavaImageRule) DeepCopyInto(out *JavaImageRule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JavaImageRule.
+func (in *JavaImageRule) DeepCopy() *JavaImageRule {
+	if in == nil {
+		return nil
+	}
+	out := new(JavaImageRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamedWorld) DeepCopyInto(out *NamedWorld) {
+	*out = *in
+	out.WorldSpec = in.WorldSpec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamedWorld.
+func (in *NamedWorld) DeepCopy() *NamedWorld {
+	if in == nil {
+		return nil
+	}
+	out := new(NamedWorld)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Network) DeepCopyInto(out *Network) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
+func (in *Network) DeepCopy() *Network {
+	if in == nil {
+		return nil
+	}
+	out := new(Network)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Network) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkList) DeepCopyInto(out *NetworkList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Network, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkList.
+func (in *NetworkList) DeepCopy() *NetworkList {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NetworkList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkServer) DeepCopyInto(out *NetworkServer) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkServer.
+func (in *NetworkServer) DeepCopy() *NetworkServer {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkServer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
+	*out = *in
+	in.ServerSelector.DeepCopyInto(&out.ServerSelector)
+	if in.Try != nil {
+		in, out := &in.Try, &out.Try
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
+func (in *NetworkSpec) DeepCopy() *NetworkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkStatus) DeepCopyInto(out *NetworkStatus) {
+	*out = *in
+	if in.Servers != nil {
+		in, out := &in.Servers, &out.Servers
+		*out = make([]NetworkServer, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkStatus.
+func (in *NetworkStatus) DeepCopy() *NetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationChannel) DeepCopyInto(out *NotificationChannel) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationChannel.
+func (in *NotificationChannel) DeepCopy() *NotificationChannel {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationChannel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NotificationChannel) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationChannelList) DeepCopyInto(out *NotificationChannelList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NotificationChannel, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationChannelList.
+func (in *NotificationChannelList) DeepCopy() *NotificationChannelList {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationChannelList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NotificationChannelList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationChannelSpec) DeepCopyInto(out *NotificationChannelSpec) {
+	*out = *in
+	if in.URLSecretKeyRef != nil {
+		in, out := &in.URLSecretKeyRef, &out.URLSecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TokenSecretKeyRef != nil {
+		in, out := &in.TokenSecretKeyRef, &out.TokenSecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Servers != nil {
+		in, out := &in.Servers, &out.Servers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Events != nil {
+		in, out := &in.Events, &out.Events
+		*out = make([]NotificationEvent, len(*in))
+		copy(*out, *in)
+	}
+	if in.Templates != nil {
+		in, out := &in.Templates, &out.Templates
+		*out = make(map[NotificationEvent]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationChannelSpec.
+func (in *NotificationChannelSpec) DeepCopy() *NotificationChannelSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationChannelSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationChannelStatus) DeepCopyInto(out *NotificationChannelStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationChannelStatus.
+func (in *NotificationChannelStatus) DeepCopy() *NotificationChannelStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationChannelStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
 	*out = *in
@@ -35,87 +426,203 @@ func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
 	out.Status = in.Status
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfig.
-func (in *OperatorConfig) DeepCopy() *OperatorConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfig.
+func (in *OperatorConfig) DeepCopy() *OperatorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperatorConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigList) DeepCopyInto(out *OperatorConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OperatorConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigList.
+func (in *OperatorConfigList) DeepCopy() *OperatorConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperatorConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigSpec) DeepCopyInto(out *OperatorConfigSpec) {
+	*out = *in
+	if in.ServersPV != nil {
+		in, out := &in.ServersPV, &out.ServersPV
+		*out = new(corev1.PersistentVolume)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = new(CapacitySpec)
+		**out = **in
+	}
+	if in.JavaImages != nil {
+		in, out := &in.JavaImages, &out.JavaImages
+		*out = make([]JavaImageRule, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigSpec.
+func (in *OperatorConfigSpec) DeepCopy() *OperatorConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigStatus) DeepCopyInto(out *OperatorConfigStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigStatus.
+func (in *OperatorConfigStatus) DeepCopy() *OperatorConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PlaytimeLimit) DeepCopyInto(out *PlaytimeLimit) {
+	*out = *in
+	if in.Players != nil {
+		in, out := &in.Players, &out.Players
+		*out = make(map[string]int32, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PlaytimeLimit.
+func (in *PlaytimeLimit) DeepCopy() *PlaytimeLimit {
 	if in == nil {
 		return nil
 	}
-	out := new(OperatorConfig)
+	out := new(PlaytimeLimit)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *OperatorConfig) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OperatorConfigList) DeepCopyInto(out *OperatorConfigList) {
+func (in *Plugin) DeepCopyInto(out *Plugin) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]OperatorConfig, len(*in))
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]ServerFile, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigList.
-func (in *OperatorConfigList) DeepCopy() *OperatorConfigList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Plugin.
+func (in *Plugin) DeepCopy() *Plugin {
 	if in == nil {
 		return nil
 	}
-	out := new(OperatorConfigList)
+	out := new(Plugin)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *OperatorConfigList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourcePack) DeepCopyInto(out *ResourcePack) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
 	}
-	return nil
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourcePack.
+func (in *ResourcePack) DeepCopy() *ResourcePack {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourcePack)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OperatorConfigSpec) DeepCopyInto(out *OperatorConfigSpec) {
+func (in *Schedule) DeepCopyInto(out *Schedule) {
 	*out = *in
-	if in.ServersPV != nil {
-		in, out := &in.ServersPV, &out.ServersPV
-		*out = new(corev1.PersistentVolume)
-		(*in).DeepCopyInto(*out)
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]ScheduleWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigSpec.
-func (in *OperatorConfigSpec) DeepCopy() *OperatorConfigSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Schedule.
+func (in *Schedule) DeepCopy() *Schedule {
 	if in == nil {
 		return nil
 	}
-	out := new(OperatorConfigSpec)
+	out := new(Schedule)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OperatorConfigStatus) DeepCopyInto(out *OperatorConfigStatus) {
+func (in *ScheduleWindow) DeepCopyInto(out *ScheduleWindow) {
 	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigStatus.
-func (in *OperatorConfigStatus) DeepCopy() *OperatorConfigStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleWindow.
+func (in *ScheduleWindow) DeepCopy() *ScheduleWindow {
 	if in == nil {
 		return nil
 	}
-	out := new(OperatorConfigStatus)
+	out := new(ScheduleWindow)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -147,6 +654,31 @@ func (in *Server) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerFile) DeepCopyInto(out *ServerFile) {
+	*out = *in
+	if in.ConfigMapKeyRef != nil {
+		in, out := &in.ConfigMapKeyRef, &out.ConfigMapKeyRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerFile.
+func (in *ServerFile) DeepCopy() *ServerFile {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerFile)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServerList) DeepCopyInto(out *ServerList) {
 	*out = *in
@@ -179,6 +711,21 @@ func (in *ServerList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerNetworkStatus) DeepCopyInto(out *ServerNetworkStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerNetworkStatus.
+func (in *ServerNetworkStatus) DeepCopy() *ServerNetworkStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerNetworkStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServerSpec) DeepCopyInto(out *ServerSpec) {
 	*out = *in
@@ -194,6 +741,75 @@ func (in *ServerSpec) DeepCopyInto(out *ServerSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.World != nil {
+		in, out := &in.World, &out.World
+		*out = new(WorldSpec)
+		**out = **in
+	}
+	if in.Worlds != nil {
+		in, out := &in.Worlds, &out.Worlds
+		*out = make([]NamedWorld, len(*in))
+		copy(*out, *in)
+	}
+	if in.StopGracePeriodSeconds != nil {
+		in, out := &in.StopGracePeriodSeconds, &out.StopGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(Schedule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JVM != nil {
+		in, out := &in.JVM, &out.JVM
+		*out = new(JVMSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make([]ServerFile, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Plugins != nil {
+		in, out := &in.Plugins, &out.Plugins
+		*out = make([]Plugin, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResourcePack != nil {
+		in, out := &in.ResourcePack, &out.ResourcePack
+		*out = new(ResourcePack)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Bedrock != nil {
+		in, out := &in.Bedrock, &out.Bedrock
+		*out = new(BedrockSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PlaytimeLimit != nil {
+		in, out := &in.PlaytimeLimit, &out.PlaytimeLimit
+		*out = new(PlaytimeLimit)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CrashPolicy != nil {
+		in, out := &in.CrashPolicy, &out.CrashPolicy
+		*out = new(CrashPolicy)
+		**out = **in
+	}
+	if in.PersistedPaths != nil {
+		in, out := &in.PersistedPaths, &out.PersistedPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.UpdatePolicy != nil {
+		in, out := &in.UpdatePolicy, &out.UpdatePolicy
+		*out = new(UpdatePolicy)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerSpec.
@@ -214,6 +830,55 @@ func (in *ServerStatus) DeepCopyInto(out *ServerStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Worlds != nil {
+		in, out := &in.Worlds, &out.Worlds
+		*out = make([]WorldStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastCrash != nil {
+		in, out := &in.LastCrash, &out.LastCrash
+		*out = new(CrashStatus)
+		**out = **in
+	}
+	if in.CrashTimes != nil {
+		in, out := &in.CrashTimes, &out.CrashTimes
+		*out = make([]int64, len(*in))
+		copy(*out, *in)
+	}
+	if in.PendingUpdate != nil {
+		in, out := &in.PendingUpdate, &out.PendingUpdate
+		*out = new(VersionChange)
+		**out = **in
+	}
+	if in.VersionHistory != nil {
+		in, out := &in.VersionHistory, &out.VersionHistory
+		*out = make([]VersionChange, len(*in))
+		copy(*out, *in)
+	}
+	if in.Network != nil {
+		in, out := &in.Network, &out.Network
+		*out = new(ServerNetworkStatus)
+		**out = **in
+	}
+	if in.Bedrock != nil {
+		in, out := &in.Bedrock, &out.Bedrock
+		*out = new(BedrockStatus)
+		**out = **in
+	}
+	if in.EffectiveSpec != nil {
+		in, out := &in.EffectiveSpec, &out.EffectiveSpec
+		*out = new(ServerSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerStatus.
@@ -225,3 +890,179 @@ func (in *ServerStatus) DeepCopy() *ServerStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerTemplate) DeepCopyInto(out *ServerTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerTemplate.
+func (in *ServerTemplate) DeepCopy() *ServerTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServerTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerTemplateList) DeepCopyInto(out *ServerTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ServerTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerTemplateList.
+func (in *ServerTemplateList) DeepCopy() *ServerTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServerTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerTemplateSpec) DeepCopyInto(out *ServerTemplateSpec) {
+	*out = *in
+	in.Defaults.DeepCopyInto(&out.Defaults)
+	if in.AllowedOverrides != nil {
+		in, out := &in.AllowedOverrides, &out.AllowedOverrides
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerTemplateSpec.
+func (in *ServerTemplateSpec) DeepCopy() *ServerTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerTemplateStatus) DeepCopyInto(out *ServerTemplateStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerTemplateStatus.
+func (in *ServerTemplateStatus) DeepCopy() *ServerTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdatePolicy) DeepCopyInto(out *UpdatePolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdatePolicy.
+func (in *UpdatePolicy) DeepCopy() *UpdatePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdatePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VersionChange) DeepCopyInto(out *VersionChange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VersionChange.
+func (in *VersionChange) DeepCopy() *VersionChange {
+	if in == nil {
+		return nil
+	}
+	out := new(VersionChange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorldGeneration) DeepCopyInto(out *WorldGeneration) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorldGeneration.
+func (in *WorldGeneration) DeepCopy() *WorldGeneration {
+	if in == nil {
+		return nil
+	}
+	out := new(WorldGeneration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorldSpec) DeepCopyInto(out *WorldSpec) {
+	*out = *in
+	out.WorldGeneration = in.WorldGeneration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorldSpec.
+func (in *WorldSpec) DeepCopy() *WorldSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorldSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorldStatus) DeepCopyInto(out *WorldStatus) {
+	*out = *in
+	if in.Generated != nil {
+		in, out := &in.Generated, &out.Generated
+		*out = new(WorldGeneration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorldStatus.
+func (in *WorldStatus) DeepCopy() *WorldStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(WorldStatus)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,136 @@
+// Package bedrock pings Bedrock Edition servers, like Geyser, with the RakNet unconnected ping
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/pkg/errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Port is the default port of Bedrock Edition servers, for UDP
+const Port = 19132
+
+// The RakNet packet IDs of the unconnected ping and its answer
+const (
+	idUnconnectedPing = 0x01
+	idUnconnectedPong = 0x1c
+)
+
+// attempts is the number of pings sent before giving up, as UDP packets get lost
+const attempts = 3
+
+// magic is the "offline message" marker of RakNet, that's in every unconnected packet
+var magic = []byte{0x00, 0xff, 0xff, 0x00, 0xfe, 0xfe, 0xfe, 0xfe, 0xfd, 0xfd, 0xfd, 0xfd, 0x12, 0x34, 0x56, 0x78}
+
+// Pong is the answer of a Bedrock Edition server to a ping
+type Pong struct {
+	// Edition is MCPE for Bedrock Edition, or MCEE for Education Edition
+	Edition string
+	// Motd is the first line of the server name
+	Motd string
+	// Protocol is the protocol version the server takes
+	Protocol int
+	// Version is the game version the server takes (e.g.: 1.20.80)
+	Version string
+	// Players is the number of players online
+	Players int
+	// MaxPlayers is the number of players the server takes
+	MaxPlayers int
+	// SubMotd is the second line of the server name, when the server sends it
+	SubMotd string
+	// GameMode is the default game mode, when the server sends it
+	GameMode string
+	// Latency is the time the server took to answer
+	Latency time.Duration
+}
+
+// Ping sends an unconnected ping to the server at addr (host:port), and returns its pong
+func Ping(addr string, timeout time.Duration) (*Pong, error) {
+	conn, err := net.DialTimeout("udp", addr, timeout)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting")
+	}
+	defer conn.Close()
+
+	guid := rand.Int63()
+	buffer := make([]byte, 1500)
+	for attempt := 0; attempt < attempts; attempt++ {
+		start := time.Now()
+		if _, err = conn.Write(pingPacket(start, guid)); err != nil {
+			return nil, errors.Wrap(err, "sending ping")
+		}
+		if err = conn.SetReadDeadline(start.Add(timeout / attempts)); err != nil {
+			return nil, errors.Wrap(err, "setting deadline")
+		}
+		var length int
+		length, err = conn.Read(buffer)
+		if err != nil {
+			continue
+		}
+		pong, err := parsePong(buffer[:length])
+		if err != nil {
+			return nil, err
+		}
+		pong.Latency = time.Since(start)
+		return pong, nil
+	}
+	return nil, errors.Wrapf(err, "no pong after %d pings", attempts)
+}
+
+// pingPacket renders an unconnected ping: the ID, the time in milliseconds, the magic and the GUID of the client
+func pingPacket(now time.Time, guid int64) []byte {
+	var packet bytes.Buffer
+	packet.WriteByte(idUnconnectedPing)
+	_ = binary.Write(&packet, binary.BigEndian, now.UnixNano()/int64(time.Millisecond))
+	packet.Write(magic)
+	_ = binary.Write(&packet, binary.BigEndian, guid)
+	return packet.Bytes()
+}
+
+// parsePong parses an unconnected pong: the ID, the time of the ping, the GUID of the server, the magic and the
+// server ID string, like MCPE;Motd;Protocol;Version;Players;MaxPlayers;GUID;SubMotd;GameMode;...
+func parsePong(packet []byte) (*Pong, error) {
+	const header = 1 + 8 + 8 + 16 + 2
+	if len(packet) < header || packet[0] != idUnconnectedPong {
+		return nil, errors.New("not an unconnected pong")
+	}
+	if !bytes.Equal(packet[17:33], magic) {
+		return nil, errors.New("pong has no RakNet magic")
+	}
+	length := int(binary.BigEndian.Uint16(packet[33:35]))
+	if len(packet) < header+length {
+		return nil, errors.New("pong is cut off")
+	}
+
+	fields := strings.Split(string(packet[header:header+length]), ";")
+	if len(fields) < 6 {
+		return nil, errors.Errorf("pong has %d fields, expected at least 6", len(fields))
+	}
+	pong := &Pong{
+		Edition: fields[0],
+		Motd:    fields[1],
+		Version: fields[3],
+	}
+	var err error
+	if pong.Protocol, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, errors.Wrap(err, "parsing protocol of pong")
+	}
+	if pong.Players, err = strconv.Atoi(fields[4]); err != nil {
+		return nil, errors.Wrap(err, "parsing players of pong")
+	}
+	if pong.MaxPlayers, err = strconv.Atoi(fields[5]); err != nil {
+		return nil, errors.Wrap(err, "parsing max players of pong")
+	}
+	if len(fields) > 7 {
+		pong.SubMotd = fields[7]
+	}
+	if len(fields) > 8 {
+		pong.GameMode = fields[8]
+	}
+	return pong, nil
+}
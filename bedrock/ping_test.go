@@ -0,0 +1,103 @@
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// pongPacket renders an unconnected pong with the server ID string, as Geyser sends it
+func pongPacket(serverID string) []byte {
+	var packet bytes.Buffer
+	packet.WriteByte(idUnconnectedPong)
+	_ = binary.Write(&packet, binary.BigEndian, int64(1700000000000))
+	_ = binary.Write(&packet, binary.BigEndian, int64(42))
+	packet.Write(magic)
+	_ = binary.Write(&packet, binary.BigEndian, uint16(len(serverID)))
+	packet.WriteString(serverID)
+	return packet.Bytes()
+}
+
+func TestParsePong(t *testing.T) {
+	tests := []struct {
+		name    string
+		packet  []byte
+		want    *Pong
+		wantErr bool
+	}{
+		{
+			name:   "geyser",
+			packet: pongPacket("MCPE;Geyser;671;1.20.80;2;20;42;Another Geyser server.;Survival;1;19132;19133;"),
+			want: &Pong{Edition: "MCPE", Motd: "Geyser", Protocol: 671, Version: "1.20.80", Players: 2, MaxPlayers: 20,
+				SubMotd: "Another Geyser server.", GameMode: "Survival"},
+		},
+		{
+			name:   "minimal",
+			packet: pongPacket("MCPE;Dedicated Server;390;1.14.60;0;10"),
+			want:   &Pong{Edition: "MCPE", Motd: "Dedicated Server", Protocol: 390, Version: "1.14.60", MaxPlayers: 10},
+		},
+		{
+			name:    "too few fields",
+			packet:  pongPacket("MCPE;Geyser;671"),
+			wantErr: true,
+		},
+		{
+			name:    "not a number",
+			packet:  pongPacket("MCPE;Geyser;671;1.20.80;many;20"),
+			wantErr: true,
+		},
+		{
+			name:    "cut off",
+			packet:  pongPacket("MCPE;Geyser;671;1.20.80;2;20")[:40],
+			wantErr: true,
+		},
+		{
+			name:    "not a pong",
+			packet:  pingPacket(time.Now(), 1),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePong(tt.packet)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePong() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePong() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPing(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		buffer := make([]byte, 1500)
+		length, addr, err := listener.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		if length != 33 || buffer[0] != idUnconnectedPing || !bytes.Equal(buffer[9:25], magic) {
+			t.Errorf("unexpected ping %x", buffer[:length])
+			return
+		}
+		_, _ = listener.WriteTo(pongPacket("MCPE;Geyser;671;1.20.80;1;20;42;Geyser;Survival"), addr)
+	}()
+
+	pong, err := Ping(listener.LocalAddr().String(), 3*time.Second)
+	if err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+	if pong.Version != "1.20.80" || pong.Players != 1 {
+		t.Errorf("Ping() = %+v", pong)
+	}
+}
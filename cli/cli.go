@@ -0,0 +1,194 @@
+// Package cli is the kubectl-minecraft command, that runs the common operations on Servers from the command line
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"os"
+	"os/signal"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// errUsage is returned by commands that got the wrong arguments
+var errUsage = errors.New("invalid arguments")
+
+// CLI runs the commands against a cluster
+type CLI struct {
+	Client    client.Client
+	Clientset kubernetes.Interface
+	Config    *rest.Config
+	Log       logr.Logger
+
+	// Namespace is the namespace of the Servers
+	Namespace string
+	// AllNamespaces lists the Servers of all namespaces
+	AllNamespaces bool
+
+	In  io.Reader
+	Out io.Writer
+}
+
+// command is a subcommand of the CLI
+type command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(c *CLI, ctx context.Context, args []string) error
+}
+
+// commands are the subcommands, in the order of the usage
+var commands = []command{
+	{Name: "list", Usage: "list", Description: "List the Servers with their phase and players", Run: (*CLI).list},
+	{Name: "start", Usage: "start <server>", Description: "Enable the Server", Run: (*CLI).start},
+	{Name: "stop", Usage: "stop <server>", Description: "Disable the Server, which stops it", Run: (*CLI).stop},
+	{Name: "console", Usage: "console <server> [command...]",
+		Description: "Run the command over RCON, or read commands from stdin", Run: (*CLI).console},
+	{Name: "logs", Usage: "logs [-f] [-tail lines] [-previous] <server>", Description: "Print the log of the Server",
+		Run: (*CLI).logs},
+	{Name: "backup", Usage: "backup [-world name] <server> <file.zip>", Description: "Export a world to a zip file",
+		Run: (*CLI).backup},
+	{Name: "restore", Usage: "restore [-world name] <server> <file.zip>",
+		Description: "Replace a world with a backup. The active world can only be restored while the Server is stopped",
+		Run:         (*CLI).restore},
+	{Name: "create", Usage: "create [-from servertemplate] [-template file.yaml] [-server-version version] [-memory MB] [-enabled] <server>",
+		Description: "Create a Server, from a ServerTemplate or the spec of the Server in the template file", Run: (*CLI).create},
+	{Name: "import-world", Usage: "import-world [-world name] [-mode seed|replace] <server> <file.zip>",
+		Description: "Import a world from a zip file, by default only when the world is empty", Run: (*CLI).importWorld},
+}
+
+// Main runs the CLI with the arguments, using the current kube context
+func Main(args []string) int {
+	flags := flag.NewFlagSet("kubectl-minecraft", flag.ExitOnError)
+	namespace := flags.String("namespace", "", "The namespace of the Servers. Defaults to the one of the kube context.")
+	flags.StringVar(namespace, "n", "", "Short for -namespace.")
+	allNamespaces := flags.Bool("A", false, "List the Servers of all namespaces.")
+	kubeconfig := flags.String("kubeconfig", "", "The kubeconfig to use. Defaults to $KUBECONFIG or ~/.kube/config.")
+	kubeContext := flags.String("context", "", "The kube context to use. Defaults to the current context.")
+	zapOptions := zap.Options{}
+	zapOptions.BindFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: kubectl minecraft [flags] <command> [arguments]\n\nCommands:\n")
+		for _, command := range commands {
+			fmt.Fprintf(flags.Output(), "  %s\n    \t%s\n", command.Usage, command.Description)
+		}
+		fmt.Fprintf(flags.Output(), "\nFlags:\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+		return 2
+	}
+	var selected *command
+	for index := range commands {
+		if commands[index].Name == flags.Arg(0) {
+			selected = &commands[index]
+		}
+	}
+	if selected == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", flags.Arg(0))
+		flags.Usage()
+		return 2
+	}
+
+	c, err := connect(*kubeconfig, *kubeContext, *namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 1
+	}
+	c.AllNamespaces = *allNamespaces
+	c.Log = zap.New(zap.UseFlagOptions(&zapOptions)).WithName("kubectl-minecraft")
+
+	// interrupting stops the command, which cleans up after itself (e.g.: helper Pods and port forwards)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	err = selected.Run(c, ctx, flags.Args()[1:])
+	if errors.Is(err, errUsage) {
+		fmt.Fprintf(os.Stderr, "Usage: kubectl minecraft %s\n", selected.Usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		return 1
+	}
+	return 0
+}
+
+// connect creates the clients for the kube context
+func connect(kubeconfig, kubeContext, namespace string) (*CLI, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules,
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+
+	config, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting kube config")
+	}
+	if namespace == "" {
+		namespace, _, err = clientConfig.Namespace()
+		if err != nil {
+			return nil, errors.Wrap(err, "getting namespace")
+		}
+	}
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := v1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	kClient, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, errors.Wrap(err, "creating kube client")
+	}
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating clientset")
+	}
+
+	return &CLI{
+		Client:    kClient,
+		Clientset: clientset,
+		Config:    config,
+		Namespace: namespace,
+		In:        os.Stdin,
+		Out:       os.Stdout,
+	}, nil
+}
+
+// parseFlags parses the flags of a command, and checks it got the number of arguments
+func parseFlags(flags *flag.FlagSet, args []string, arguments int) error {
+	flags.SetOutput(ioutil.Discard)
+	if err := flags.Parse(args); err != nil {
+		return errors.Wrap(errUsage, err.Error())
+	}
+	if flags.NArg() != arguments {
+		return errUsage
+	}
+	return nil
+}
+
+// getServer gets the named Server in the namespace of the CLI
+func (c *CLI) getServer(ctx context.Context, name string) (*v1.Server, error) {
+	var server v1.Server
+	if err := c.Client.Get(ctx, client.ObjectKey{Name: name, Namespace: c.Namespace}, &server); err != nil {
+		return nil, errors.Wrapf(err, "getting server %s", name)
+	}
+	return &server, nil
+}
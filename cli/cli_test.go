@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCLI returns a CLI for the games namespace, with fake clients that have the objects
+func newCLI(t *testing.T, objects ...client.Object) (*CLI, *bytes.Buffer) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := v1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	var pods []runtime.Object
+	for _, object := range objects {
+		if pod, ok := object.(*corev1.Pod); ok {
+			pods = append(pods, pod)
+		}
+	}
+	var out bytes.Buffer
+	return &CLI{
+		Client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Clientset: kubefake.NewSimpleClientset(pods...),
+		Log:       logr.Discard(),
+		Namespace: "games",
+		Out:       &out,
+	}, &out
+}
+
+// newServer returns a Server in the games namespace
+func newServer(name string, modify func(server *v1.Server)) *v1.Server {
+	server := &v1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "games"},
+		Spec:       v1.ServerSpec{ServerVersion: "paper-1.20.4", MaxMemory: 2048, InitMemory: 2048},
+	}
+	if modify != nil {
+		modify(server)
+	}
+	return server
+}
+
+func TestList(t *testing.T) {
+	c, out := newCLI(t,
+		newServer("skyblock", nil),
+		newServer("castle", func(server *v1.Server) {
+			server.Status.Phase = v1.ServerPhaseRunning
+			server.Status.ServerVersion = "paper-1.20.2"
+			server.Status.Players = []string{"alex", "steve"}
+		}),
+		newServer("elsewhere", func(server *v1.Server) { server.Namespace = "other" }),
+	)
+	if err := c.list(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "NAME      PHASE    VERSION       PLAYERS\n" +
+		"castle    Running  paper-1.20.2  2 (alex, steve)\n" +
+		"skyblock  -        paper-1.20.4  0\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	today := time.Now().UTC().Weekday().String()[:3]
+	var otherDays []string
+	for _, day := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+		if day != today {
+			otherDays = append(otherDays, day)
+		}
+	}
+	c, _ := newCLI(t,
+		newServer("castle", nil),
+		newServer("school-nights", func(server *v1.Server) {
+			server.Spec.Schedule = &v1.Schedule{Windows: []v1.ScheduleWindow{{Days: otherDays, Start: "00:00", End: "00:01"}}}
+		}),
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		run     func(c *CLI, ctx context.Context, args []string) error
+		server  string
+		enabled bool
+		err     string
+	}{
+		{name: "start", run: (*CLI).start, server: "castle", enabled: true},
+		{name: "stop", run: (*CLI).stop, server: "castle", enabled: false},
+		{name: "outside of schedule", run: (*CLI).start, server: "school-nights", err: "outside of its schedule"},
+		{name: "unknown server", run: (*CLI).start, server: "unknown", err: "not found"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.run(c, ctx, []string{test.server})
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error with %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var server v1.Server
+			if err := c.Client.Get(ctx, client.ObjectKey{Name: test.server, Namespace: "games"}, &server); err != nil {
+				t.Fatal(err)
+			}
+			if server.Spec.Enabled != test.enabled {
+				t.Errorf("expected enabled %v, got %v", test.enabled, server.Spec.Enabled)
+			}
+		})
+	}
+
+	if err := c.start(ctx, nil); !errors.Is(err, errUsage) {
+		t.Errorf("expected usage error without a server, got %v", err)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	template := filepath.Join(t.TempDir(), "server.yaml")
+	err := ioutil.WriteFile(template, []byte(`
+apiVersion: minecraft.hsmade.com/v1
+kind: Server
+metadata:
+  name: template
+  labels:
+    family: "yes"
+spec:
+  server-version: fabric-1.20.1
+  maxMemoryMB: 4096
+  persistedPaths:
+    - journeymap/
+status:
+  phase: Running
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, out := newCLI(t)
+	ctx := context.Background()
+
+	if err := c.create(ctx, []string{"-template", template, "-server-version", "fabric-1.20.4", "island"}); err != nil {
+		t.Fatal(err)
+	}
+	var server v1.Server
+	if err := c.Client.Get(ctx, client.ObjectKey{Name: "island", Namespace: "games"}, &server); err != nil {
+		t.Fatal(err)
+	}
+	if server.Spec.ServerVersion != "fabric-1.20.4" || server.Spec.MaxMemory != 4096 || server.Spec.InitMemory != 4096 {
+		t.Errorf("expected the flags on top of the template, got %+v", server.Spec)
+	}
+	if len(server.Spec.PersistedPaths) != 1 || server.Labels["family"] != "yes" || server.Spec.Enabled {
+		t.Errorf("expected the spec and labels of the template, disabled, got %+v", server)
+	}
+	if server.Status.Phase != "" {
+		t.Errorf("expected no status, got %+v", server.Status)
+	}
+	if !strings.Contains(out.String(), "games/island created") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	if err := c.create(ctx, []string{"bare"}); err == nil {
+		t.Error("expected an error without a server version")
+	}
+}
+
+func TestLogs(t *testing.T) {
+	c, out := newCLI(t,
+		newServer("castle", nil),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "castle-abc", Namespace: "games",
+				Labels: map[string]string{"app": "minecraft-operator-server-castle"}},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		},
+	)
+	if err := c.logs(context.Background(), []string{"-tail", "10", "castle"}); err != nil {
+		t.Fatal(err)
+	}
+	// the fake clientset returns the same log for every Pod
+	if out.String() != "fake logs" {
+		t.Errorf("unexpected logs %q", out.String())
+	}
+
+	if err := c.logs(context.Background(), []string{"skyblock"}); err == nil {
+		t.Error("expected an error for an unknown server")
+	}
+}
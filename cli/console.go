@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/rcon"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	"net/http"
+	"os"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+)
+
+// serverContainer is the container of the Server Pod that runs the game
+const serverContainer = "minecraft"
+
+// serverPod returns the Pod of the Server: the running one, or else the newest
+func (c *CLI) serverPod(ctx context.Context, server *v1.Server) (*corev1.Pod, error) {
+	var podList corev1.PodList
+	err := c.Client.List(ctx, &podList, client.InNamespace(server.Namespace),
+		client.MatchingLabels{"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name)})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pods")
+	}
+	if len(podList.Items) == 0 {
+		return nil, errors.Errorf("server %s has no pod, is it enabled?", server.Name)
+	}
+
+	pods := podList.Items
+	sort.Slice(pods, func(i, j int) bool {
+		iRunning, jRunning := pods[i].Status.Phase == corev1.PodRunning, pods[j].Status.Phase == corev1.PodRunning
+		if iRunning != jRunning {
+			return iRunning
+		}
+		return pods[j].CreationTimestamp.Before(&pods[i].CreationTimestamp)
+	})
+	return &pods[0], nil
+}
+
+// logs prints the log of the Server, and keeps printing it with -f
+func (c *CLI) logs(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("logs", flag.ContinueOnError)
+	follow := flags.Bool("f", false, "Keep printing the log as the Server writes it.")
+	tail := flags.Int64("tail", -1, "The number of lines from the end of the log to print. Defaults to all.")
+	previous := flags.Bool("previous", false, "Print the log of the previous run, like after a crash.")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	server, err := c.getServer(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	pod, err := c.serverPod(ctx, server)
+	if err != nil {
+		return err
+	}
+
+	options := &corev1.PodLogOptions{Container: serverContainer, Follow: *follow, Previous: *previous}
+	if *tail >= 0 {
+		options.TailLines = tail
+	}
+	stream, err := c.Clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, options).Stream(ctx)
+	if err != nil {
+		return errors.Wrap(err, "getting logs")
+	}
+	defer stream.Close()
+
+	_, err = io.Copy(c.Out, stream)
+	if err != nil && ctx.Err() == nil {
+		return errors.Wrap(err, "reading logs")
+	}
+	return nil
+}
+
+// console runs the command on the Server over RCON, or the commands read from stdin, one per line
+func (c *CLI) console(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("console", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	if err := flags.Parse(args); err != nil || flags.NArg() < 1 {
+		return errUsage
+	}
+	server, err := c.getServer(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	pod, err := c.serverPod(ctx, server)
+	if err != nil {
+		return err
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return errors.Errorf("server %s isn't running", server.Name)
+	}
+	password, err := rcon.Password(ctx, c.Client, server)
+	if err != nil {
+		return err
+	}
+
+	// RCON isn't exposed, so it's reached through a port forward to the Pod
+	addr, stop, err := c.portForward(ctx, pod, rcon.Port)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	if flags.NArg() > 1 {
+		return c.rconCommand(addr, password, strings.Join(flags.Args()[1:], " "))
+	}
+
+	fmt.Fprintf(os.Stderr, "connected to %s, end with Ctrl-D\n", server.Name)
+	lines := bufio.NewScanner(c.In)
+	for {
+		fmt.Fprint(os.Stderr, "> ")
+		if !lines.Scan() {
+			fmt.Fprintln(os.Stderr)
+			return lines.Err()
+		}
+		command := strings.TrimPrefix(strings.TrimSpace(lines.Text()), "/")
+		if command == "" {
+			continue
+		}
+		if err := c.rconCommand(addr, password, command); err != nil {
+			return err
+		}
+	}
+}
+
+// rconCommand runs the command, and prints the response
+func (c *CLI) rconCommand(addr, password, command string) error {
+	responses, err := rcon.CommandAt(addr, password, command)
+	if err != nil {
+		return err
+	}
+	for _, response := range responses {
+		if response != "" {
+			fmt.Fprintln(c.Out, response)
+		}
+	}
+	return nil
+}
+
+// portForward forwards a local port to the port of the Pod, and returns its address and a function to stop it
+func (c *CLI) portForward(ctx context.Context, pod *corev1.Pod, port int) (string, func(), error) {
+	transport, upgrader, err := spdy.RoundTripperFor(c.Config)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "creating port forward transport")
+	}
+	url := c.Clientset.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stopChannel := make(chan struct{})
+	readyChannel := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)},
+		stopChannel, readyChannel, ioutil.Discard, os.Stderr)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "creating port forward")
+	}
+	failed := make(chan error, 1)
+	go func() {
+		failed <- forwarder.ForwardPorts()
+	}()
+	stop := func() { close(stopChannel) }
+
+	select {
+	case <-readyChannel:
+	case err := <-failed:
+		return "", nil, errors.Wrap(err, "forwarding port")
+	case <-ctx.Done():
+		stop()
+		return "", nil, ctx.Err()
+	}
+	ports, err := forwarder.GetPorts()
+	if err != nil || len(ports) == 0 {
+		stop()
+		return "", nil, errors.Wrap(err, "getting forwarded port")
+	}
+	return fmt.Sprintf("127.0.0.1:%d", ports[0].Local), stop, nil
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultMemoryMB is the memory of created Servers, when neither the template nor the flags set it
+const defaultMemoryMB = 2048
+
+// list prints the Servers with their phase, version and players
+func (c *CLI) list(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	var options []client.ListOption
+	if !c.AllNamespaces {
+		options = append(options, client.InNamespace(c.Namespace))
+	}
+	var servers v1.ServerList
+	if err := c.Client.List(ctx, &servers, options...); err != nil {
+		return errors.Wrap(err, "listing servers")
+	}
+	sort.Slice(servers.Items, func(i, j int) bool {
+		if servers.Items[i].Namespace != servers.Items[j].Namespace {
+			return servers.Items[i].Namespace < servers.Items[j].Namespace
+		}
+		return servers.Items[i].Name < servers.Items[j].Name
+	})
+
+	writer := tabwriter.NewWriter(c.Out, 0, 4, 2, ' ', 0)
+	if c.AllNamespaces {
+		fmt.Fprint(writer, "NAMESPACE\t")
+	}
+	fmt.Fprintln(writer, "NAME\tPHASE\tVERSION\tPLAYERS")
+	for _, server := range servers.Items {
+		if c.AllNamespaces {
+			fmt.Fprintf(writer, "%s\t", server.Namespace)
+		}
+		phase := string(server.Status.Phase)
+		if phase == "" {
+			phase = "-"
+		}
+		version := server.Status.ServerVersion
+		if version == "" {
+			version = helpers.EffectiveServer(&server).Spec.ServerVersion
+		}
+		players := fmt.Sprintf("%d", len(server.Status.Players))
+		if len(server.Status.Players) > 0 {
+			players += " (" + strings.Join(server.Status.Players, ", ") + ")"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", server.Name, phase, version, players)
+	}
+	return writer.Flush()
+}
+
+// start enables the Server
+func (c *CLI) start(ctx context.Context, args []string) error {
+	return c.setEnabled(ctx, "start", args, true)
+}
+
+// stop disables the Server, after which the operator stops it
+func (c *CLI) stop(ctx context.Context, args []string) error {
+	return c.setEnabled(ctx, "stop", args, false)
+}
+
+// setEnabled enables or disables the Server, refusing to start it outside of its schedule like the web UI
+func (c *CLI) setEnabled(ctx context.Context, name string, args []string, enabled bool) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+	server, err := c.getServer(ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		allowed, err := helpers.ScheduleAllowsRunning(helpers.EffectiveServer(server), time.Now())
+		if err != nil {
+			return errors.Wrap(err, "checking schedule")
+		}
+		if !allowed {
+			return errors.Errorf("server %s is outside of its schedule", server.Name)
+		}
+	}
+
+	patch := client.MergeFrom(server.DeepCopy())
+	server.Spec.Enabled = enabled
+	if err := c.Client.Patch(ctx, server, patch); err != nil {
+		return errors.Wrapf(err, "updating server %s", server.Name)
+	}
+	if enabled {
+		fmt.Fprintf(c.Out, "server %s enabled\n", server.Name)
+	} else {
+		fmt.Fprintf(c.Out, "server %s disabled, it stops after warning the players\n", server.Name)
+	}
+	return nil
+}
+
+// create creates a Server, with the spec of the Server in the template file and the flags on top
+func (c *CLI) create(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("create", flag.ContinueOnError)
+	template := flags.String("template", "", "A Server manifest (YAML) to take the spec from.")
+	from := flags.String("from", "", "A ServerTemplate in the namespace, that fills in the spec.")
+	serverVersion := flags.String("server-version", "", "The server version to run (e.g.: paper-1.20.4).")
+	memory := flags.Int("memory", 0, "The memory of the Server, in MB.")
+	enabled := flags.Bool("enabled", false, "Start the Server right away.")
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	server := &v1.Server{}
+	if *template != "" {
+		var err error
+		server, err = readTemplate(*template)
+		if err != nil {
+			return err
+		}
+	}
+	server.ObjectMeta = metav1.ObjectMeta{
+		Name:        flags.Arg(0),
+		Namespace:   c.Namespace,
+		Labels:      server.Labels,
+		Annotations: server.Annotations,
+	}
+	server.Status = v1.ServerStatus{}
+
+	if *serverVersion != "" {
+		server.Spec.ServerVersion = *serverVersion
+	}
+	if *memory > 0 {
+		server.Spec.MaxMemory = int32(*memory)
+		server.Spec.InitMemory = int32(*memory)
+	}
+	if *from != "" {
+		// the ServerTemplate fills in the rest
+		var serverTemplate v1.ServerTemplate
+		if err := c.Client.Get(ctx, client.ObjectKey{Name: *from, Namespace: c.Namespace}, &serverTemplate); err != nil {
+			return errors.Wrapf(err, "getting server template %s", *from)
+		}
+		server.Spec.TemplateRef = serverTemplate.Name
+	} else {
+		if server.Spec.ServerVersion == "" {
+			return errors.New("the server version has to be in the template or the -server-version flag")
+		}
+		if server.Spec.MaxMemory == 0 {
+			server.Spec.MaxMemory = defaultMemoryMB
+		}
+		if server.Spec.InitMemory == 0 {
+			server.Spec.InitMemory = server.Spec.MaxMemory
+		}
+	}
+	server.Spec.Enabled = *enabled
+
+	if err := c.Client.Create(ctx, server); err != nil {
+		return errors.Wrapf(err, "creating server %s", server.Name)
+	}
+	if server.Spec.TemplateRef != "" {
+		fmt.Fprintf(c.Out, "server %s/%s created from %s\n", server.Namespace, server.Name, server.Spec.TemplateRef)
+		return nil
+	}
+	fmt.Fprintf(c.Out, "server %s/%s created, running %s\n", server.Namespace, server.Name, server.Spec.ServerVersion)
+	return nil
+}
+
+// readTemplate reads the Server manifest in the file
+func readTemplate(file string) (*v1.Server, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading template")
+	}
+	var server v1.Server
+	if err := yaml.UnmarshalStrict(content, &server); err != nil {
+		return nil, errors.Wrap(err, "parsing template")
+	}
+	if server.Kind != "" && server.Kind != "Server" {
+		return nil, errors.Errorf("template is a %s, not a Server", server.Kind)
+	}
+	return &server, nil
+}
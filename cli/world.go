@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"archive/zip"
+	"context"
+	"flag"
+	"fmt"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/rcon"
+	"github.com/hsmade/minecraft-operator/transfer"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"os"
+)
+
+// transfer returns a Transfer with the helper image of the OperatorConfig for the namespace
+func (c *CLI) transfer(ctx context.Context, namespace string) (*transfer.Transfer, error) {
+	var configs v1.OperatorConfigList
+	if err := c.Client.List(ctx, &configs); err != nil {
+		return nil, errors.Wrap(err, "listing OperatorConfigs")
+	}
+	helperImage := "busybox"
+	if config := helpers.OperatorConfigFor(configs.Items, namespace); config != nil && config.Spec.InitContainerImage != "" {
+		helperImage = config.Spec.InitContainerImage
+	}
+	return &transfer.Transfer{
+		Client:      c.Client,
+		Config:      c.Config,
+		Log:         c.Log.WithName("transfer"),
+		HelperImage: helperImage,
+		RCON:        c.rcon,
+	}, nil
+}
+
+// rcon runs the commands on the Server over a port forward, as the CLI runs outside of the cluster
+func (c *CLI) rcon(ctx context.Context, server *v1.Server, commands ...string) ([]string, error) {
+	pod, err := c.serverPod(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil, errors.Errorf("server %s isn't running", server.Name)
+	}
+	password, err := rcon.Password(ctx, c.Client, server)
+	if err != nil {
+		return nil, err
+	}
+	addr, stop, err := c.portForward(ctx, pod, rcon.Port)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+	return rcon.CommandAt(addr, password, commands...)
+}
+
+// worldFlags parses the flags of the world commands, and returns the Server and the world
+func (c *CLI) worldFlags(ctx context.Context, flags *flag.FlagSet, args []string) (*v1.Server, string, error) {
+	world := flags.String("world", "", "The world. Defaults to the active world of the Server.")
+	if err := parseFlags(flags, args, 2); err != nil {
+		return nil, "", err
+	}
+	server, err := c.getServer(ctx, flags.Arg(0))
+	if err != nil {
+		return nil, "", err
+	}
+	// the worlds can come from the template
+	server = helpers.EffectiveServer(server)
+	if *world == "" {
+		*world = helpers.RunningWorldName(server)
+	}
+	return server, *world, nil
+}
+
+// backup exports a world of the Server to a zip file
+func (c *CLI) backup(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ContinueOnError)
+	server, world, err := c.worldFlags(ctx, flags, args)
+	if err != nil {
+		return err
+	}
+	t, err := c.transfer(ctx, server.Namespace)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(flags.Arg(1))
+	if err != nil {
+		return errors.Wrap(err, "creating backup")
+	}
+	err = t.ExportWorld(ctx, server, world, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// an incomplete backup is worse than none
+		os.Remove(flags.Arg(1))
+		return errors.Wrapf(err, "exporting world %s", world)
+	}
+	fmt.Fprintf(c.Out, "world %s of server %s saved to %s\n", world, server.Name, flags.Arg(1))
+	return nil
+}
+
+// restore replaces a world of the Server with a backup
+func (c *CLI) restore(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	server, world, err := c.worldFlags(ctx, flags, args)
+	if err != nil {
+		return err
+	}
+	return c.importArchive(ctx, server, world, flags.Arg(1), transfer.ImportReplace)
+}
+
+// importWorld imports a world into the Server, by default only when it has none yet
+func (c *CLI) importWorld(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import-world", flag.ContinueOnError)
+	mode := flags.String("mode", string(transfer.ImportSeed),
+		"seed only imports when the world is empty, replace removes the world first.")
+	server, world, err := c.worldFlags(ctx, flags, args)
+	if err != nil {
+		return err
+	}
+	return c.importArchive(ctx, server, world, flags.Arg(1), transfer.ImportMode(*mode))
+}
+
+// importArchive puts the world in the zip file on the volume of the Server
+func (c *CLI) importArchive(ctx context.Context, server *v1.Server, world, file string, mode transfer.ImportMode) error {
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return errors.Wrap(err, "opening world archive")
+	}
+	defer archive.Close()
+
+	t, err := c.transfer(ctx, server.Namespace)
+	if err != nil {
+		return err
+	}
+	if err := t.ImportWorld(ctx, server, world, &archive.Reader, mode); err != nil {
+		return errors.Wrapf(err, "importing world %s", world)
+	}
+	fmt.Fprintf(c.Out, "world %s of server %s imported from %s\n", world, server.Name, file)
+	return nil
+}
@@ -0,0 +1,264 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/bedrock"
+	"github.com/hsmade/minecraft-operator/initializer"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path"
+	"time"
+)
+
+// ConditionBedrockReachable tells if Bedrock players can reach the Server through Geyser
+const ConditionBedrockReachable = "BedrockReachable"
+
+// defaultGeyserImage runs Geyser Standalone in the Sidecar mode, which needs Java 17 or newer
+const defaultGeyserImage = "eclipse-temurin:21-jre"
+
+// geyserContainer is the name of the container that runs Geyser in the Sidecar mode
+const geyserContainer = "geyser"
+
+// geyserDirectory is where Geyser Standalone runs from in the Sidecar mode, relative to the Server's directory
+const geyserDirectory = "geyser"
+
+// bedrockPingTimeout is how long UpdateStatus waits for Geyser to answer the Bedrock ping
+const bedrockPingTimeout = time.Second
+
+// geyserConfigTemplate is the config.yml of Geyser. Geyser adds the other settings with their defaults.
+const geyserConfigTemplate = `# Generated by the minecraft-operator
+bedrock:
+  port: %d
+  clone-remote-port: false
+remote:
+  address: %s
+  port: 25565
+  auth-type: %s
+`
+
+// bedrockEnabled tells if the Server has Bedrock support enabled
+func bedrockEnabled(server *v1.Server) bool {
+	return server.Spec.Bedrock != nil && server.Spec.Bedrock.Enabled
+}
+
+// bedrockMode returns how Geyser runs for the Server: the Mode from the spec, or Plugin when the server type loads
+// plugins and Sidecar when it doesn't
+func bedrockMode(server *v1.Server) v1.BedrockMode {
+	if server.Spec.Bedrock.Mode != "" {
+		return server.Spec.Bedrock.Mode
+	}
+	if supported, _ := supportsPlugins(server); supported {
+		return v1.BedrockPlugin
+	}
+	return v1.BedrockSidecar
+}
+
+// bedrockJarPlugin returns the jar as a plugin with the name, to check and install it like the other plugins
+func bedrockJarPlugin(name string, jar v1.BedrockJar) v1.Plugin {
+	return v1.Plugin{Name: name, Jar: jar.Jar, URL: jar.URL, SHA256: jar.SHA256}
+}
+
+// validateBedrock checks the Bedrock settings of the Server against its server type
+func validateBedrock(server *v1.Server) error {
+	if server.Status.Network != nil {
+		// Geyser would join the players without the forwarding the Server takes, and its hostPort past the proxy
+		return errors.Errorf("the Server is behind Network %s, Geyser has to run on its proxy", server.Status.Network.Name)
+	}
+	config := ConfigFor(server.Namespace)
+	if err := validatePlugin(config, bedrockJarPlugin("Geyser", server.Spec.Bedrock.Geyser)); err != nil {
+		return err
+	}
+	switch bedrockMode(server) {
+	case v1.BedrockPlugin:
+		if supported, flavor := supportsPlugins(server); !supported {
+			return errors.Errorf("server type %q doesn't load plugins, Geyser needs the Sidecar mode", flavor)
+		}
+		if server.Spec.Bedrock.Floodgate != nil {
+			return validatePlugin(config, bedrockJarPlugin("Floodgate", *server.Spec.Bedrock.Floodgate))
+		}
+	case v1.BedrockSidecar:
+		if server.Spec.Bedrock.Floodgate != nil {
+			return errors.New("Floodgate only runs in the Plugin mode")
+		}
+	}
+	return nil
+}
+
+// bedrockActive tells if Geyser is installed for the Server: Bedrock is enabled and its settings are valid
+func bedrockActive(server *v1.Server) bool {
+	return bedrockEnabled(server) && validateBedrock(server) == nil
+}
+
+// bedrockPlugins returns Geyser and Floodgate as plugins, when they run as plugins for the Server
+func bedrockPlugins(server *v1.Server) []v1.Plugin {
+	if !bedrockActive(server) || bedrockMode(server) != v1.BedrockPlugin {
+		return nil
+	}
+	plugins := []v1.Plugin{bedrockJarPlugin("Geyser-Spigot", server.Spec.Bedrock.Geyser)}
+	if server.Spec.Bedrock.Floodgate != nil {
+		plugins = append(plugins, bedrockJarPlugin("floodgate", *server.Spec.Bedrock.Floodgate))
+	}
+	return plugins
+}
+
+// geyserAuthType returns how Geyser logs Bedrock players in: through Floodgate, as offline players when the Server
+// doesn't authenticate players, or with a Java Edition account
+func geyserAuthType(server *v1.Server) string {
+	switch {
+	case server.Spec.Bedrock.Floodgate != nil:
+		return "floodgate"
+	case server.Spec.Properties["online-mode"] == "false":
+		return "offline"
+	}
+	return "online"
+}
+
+// bedrockFiles returns the config of Geyser, with its path relative to the Server's directory
+func bedrockFiles(server *v1.Server) []v1.ServerFile {
+	if !bedrockActive(server) {
+		return nil
+	}
+	configPath, address := path.Join("plugins", "Geyser-Spigot", "config.yml"), "auto"
+	if bedrockMode(server) == v1.BedrockSidecar {
+		// Geyser Standalone reaches the server within the Pod
+		configPath, address = path.Join(geyserDirectory, "config.yml"), "127.0.0.1"
+	}
+	return []v1.ServerFile{{
+		Path:    configPath,
+		Content: fmt.Sprintf(geyserConfigTemplate, bedrock.Port, address, geyserAuthType(server)),
+	}}
+}
+
+// bedrockDirectory returns the directory with the Geyser Standalone jar for the init manifest, or nil when Geyser
+// doesn't run in the Sidecar mode
+func bedrockDirectory(server *v1.Server) *initializer.Directory {
+	if !bedrockActive(server) || bedrockMode(server) != v1.BedrockSidecar {
+		return nil
+	}
+	artifact := initializer.Artifact{
+		Name:   "Geyser.jar",
+		SHA256: server.Spec.Bedrock.Geyser.SHA256,
+	}
+	if server.Spec.Bedrock.Geyser.URL != "" {
+		artifact.URL = server.Spec.Bedrock.Geyser.URL
+	} else {
+		artifact.Source = path.Join("/jars/plugins", server.Spec.Bedrock.Geyser.Jar)
+	}
+	return &initializer.Directory{
+		Path:        geyserDirectory,
+		Artifacts:   []initializer.Artifact{artifact},
+		PruneSuffix: ".jar",
+	}
+}
+
+// bedrockContainerPort returns the UDP port Bedrock players connect to
+func bedrockContainerPort(server *v1.Server) corev1.ContainerPort {
+	return corev1.ContainerPort{
+		Name:          "udp-bedrock",
+		ContainerPort: bedrock.Port,
+		HostPort:      server.Spec.Bedrock.HostPort,
+		Protocol:      corev1.ProtocolUDP,
+	}
+}
+
+// renderGeyserContainer renders the container that runs Geyser Standalone in the Sidecar mode
+func renderGeyserContainer(server *v1.Server) corev1.Container {
+	image := server.Spec.Bedrock.Image
+	if image == "" {
+		image = defaultGeyserImage
+	}
+	return corev1.Container{
+		Name:  geyserContainer,
+		Image: image,
+		Ports: []corev1.ContainerPort{bedrockContainerPort(server)},
+		// Geyser needs little memory, without a limit the JVM takes a quarter of the node
+		Command:    []string{"java", "-Xmx256M", "-jar", "Geyser.jar", "--nogui"},
+		WorkingDir: path.Join("/data", geyserDirectory),
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "data",
+				MountPath: "/data",
+			},
+		},
+	}
+}
+
+// UpdateBedrockStatus pings Geyser like a Bedrock client, to report if Bedrock players can join. It only pings once
+// the Java ping found the Server running, so it goes after that.
+// It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) UpdateBedrockStatus(log logr.Logger, server *v1.Server) {
+	log.V(loglevels.Verbose).Info("updating bedrock status")
+
+	if !bedrockEnabled(server) {
+		server.Status.Bedrock = nil
+		meta.RemoveStatusCondition(&server.Status.Conditions, ConditionBedrockReachable)
+		return
+	}
+
+	if err := validateBedrock(server); err != nil {
+		log.V(loglevels.Info).Info("geyser can't be installed", "error", err)
+		server.Status.Bedrock = nil
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionBedrockReachable,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "InvalidConfig",
+			Message:            fmt.Sprintf("%s, Geyser isn't installed", err),
+		})
+		return
+	}
+
+	previous := server.Status.Bedrock
+	server.Status.Bedrock = &v1.BedrockStatus{}
+	if previous != nil {
+		server.Status.Bedrock.LastPong = previous.LastPong
+	}
+	if !server.Spec.Enabled {
+		meta.RemoveStatusCondition(&server.Status.Conditions, ConditionBedrockReachable)
+		return
+	}
+	if !server.Status.Running {
+		// Geyser can't get players in before the Java server is up, and the pings would only hold up the status
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionBedrockReachable,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "NotRunning",
+			Message:            "The Server isn't running yet",
+		})
+		return
+	}
+
+	addr := fmt.Sprintf("%s.%s.svc.cluster.local:%d", server.Name, server.Namespace, bedrock.Port)
+	log.V(loglevels.Flow).Info("pinging geyser", "addr", addr)
+	pong, err := bedrock.Ping(addr, bedrockPingTimeout)
+	if err != nil {
+		log.V(loglevels.Info).Info("could not ping geyser", "error", err)
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionBedrockReachable,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "NoPong",
+			Message:            fmt.Sprintf("Geyser didn't answer the Bedrock ping: %s", err),
+		})
+		return
+	}
+	log.V(loglevels.Trace).Info("geyser ping result", "pong", *pong)
+
+	server.Status.Bedrock.Reachable = true
+	server.Status.Bedrock.Version = pong.Version
+	server.Status.Bedrock.Players = int32(pong.Players)
+	server.Status.Bedrock.LastPong = time.Now().Unix()
+	meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+		Type:               ConditionBedrockReachable,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: server.Generation,
+		Reason:             "Pong",
+		Message:            fmt.Sprintf("Bedrock %s players can join on UDP port %d", pong.Version, bedrock.Port),
+	})
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"sync"
+	"time"
+)
+
+// serverMemoryMB returns the memory a Server takes from the memory budget, which is the memory limit of its container
+func serverMemoryMB(server *v1.Server) int64 {
+	return containerMemoryMB(server)
+}
+
+// scaledUp tells if the Deployment of a Server without a phase runs it, the phase tells for the others
+func (r *ServerReconciler) scaledUp(ctx context.Context, server *v1.Server) (bool, error) {
+	if server.Status.Phase != "" {
+		return false, nil
+	}
+	var deployment appsv1.Deployment
+	err := r.Get(ctx, client.ObjectKey{Name: server.Name, Namespace: server.Namespace}, &deployment)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "getting Deployment")
+	}
+	return deployment.Spec.Replicas != nil && *deployment.Spec.Replicas > 0, nil
+}
+
+// admission serialises the admission of Servers, so Servers reconciled at the same time can't take the same room
+var admission sync.Mutex
+
+// ReconcileCapacity decides if an enabled Server may start, when the operator limits the Servers that can run
+// at the same time. When there's no room, the Server is queued, and the longest idle Server might be stopped
+// to make room, depending on the preemption policy.
+func (r *ServerReconciler) ReconcileCapacity(ctx context.Context, log logr.Logger, server *v1.Server) error {
+	log.V(loglevels.Verbose).Info("start reconciling of capacity")
+	config := ConfigFor(server.Namespace)
+	capacity := config.Capacity
+
+	if !server.Spec.Enabled {
+		if server.Status.Phase == v1.ServerPhaseQueued {
+			log.V(loglevels.Info).Info("server got disabled, removing it from the queue")
+			server.Status.Phase = v1.ServerPhaseStopped
+		}
+		server.Status.QueuedAt = 0
+		return nil
+	}
+
+	scaledUp, err := r.scaledUp(ctx, server)
+	if err != nil {
+		return err
+	}
+	if server.Status.Phase != v1.ServerPhaseQueued && helpers.UsesCapacity(server, scaledUp) {
+		log.V(loglevels.Flow).Info("server already admitted")
+		return nil
+	}
+
+	if capacity.MaxRunningServers == 0 && capacity.MemoryBudgetMB == 0 {
+		log.V(loglevels.Flow).Info("no capacity limits, admitting server")
+		server.Status.Phase = v1.ServerPhaseStarting
+		server.Status.QueuedAt = 0
+		return nil
+	}
+
+	admission.Lock()
+	defer admission.Unlock()
+
+	// the cache can miss the admission of a Server reconciled just before, so this reads from the API server
+	log.V(loglevels.Flow).Info("listing Servers to check capacity")
+	var servers v1.ServerList
+	err = r.apiReader().List(ctx, &servers)
+	if err != nil {
+		return errors.Wrap(err, "listing Servers")
+	}
+
+	var running []*v1.Server
+	var queue []*v1.Server
+	var runningCount int32
+	var runningMemory int64
+	stopping := false
+	for index := range servers.Items {
+		other := helpers.EffectiveServer(&servers.Items[index])
+		if other.Namespace == server.Namespace && other.Name == server.Name {
+			continue
+		}
+		// the capacity is shared by the Servers that use the same OperatorConfig
+		if !sameConfig(ConfigFor(other.Namespace), config) {
+			continue
+		}
+		if other.Spec.Enabled && other.Status.Phase == v1.ServerPhaseQueued {
+			queue = append(queue, other)
+			continue
+		}
+		scaledUp, err := r.scaledUp(ctx, other)
+		if err != nil {
+			return err
+		}
+		if helpers.UsesCapacity(other, scaledUp) {
+			runningCount++
+			runningMemory += serverMemoryMB(other)
+			running = append(running, other)
+			if other.Status.Phase == v1.ServerPhaseStopping {
+				stopping = true
+			}
+		}
+	}
+	log.V(loglevels.Trace).Info("capacity in use", "servers", runningCount, "memoryMB", runningMemory)
+
+	if server.Status.QueuedAt == 0 {
+		server.Status.QueuedAt = time.Now().Unix()
+	}
+	queue = append(queue, server)
+	sort.Slice(queue, func(i, j int) bool { return helpers.QueuedBefore(queue[i], queue[j]) })
+	first := queue[0] == server
+
+	fits := (capacity.MaxRunningServers == 0 || runningCount+1 <= capacity.MaxRunningServers) &&
+		(capacity.MemoryBudgetMB == 0 || runningMemory+serverMemoryMB(server) <= capacity.MemoryBudgetMB)
+	if fits && first {
+		log.V(loglevels.Info).Info("room to run, admitting server")
+		server.Status.Phase = v1.ServerPhaseStarting
+		server.Status.QueuedAt = 0
+		// stored right away, so the next Server that's admitted counts this one
+		if err := r.storeStatus(ctx, server); err != nil {
+			return errors.Wrap(err, "storing admission")
+		}
+		return nil
+	}
+
+	if server.Status.Phase != v1.ServerPhaseQueued {
+		log.V(loglevels.Info).Info("no room to run, queueing server")
+	}
+	server.Status.Phase = v1.ServerPhaseQueued
+
+	if !first || fits {
+		log.V(loglevels.Flow).Info("waiting for servers that were queued first")
+		return nil
+	}
+	if stopping {
+		// wait for the room that's being made, instead of stopping yet another Server
+		log.V(loglevels.Flow).Info("waiting for a server to stop")
+		return nil
+	}
+
+	log.V(loglevels.Flow).Info("looking for a server to preempt", "policy", capacity.Preemption)
+	victim := helpers.PreemptionVictim(capacity.Preemption, server, running)
+	if victim == nil {
+		log.V(loglevels.Flow).Info("no server to preempt")
+		return nil
+	}
+
+	log.V(loglevels.Info).Info("stopping idle server to make room", "preempted", victim.Namespace+"/"+victim.Name)
+	err = r.setEnabled(ctx, victim, false)
+	if err != nil {
+		return errors.Wrap(err, "disabling preempted server")
+	}
+	r.normal(victim, EventPreempted, "Stopped to make room for Server %s/%s", server.Namespace, server.Name)
+	return nil
+}
@@ -18,9 +18,6 @@ import (
 //go:embed assets/server.properties.tmpl
 var serverPropertiesTemplate string
 
-//go:embed assets/init.sh.tmpl
-var initScriptTemplate string
-
 // ReconcileConfigMap make sure the config map exists as it should.
 func (r *ServerReconciler) ReconcileConfigMap(ctx context.Context, log logr.Logger, server *v1.Server) error {
 	log.V(loglevels.Verbose).Info("start reconciling of configMap")
@@ -48,6 +45,7 @@ func (r *ServerReconciler) ReconcileConfigMap(ctx context.Context, log logr.Logg
 			return errors.Wrap(err, "creating configMap")
 		}
 		log.V(loglevels.Flow).Info("created configMap ok")
+		r.normal(server, EventCreated, "Created ConfigMap %s", configMap.Name)
 		return nil
 	}
 
@@ -62,7 +60,9 @@ func (r *ServerReconciler) ReconcileConfigMap(ctx context.Context, log logr.Logg
 				if err != nil {
 					// non-critical error
 					log.V(loglevels.Info).Error(err, "failed to delete configMap", "namespace", cm.Namespace, "name", cm.Name)
+					continue
 				}
+				r.normal(server, EventDeleted, "Deleted extra ConfigMap %s", cm.Name)
 			}
 		}
 	}
@@ -76,6 +76,7 @@ func (r *ServerReconciler) ReconcileConfigMap(ctx context.Context, log logr.Logg
 		if err != nil {
 			return errors.Wrap(err, "replacing configMap")
 		}
+		r.normal(server, EventUpdated, "Updated ConfigMap %s", configMap.Name)
 	}
 	log.V(loglevels.Flow).Info("configMap is already up to date")
 
@@ -88,7 +89,14 @@ func (r *ServerReconciler) RenderConfigMap(log logr.Logger, server *v1.Server) (
 
 	log.V(loglevels.Flow).Info("rendering server.properties")
 	server.Spec.Properties["motd"] = server.Name
-	err, serverProperties := helpers.RenderTemplate(serverPropertiesTemplate, map[string]map[string]string{"Properties": server.Spec.Properties})
+	world, resourcePack, network := worldProperties(server), resourcePackProperties(server), networkProperties(server)
+	err, serverProperties := helpers.RenderTemplate(serverPropertiesTemplate, map[string]map[string]string{
+		// the keys the operator manages replace the ones in the spec, instead of showing up twice
+		"Properties":   helpers.WithoutKeys(server.Spec.Properties, world, resourcePack, network),
+		"World":        world,
+		"ResourcePack": resourcePack,
+		"Network":      network,
+	})
 	if err != nil {
 		return nil, errors.Wrap(err, "rendering server.properties")
 	}
@@ -96,14 +104,13 @@ func (r *ServerReconciler) RenderConfigMap(log logr.Logger, server *v1.Server) (
 		serverProperties, "template", serverPropertiesTemplate, "data", server.Spec.Properties)
 	log.V(loglevels.Flow).Info("rendered server.properties ok")
 
-	log.V(loglevels.Flow).Info("rendering init.sh")
-	err, initScript := helpers.RenderTemplate(initScriptTemplate, server.Spec)
+	log.V(loglevels.Flow).Info("rendering init manifest")
+	manifest, err := renderInitManifest(server, serverProperties)
 	if err != nil {
-		return nil, errors.Wrap(err, "rendering init.sh")
+		return nil, errors.Wrap(err, "rendering init manifest")
 	}
-	log.V(loglevels.Trace).Info("rendered init.sh", "result",
-		initScript, "template", initScriptTemplate, "data", server.Spec)
-	log.V(loglevels.Flow).Info("rendered init.sh ok")
+	log.V(loglevels.Trace).Info("rendered init manifest", "result", manifest)
+	log.V(loglevels.Flow).Info("rendered init manifest ok")
 
 	log.V(loglevels.Flow).Info("rendering configMap")
 	configMap := &corev1.ConfigMap{
@@ -115,7 +122,7 @@ func (r *ServerReconciler) RenderConfigMap(log logr.Logger, server *v1.Server) (
 		},
 		Data: map[string]string{
 			"server.properties": serverProperties,
-			"init.sh":           initScript,
+			"manifest.json":     manifest,
 		},
 	}
 	log.V(loglevels.Flow).Info("rendered configMap ok")
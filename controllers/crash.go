@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/logparse"
+	"github.com/hsmade/minecraft-operator/notify"
+	"github.com/hsmade/minecraft-operator/transfer"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"path"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strings"
+	"time"
+)
+
+// ConditionCrashBackoff tells if the Server is kept from running, because it crashed too often
+const ConditionCrashBackoff = "CrashBackoff"
+
+// EventCrashed is the reason of the Events for crashes of the Server
+const EventCrashed = "Crashed"
+
+const (
+	// CrashLogKey is the key in the crash ConfigMap with the end of the log of the crashed Server
+	CrashLogKey = "log"
+	// CrashReportKey is the key in the crash ConfigMap with the crash report
+	CrashReportKey = "crash-report"
+)
+
+const (
+	// crashLogLines is the number of lines of the log that are kept of a crash
+	crashLogLines = 300
+	// maxCrashReportBytes keeps the crash report within what a ConfigMap holds, next to the log
+	maxCrashReportBytes = 512 * 1024
+	// serverContainer is the name of the container that runs the Server
+	serverContainer = "minecraft"
+)
+
+// CrashConfigMapName returns the name of the ConfigMap with the log and crash report of the last crash of the Server
+func CrashConfigMapName(server *v1.Server) string {
+	return server.Name + "-crash"
+}
+
+// crashPolicy returns the crash policy of the Server, with its defaults
+func crashPolicy(server *v1.Server) v1.CrashPolicy {
+	policy := v1.CrashPolicy{MaxCrashes: 3, WindowMinutes: 10, Action: v1.CrashActionBackoff, BackoffMinutes: 10}
+	if server.Spec.CrashPolicy == nil {
+		return policy
+	}
+	if server.Spec.CrashPolicy.MaxCrashes > 0 {
+		policy.MaxCrashes = server.Spec.CrashPolicy.MaxCrashes
+	}
+	if server.Spec.CrashPolicy.WindowMinutes > 0 {
+		policy.WindowMinutes = server.Spec.CrashPolicy.WindowMinutes
+	}
+	if server.Spec.CrashPolicy.Action != "" {
+		policy.Action = server.Spec.CrashPolicy.Action
+	}
+	if server.Spec.CrashPolicy.BackoffMinutes > 0 {
+		policy.BackoffMinutes = server.Spec.CrashPolicy.BackoffMinutes
+	}
+	return policy
+}
+
+// inCrashBackoff tells if the Server is kept from running, because it crashed too often
+func inCrashBackoff(server *v1.Server) bool {
+	return server.Status.CrashBackoffUntil > time.Now().Unix()
+}
+
+// ReconcileCrashes detects crashes of the Server, keeps the end of its log and its crash report, and keeps a Server
+// that crashes too often from running.
+// It changes server.Status, storing it is up to the caller. The Disable action patches the spec, keeping the status.
+func (r *ServerReconciler) ReconcileCrashes(ctx context.Context, log logr.Logger, server *v1.Server) error {
+	log.V(loglevels.Verbose).Info("start reconciling of crashes")
+	now := time.Now()
+
+	if server.Status.CrashBackoffUntil != 0 && !inCrashBackoff(server) {
+		log.V(loglevels.Info).Info("crash backoff ended, starting server again")
+		server.Status.CrashBackoffUntil = 0
+		meta.RemoveStatusCondition(&server.Status.Conditions, ConditionCrashBackoff)
+	}
+
+	if !server.Spec.Enabled || server.Status.Phase == v1.ServerPhaseStopping {
+		// stopping kills the Server, which isn't a crash
+		return nil
+	}
+
+	log.V(loglevels.Flow).Info("fetching pod for crashes")
+	var pods corev1.PodList
+	err := r.List(ctx, &pods, client.InNamespace(server.Namespace),
+		client.MatchingLabels{"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name)})
+	if err != nil {
+		return errors.Wrap(err, "listing pods")
+	}
+
+	for index := range pods.Items {
+		pod := &pods.Items[index]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != serverContainer {
+				continue
+			}
+			if err := r.recordCrash(ctx, log, server, pod, status, now); err != nil {
+				return err
+			}
+			r.captureCrashReport(ctx, log, server, pod, status)
+		}
+	}
+	return nil
+}
+
+// recordCrash records the last exit of the container, when it's a crash that hasn't been recorded yet
+func (r *ServerReconciler) recordCrash(ctx context.Context, log logr.Logger, server *v1.Server, pod *corev1.Pod, status corev1.ContainerStatus, now time.Time) error {
+	terminated := status.State.Terminated
+	previous := false
+	if terminated == nil {
+		// it's been restarted
+		terminated = status.LastTerminationState.Terminated
+		previous = true
+	}
+	if terminated == nil || terminated.ExitCode == 0 {
+		return nil
+	}
+	finished := terminated.FinishedAt.Unix()
+	if server.Status.LastCrash != nil && finished <= server.Status.LastCrash.Time {
+		log.V(loglevels.Flow).Info("crash already recorded", "finished", finished)
+		return nil
+	}
+
+	log.V(loglevels.Info).Info("server crashed", "exitCode", terminated.ExitCode, "reason", terminated.Reason)
+	crash := &v1.CrashStatus{
+		Time:     finished,
+		ExitCode: terminated.ExitCode,
+		Reason:   terminated.Reason,
+	}
+
+	// the log tells what went wrong, and where the crash report is
+	tail, err := r.crashLog(ctx, pod, previous)
+	if err != nil {
+		log.V(loglevels.Info).Error(err, "failed to get the log of the crashed server")
+	}
+	var modError string
+	_ = logparse.Parse(strings.NewReader(tail), func(event logparse.Event) {
+		switch {
+		case event.Type == logparse.ModError && modError == "":
+			modError = event.Message
+		case event.Type == logparse.CrashReport && event.Path != "":
+			crash.ReportPath = path.Join("/data/crash-reports", path.Base(event.Path))
+		}
+	})
+	switch {
+	case modError != "":
+		crash.Message = "mods failed to load: " + modError
+	case terminated.Reason == "OOMKilled":
+		crash.Message = "ran out of memory, raise maxMemory or the memory overhead of the JVM"
+	case crash.ReportPath != "":
+		crash.Message = "the server crashed, see " + crash.ReportPath
+	default:
+		crash.Message = fmt.Sprintf("the server exited with code %d", terminated.ExitCode)
+	}
+	if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+		crash.Message += ", and is in CrashLoopBackOff"
+	}
+	server.Status.LastCrash = crash
+
+	err = r.storeCrashData(ctx, log, server, map[string]string{CrashLogKey: tail, CrashReportKey: ""})
+	if err != nil {
+		return errors.Wrap(err, "storing crash log")
+	}
+
+	r.warning(server, EventCrashed, "The server crashed: %s", crash.Message)
+	r.Notify(ctx, log, server, notify.Event{Type: v1.NotificationCrash, Message: crash.Message})
+
+	// count the crashes within the window of the policy
+	policy := crashPolicy(server)
+	window := now.Add(-time.Duration(policy.WindowMinutes) * time.Minute).Unix()
+	crashTimes := []int64{finished}
+	for _, crashTime := range server.Status.CrashTimes {
+		if crashTime >= window {
+			crashTimes = append(crashTimes, crashTime)
+		}
+	}
+	server.Status.CrashTimes = crashTimes
+	if int32(len(crashTimes)) < policy.MaxCrashes {
+		return nil
+	}
+	server.Status.CrashTimes = nil
+
+	if policy.Action == v1.CrashActionDisable {
+		log.V(loglevels.Info).Info("server crashed too often, disabling it", "crashes", len(crashTimes))
+		if err := r.setEnabled(ctx, server, false); err != nil {
+			return errors.Wrap(err, "disabling server")
+		}
+		r.warning(server, EventCrashed, "Disabled the Server, after %d crashes in %d minutes", len(crashTimes), policy.WindowMinutes)
+		return nil
+	}
+
+	log.V(loglevels.Info).Info("server crashed too often, backing off", "crashes", len(crashTimes), "minutes", policy.BackoffMinutes)
+	server.Status.CrashBackoffUntil = now.Add(time.Duration(policy.BackoffMinutes) * time.Minute).Unix()
+	message := fmt.Sprintf("The Server crashed %d times in %d minutes, it's kept from running until %s",
+		len(crashTimes), policy.WindowMinutes, time.Unix(server.Status.CrashBackoffUntil, 0).UTC().Format(time.RFC3339))
+	meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+		Type:               ConditionCrashBackoff,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: server.Generation,
+		Reason:             "CrashedTooOften",
+		Message:            message,
+	})
+	r.warning(server, EventCrashed, message)
+	return nil
+}
+
+// crashLog returns the end of the log of the server container, of its previous run when it has been restarted
+func (r *ServerReconciler) crashLog(ctx context.Context, pod *corev1.Pod, previous bool) (string, error) {
+	if r.RestConfig == nil {
+		return "", errors.New("no API config to read logs with")
+	}
+	clientSet, err := kubernetes.NewForConfig(r.RestConfig)
+	if err != nil {
+		return "", errors.Wrap(err, "create client")
+	}
+
+	lines := int64(crashLogLines)
+	content, err := clientSet.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: serverContainer,
+		Previous:  previous,
+		TailLines: &lines,
+	}).DoRaw(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "reading logs")
+	}
+	return string(content), nil
+}
+
+// captureCrashReport copies the crash report of the last crash into the crash ConfigMap, once the server runs again.
+// The report stays on the volume of the Server, so it's tried again on the next reconcile when it fails.
+func (r *ServerReconciler) captureCrashReport(ctx context.Context, log logr.Logger, server *v1.Server, pod *corev1.Pod, status corev1.ContainerStatus) {
+	crash := server.Status.LastCrash
+	if crash == nil || crash.ReportPath == "" || crash.ReportCaptured || status.State.Running == nil || r.RestConfig == nil {
+		return
+	}
+
+	log.V(loglevels.Flow).Info("capturing crash report", "path", crash.ReportPath)
+	t := transfer.Transfer{Client: r.Client, Config: r.RestConfig, Log: log}
+	var report bytes.Buffer
+	err := t.Exec(ctx, pod, serverContainer, []string{"head", "-c", fmt.Sprint(maxCrashReportBytes), crash.ReportPath}, nil, &report)
+	if err != nil {
+		log.V(loglevels.Info).Error(err, "failed to read crash report, trying again later")
+		return
+	}
+	if err := r.storeCrashData(ctx, log, server, map[string]string{CrashReportKey: report.String()}); err != nil {
+		log.V(loglevels.Info).Error(err, "failed to store crash report, trying again later")
+		return
+	}
+	crash.ReportCaptured = true
+}
+
+// storeCrashData sets the keys of the crash ConfigMap, creating it when needed
+func (r *ServerReconciler) storeCrashData(ctx context.Context, log logr.Logger, server *v1.Server, data map[string]string) error {
+	var configMap corev1.ConfigMap
+	err := r.Get(ctx, client.ObjectKey{Name: CrashConfigMapName(server), Namespace: server.Namespace}, &configMap)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "getting crash configMap")
+	}
+	found := err == nil
+
+	if !found {
+		configMap = corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels: map[string]string{
+					"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name),
+				},
+				Name:      CrashConfigMapName(server),
+				Namespace: server.Namespace,
+			},
+		}
+		// not as controller, as the reconciler takes the ConfigMaps it controls for its config
+		log.V(loglevels.Verbose).Info("setting owner reference for crash configMap")
+		if err := controllerutil.SetOwnerReference(server, &configMap, r.Scheme); err != nil {
+			return errors.Wrap(err, "setting owner reference")
+		}
+	}
+	if configMap.Data == nil {
+		configMap.Data = make(map[string]string)
+	}
+	for key, value := range data {
+		configMap.Data[key] = value
+	}
+
+	if found {
+		log.V(loglevels.Flow).Info("updating crash configMap")
+		return r.Client.Update(ctx, &configMap)
+	}
+	log.V(loglevels.Info).Info("crash configMap not found, creating new one")
+	return r.Client.Create(ctx, &configMap)
+}
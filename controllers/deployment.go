@@ -2,17 +2,21 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
 	_ "embed"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"github.com/go-logr/logr"
 	minecraftv1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
 	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/rcon"
 	"github.com/mitchellh/hashstructure/v2"
 	"github.com/pkg/errors"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"reflect"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -46,6 +50,7 @@ func (r *ServerReconciler) ReconcileDeployment(ctx context.Context, log logr.Log
 			return errors.Wrap(err, "creating Deployment")
 		}
 		log.V(loglevels.Flow).Info("created Deployment ok")
+		r.normal(server, EventCreated, "Created Deployment %s", deployment.Name)
 		return nil
 	}
 
@@ -60,54 +65,142 @@ func (r *ServerReconciler) ReconcileDeployment(ctx context.Context, log logr.Log
 				if err != nil {
 					// non-critical error
 					log.V(loglevels.Info).Error(err, "failed to delete Deployment", "namespace", p.Namespace, "name", p.Name)
+					continue
 				}
+				r.normal(server, EventDeleted, "Deleted extra Deployment %s", p.Name)
 			}
 		}
 	}
 
 	// patch the Deployment, if needed
-	// only check for things we can influence
+	// it's compared by the hash of what was rendered, as the API server adds defaults to what's stored
 	log.V(loglevels.Flow).Info("comparing Deployment data with the rendered data")
-	if !reflect.DeepEqual(deployment.Spec.Template.Spec.Containers[0].Ports[0].HostPort, DeploymentList.Items[0].Spec.Template.Spec.Containers[0].Ports[0].HostPort) ||
-		!reflect.DeepEqual(deployment.Spec.Template.Spec.Containers[0].Image, DeploymentList.Items[0].Spec.Template.Spec.Containers[0].Image) ||
-		!reflect.DeepEqual(deployment.Spec.Replicas, DeploymentList.Items[0].Spec.Replicas) ||
-		!reflect.DeepEqual(deployment.ObjectMeta.Annotations["configHash"], DeploymentList.Items[0].ObjectMeta.Annotations["configHash"]) ||
-		!reflect.DeepEqual(deployment.Spec.Template.Spec.Containers, DeploymentList.Items[0].Spec.Template.Spec.Containers) {
+	found := &DeploymentList.Items[0]
+	if found.Annotations[renderedHashAnnotation] != deployment.Annotations[renderedHashAnnotation] {
 		log.V(loglevels.Info).Info("replacing Deployment")
-		log.V(loglevels.Trace).Info("replacing Deployment", "rendered", deployment.Spec, "found", DeploymentList.Items[0].Spec)
-		DeploymentList.Items[0].Spec = deployment.Spec
-		DeploymentList.Items[0].ObjectMeta.Annotations["configHash"] = deployment.ObjectMeta.Annotations["configHash"]
-		err = r.Client.Update(ctx, &DeploymentList.Items[0])
+		log.V(loglevels.Trace).Info("replacing Deployment", "rendered", deployment.Spec, "found", found.Spec)
+		found.Spec = deployment.Spec
+		if found.Annotations == nil {
+			found.Annotations = make(map[string]string)
+		}
+		found.Annotations[renderedHashAnnotation] = deployment.Annotations[renderedHashAnnotation]
+		err = r.Client.Update(ctx, found)
 		if err != nil {
 			return errors.Wrap(err, "replacing Deployment")
 		}
+		r.normal(server, EventUpdated, "Updated Deployment %s", deployment.Name)
 	}
 	log.V(loglevels.Flow).Info("Deployment is already up to date")
 
 	return nil
 }
 
+// runtimeSpec returns a copy of the Server spec without the fields the Pod doesn't run with, which the operator acts
+// on itself. Disabling a Server mustn't restart its Pod, that's up to the stop sequence.
+func runtimeSpec(server *minecraftv1.Server) minecraftv1.ServerSpec {
+	spec := server.Spec
+	spec.TemplateRef = ""
+	spec.Enabled = false
+	spec.IdleTimeoutSeconds = 0
+	spec.StopGracePeriodSeconds = nil
+	spec.Schedule = nil
+	spec.Priority = 0
+	spec.PlaytimeLimit = nil
+	spec.CrashPolicy = nil
+	spec.UpdatePolicy = nil
+	spec.Rollback = ""
+	return spec
+}
+
+// specHash returns the hash of the Server spec, its Files, resource pack and Network, that the Pods are annotated
+// with to restart them on changes
+func specHash(log logr.Logger, server *minecraftv1.Server) string {
+	// the content of the Files and the resource pack isn't in the spec, so their hashes are added
+	configHash, err := hashstructure.Hash(struct {
+		Spec             minecraftv1.ServerSpec
+		FilesHash        string
+		ResourcePackSHA1 string
+		Network          *minecraftv1.ServerNetworkStatus
+	}{runtimeSpec(server), server.Status.FilesHash, server.Status.ResourcePackSHA1, server.Status.Network}, hashstructure.FormatV2, nil)
+	if err != nil {
+		log.V(loglevels.Info).Info("failed to generate hash from spec", "error", err)
+		configHash = 0
+	}
+	return fmt.Sprintf("%d", configHash)
+}
+
+// renderedHash returns the hash of a rendered object, to compare it with what was rendered before
+func renderedHash(rendered interface{}) (string, error) {
+	content, err := json.Marshal(rendered)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// runningCurrentSpec tells if there's a running Pod for the Server that was started with the current spec
+func (r *ServerReconciler) runningCurrentSpec(ctx context.Context, log logr.Logger, server *minecraftv1.Server) (bool, error) {
+	var podList corev1.PodList
+	err := r.List(ctx, &podList, client.InNamespace(server.Namespace),
+		client.MatchingLabels{"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name)})
+	if err != nil {
+		return false, errors.Wrap(err, "listing pods")
+	}
+
+	hash := specHash(log, server)
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil && pod.Annotations["checksum/config"] == hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // RenderDeployment renders the Deployment used for the Server
 func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1.Server) (*appsv1.Deployment, error) {
 	log.V(loglevels.Verbose).Info("rendering Deployment")
 
-	if Config.ServerPV == nil || Config.ModJarsPVC == nil || Config.ServerJarsPVC == nil {
+	config := ConfigFor(server.Namespace)
+	if config.ServerPV == nil || config.ModJarsPVC == nil || config.ServerJarsPVC == nil {
+		r.warning(server, EventOperatorConfigMissing, "The OperatorConfig isn't loaded, or has no jar PVCs in the namespace, can't render the Deployment")
 		return nil, errors.New("Operator config isn't initialised (yet)") // FIXME
 	}
 
 	var executeBit int32 = 0o777
+	var rootUser int64 = 0
+	runAsNonRoot := false
 	var replicas int32 = 0
-	if server.Spec.Enabled {
+	if (server.Spec.Enabled && server.Status.Phase != minecraftv1.ServerPhaseQueued) || server.Status.Phase == minecraftv1.ServerPhaseStopping {
+		log.V(loglevels.Flow).Info("server enabled or still stopping, scaling up")
 		replicas = 1
 	}
+	if server.Spec.Enabled && inCrashBackoff(server) {
+		log.V(loglevels.Flow).Info("server crashed too often, scaling down until the backoff ends")
+		replicas = 0
+	}
+	if server.Spec.Enabled && bypassesProxy(server) {
+		log.V(loglevels.Flow).Info("server behind a network sets a hostPort, not starting it")
+		replicas = 0
+	}
+	// the stop sequence has already had its time when the Pod gets removed, so the preStop hook only has to wait for java
+	terminationGracePeriod := int64(shutdownTimeoutSeconds)
 
-	log.V(loglevels.Flow).Info("generating hash of spec")
-	configHash, err := hashstructure.Hash(server.Spec, hashstructure.FormatV2, nil)
+	log.V(loglevels.Flow).Info("picking image")
+	image, err := serverImage(server)
 	if err != nil {
-		log.V(loglevels.Info).Info("failed to generate hash from spec", "error", err)
-		configHash = 0
+		return nil, err
+	}
+
+	log.V(loglevels.Flow).Info("rendering container resources")
+	resources, err := containerResources(server)
+	if err != nil {
+		return nil, errors.Wrap(err, "rendering container resources")
 	}
 
+	log.V(loglevels.Flow).Info("generating hash of spec")
+	configHash := specHash(log, server)
+
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Labels: map[string]string{
@@ -136,12 +229,13 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 						// FIXME: need more
 					},
 					Annotations: map[string]string{
-						"checksum/config": fmt.Sprintf("%d", configHash),
+						"checksum/config": configHash,
 					},
 					Name:      server.Name,
 					Namespace: server.Namespace,
 				},
 				Spec: corev1.PodSpec{
+					TerminationGracePeriodSeconds: &terminationGracePeriod,
 					Volumes: []corev1.Volume{
 						{
 							Name: "world",
@@ -155,7 +249,7 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 							Name: "server-jars",
 							VolumeSource: corev1.VolumeSource{
 								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: Config.ServerJarsPVC.Name,
+									ClaimName: config.ServerJarsPVC.Name,
 								},
 							},
 						},
@@ -163,7 +257,7 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 							Name: "mod-jars",
 							VolumeSource: corev1.VolumeSource{
 								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: Config.ModJarsPVC.Name,
+									ClaimName: config.ModJarsPVC.Name,
 								},
 							},
 						},
@@ -184,12 +278,41 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 								EmptyDir: &corev1.EmptyDirVolumeSource{},
 							},
 						},
+						{
+							Name: "files",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: filesSecretName(server),
+								},
+							},
+						},
 					},
 					InitContainers: []corev1.Container{
 						{
 							Name:    "init",
-							Image:   Config.InitContainerImage,
-							Command: []string{"/init.sh"},
+							Image:   config.InitImage,
+							Command: []string{"/manager", "init", "--manifest", "/config/manifest.json"},
+							// the init binary writes the reason it failed here
+							TerminationMessagePath:   corev1.TerminationMessagePathDefault,
+							TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+							SecurityContext: &corev1.SecurityContext{
+								// the operator image runs as nonroot, but the volumes are owned by root
+								RunAsUser:    &rootUser,
+								RunAsNonRoot: &runAsNonRoot,
+							},
+							Env: []corev1.EnvVar{
+								{
+									Name: "RCON_PASSWORD",
+									ValueFrom: &corev1.EnvVarSource{
+										SecretKeyRef: &corev1.SecretKeySelector{
+											LocalObjectReference: corev1.LocalObjectReference{
+												Name: rcon.SecretName(server),
+											},
+											Key: rcon.PasswordKey,
+										},
+									},
+								},
+							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "data",
@@ -208,9 +331,12 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 									MountPath: "/jars/mods",
 								},
 								{
-									Name:      "config",
-									SubPath:   "init.sh",
-									MountPath: "/init.sh",
+									Name:      "world",
+									MountPath: "/worlds",
+								},
+								{
+									Name:      "files",
+									MountPath: filesMountPath,
 								},
 							},
 						},
@@ -218,12 +344,18 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 					Containers: []corev1.Container{
 						{
 							Name:  "minecraft",
-							Image: server.Spec.Image,
-							Ports: []corev1.ContainerPort{{
-								Name:          "tcp-minecraft",
-								ContainerPort: 25565,
-								HostPort:      server.Spec.HostPort,
-							}},
+							Image: image,
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "tcp-minecraft",
+									ContainerPort: 25565,
+									HostPort:      serverHostPort(server),
+								},
+								{
+									Name:          "tcp-rcon",
+									ContainerPort: rcon.Port,
+								},
+							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      "data",
@@ -232,7 +364,19 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 								{
 									Name:      "world",
 									MountPath: "/data/world",
-									SubPath:   server.Name,
+									SubPath:   helpers.WorldPath(server, activeWorld(server)),
+								},
+								{
+									// the crash reports outlive the Pod, to capture them after a crash
+									Name:      "world",
+									MountPath: "/data/crash-reports",
+									SubPath:   helpers.CrashReportsPath(server),
+								},
+								{
+									// the files the game changes, which the init container links into /data
+									Name:      "world",
+									MountPath: stateMountPath,
+									SubPath:   helpers.StatePath(server),
 								},
 								{
 									Name:      "config",
@@ -244,6 +388,13 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 							},
 							TTY:        true,
 							WorkingDir: "/data",
+							Lifecycle: &corev1.Lifecycle{
+								PreStop: &corev1.Handler{
+									Exec: &corev1.ExecAction{
+										Command: []string{"sh", "-c", preStopScript},
+									},
+								},
+							},
 							Env: []corev1.EnvVar{
 								{
 									Name: "XMX", Value: fmt.Sprintf("%dM", server.Spec.MaxMemory),
@@ -251,7 +402,11 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 								{
 									Name: "XMS", Value: fmt.Sprintf("%dM", server.Spec.InitMemory),
 								},
+								{
+									Name: "JAVA_OPTS", Value: javaOpts(server),
+								},
 							},
+							Resources: resources,
 						},
 					},
 				},
@@ -259,6 +414,38 @@ func (r *ServerReconciler) RenderDeployment(log logr.Logger, server *minecraftv1
 		},
 	}
 
+	if config.PluginJarsPVC != nil {
+		log.V(loglevels.Flow).Info("adding plugin jars volume")
+		deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, corev1.Volume{
+			Name: "plugin-jars",
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: config.PluginJarsPVC.Name,
+				},
+			},
+		})
+		initContainer := &deployment.Spec.Template.Spec.InitContainers[0]
+		initContainer.VolumeMounts = append(initContainer.VolumeMounts, corev1.VolumeMount{
+			Name:      "plugin-jars",
+			MountPath: "/jars/plugins",
+		})
+	}
+
+	if bedrockActive(server) {
+		log.V(loglevels.Flow).Info("adding bedrock port", "mode", bedrockMode(server))
+		podSpec := &deployment.Spec.Template.Spec
+		if bedrockMode(server) == minecraftv1.BedrockSidecar {
+			podSpec.Containers = append(podSpec.Containers, renderGeyserContainer(server))
+		} else {
+			podSpec.Containers[0].Ports = append(podSpec.Containers[0].Ports, bedrockContainerPort(server))
+		}
+	}
+
+	hash, err := renderedHash(deployment.Spec)
+	if err != nil {
+		return nil, errors.Wrap(err, "hashing Deployment")
+	}
+	deployment.Annotations[renderedHashAnnotation] = hash
 	log.V(loglevels.Flow).Info("rendered Deployment ok")
 
 	log.V(loglevels.Verbose).Info("setting controller reference for Deployment")
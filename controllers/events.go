@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// The reasons of the Events on Servers
+const (
+	EventCreated               = "Created"
+	EventUpdated               = "Updated"
+	EventDeleted               = "Deleted"
+	EventIdleTimeout           = "IdleTimeout"
+	EventPreempted             = "Preempted"
+	EventScheduleStart         = "ScheduleStart"
+	EventScheduleStop          = "ScheduleStop"
+	EventPingFailed            = "PingFailed"
+	EventOperatorConfigMissing = "OperatorConfigMissing"
+)
+
+// pingFailureThreshold is the number of pings in a row an enabled Server has to miss, before that's reported
+const pingFailureThreshold = 10
+
+// event records an Event on the Server, when the reconciler has a recorder
+func (r *ServerReconciler) event(server *v1.Server, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(server, eventType, reason, messageFmt, args...)
+}
+
+// warning records a Warning Event on the Server
+func (r *ServerReconciler) warning(server *v1.Server, reason, messageFmt string, args ...interface{}) {
+	r.event(server, corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// normal records a Normal Event on the Server
+func (r *ServerReconciler) normal(server *v1.Server, reason, messageFmt string, args ...interface{}) {
+	r.event(server, corev1.EventTypeNormal, reason, messageFmt, args...)
+}
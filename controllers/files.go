@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// filesMountPath is where the files Secret is mounted in the init container
+const filesMountPath = "/files"
+
+// filesSecretName returns the name of the Secret holding the resolved Files of the Server
+func filesSecretName(server *v1.Server) string {
+	return server.Name + "-files"
+}
+
+// fileKey returns the key of the file in the files Secret
+func fileKey(index int) string {
+	return fmt.Sprintf("file-%d", index)
+}
+
+// fileSource returns the path of the file in the init container
+func fileSource(index int) string {
+	return path.Join(filesMountPath, fileKey(index))
+}
+
+// resolveFileContent returns the content of a file, from the spec or the referenced ConfigMap or Secret,
+// rendered as a template when asked
+func (r *ServerReconciler) resolveFileContent(ctx context.Context, server *v1.Server, file v1.ServerFile) ([]byte, error) {
+	content := []byte(file.Content)
+
+	switch {
+	case file.ConfigMapKeyRef != nil:
+		var configMap corev1.ConfigMap
+		err := r.Get(ctx, client.ObjectKey{Name: file.ConfigMapKeyRef.Name, Namespace: server.Namespace}, &configMap)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting configMap %s", file.ConfigMapKeyRef.Name)
+		}
+		if value, ok := configMap.Data[file.ConfigMapKeyRef.Key]; ok {
+			content = []byte(value)
+		} else if value, ok := configMap.BinaryData[file.ConfigMapKeyRef.Key]; ok {
+			content = value
+		} else {
+			return nil, errors.Errorf("key %s not found in configMap %s", file.ConfigMapKeyRef.Key, file.ConfigMapKeyRef.Name)
+		}
+
+	case file.SecretKeyRef != nil:
+		var secret corev1.Secret
+		err := r.Get(ctx, client.ObjectKey{Name: file.SecretKeyRef.Name, Namespace: server.Namespace}, &secret)
+		if err != nil {
+			return nil, errors.Wrapf(err, "getting secret %s", file.SecretKeyRef.Name)
+		}
+		value, ok := secret.Data[file.SecretKeyRef.Key]
+		if !ok {
+			return nil, errors.Errorf("key %s not found in secret %s", file.SecretKeyRef.Key, file.SecretKeyRef.Name)
+		}
+		content = value
+	}
+
+	if file.Template {
+		err, rendered := helpers.RenderTemplate(string(content), server)
+		if err != nil {
+			return nil, errors.Wrap(err, "rendering template")
+		}
+		content = []byte(rendered)
+	}
+	return content, nil
+}
+
+// ReconcileFiles resolves the Files of the Server and its plugins into the files Secret, which the init container copies them from.
+// The hash of their content is stored in the status, so the Server restarts when it changes.
+func (r *ServerReconciler) ReconcileFiles(ctx context.Context, log logr.Logger, server *v1.Server) error {
+	log.V(loglevels.Verbose).Info("start reconciling of files")
+
+	log.V(loglevels.Flow).Info("resolving files")
+	data := make(map[string][]byte)
+	hash := sha256.New()
+	files := serverFiles(server)
+	for index, file := range files {
+		content, err := r.resolveFileContent(ctx, server, file)
+		if err != nil {
+			return errors.Wrapf(err, "resolving file %s", file.Path)
+		}
+		data[fileKey(index)] = content
+		fmt.Fprintf(hash, "%s\x00%d\x00", file.Path, len(content))
+		hash.Write(content)
+	}
+	server.Status.FilesHash = ""
+	if len(files) > 0 {
+		server.Status.FilesHash = hex.EncodeToString(hash.Sum(nil))
+	}
+	log.V(loglevels.Flow).Info("resolved files ok", "files", len(data))
+
+	log.V(loglevels.Flow).Info("render files secret")
+	secret, err := r.RenderFilesSecret(log, server, data)
+	if err != nil {
+		return errors.Wrap(err, "rendering files secret")
+	}
+
+	log.V(loglevels.Flow).Info("fetching files secret manifest")
+	var existingSecret corev1.Secret
+	err = r.Get(ctx, client.ObjectKey{Name: filesSecretName(server), Namespace: server.Namespace}, &existingSecret)
+	if apierrors.IsNotFound(err) {
+		log.V(loglevels.Info).Info("files secret not found, creating new one")
+		err = r.Client.Create(ctx, secret)
+		if err != nil {
+			return errors.Wrap(err, "creating files secret")
+		}
+		log.V(loglevels.Flow).Info("created files secret ok")
+		r.normal(server, EventCreated, "Created Secret %s for the files", secret.Name)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get files secret")
+	}
+
+	log.V(loglevels.Flow).Info("comparing files secret data with the rendered data")
+	if len(existingSecret.Data) != len(secret.Data) || (len(secret.Data) > 0 && !reflect.DeepEqual(existingSecret.Data, secret.Data)) {
+		log.V(loglevels.Info).Info("replacing files secret")
+		existingSecret.Data = secret.Data
+		err = r.Client.Update(ctx, &existingSecret)
+		if err != nil {
+			return errors.Wrap(err, "replacing files secret")
+		}
+		r.normal(server, EventUpdated, "Updated Secret %s for the files", secret.Name)
+	}
+	log.V(loglevels.Flow).Info("files secret is up to date")
+
+	return nil
+}
+
+// RenderFilesSecret renders the secret holding the resolved Files of the Server
+func (r *ServerReconciler) RenderFilesSecret(log logr.Logger, server *v1.Server, data map[string][]byte) (*corev1.Secret, error) {
+	log.V(loglevels.Verbose).Info("rendering files secret")
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name),
+			},
+			Annotations: make(map[string]string),
+			Name:        filesSecretName(server),
+			Namespace:   server.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	log.V(loglevels.Flow).Info("rendered files secret ok")
+
+	log.V(loglevels.Verbose).Info("setting controller reference for files secret")
+	if err := ctrl.SetControllerReference(server, secret, r.Scheme); err != nil {
+		log.Info("ERROR failed to set owner reference", "error", err)
+		return nil, err
+	}
+	log.V(loglevels.Flow).Info("set controller reference ok for files secret")
+
+	return secret, nil
+}
@@ -0,0 +1,56 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+)
+
+// UsesCapacity tells if the Server has been admitted to run, or is still stopping. A Server without a phase is new,
+// or wasn't reconciled since the capacity limits were introduced, then it only counts when its Deployment runs it,
+// which scaledUp tells.
+func UsesCapacity(server *v1.Server, scaledUp bool) bool {
+	switch server.Status.Phase {
+	case v1.ServerPhaseStarting, v1.ServerPhaseRunning, v1.ServerPhaseStopping:
+		return true
+	case "":
+		return scaledUp
+	}
+	return false
+}
+
+// QueuedBefore tells if server a goes before server b in the queue: higher priority first, then the first one queued
+func QueuedBefore(a, b *v1.Server) bool {
+	if a.Spec.Priority != b.Spec.Priority {
+		return a.Spec.Priority > b.Spec.Priority
+	}
+	if a.Status.QueuedAt != b.Status.QueuedAt {
+		return a.Status.QueuedAt < b.Status.QueuedAt
+	}
+	return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+}
+
+// MayPreempt tells if the queued Server may stop the running Server under the preemption policy
+func MayPreempt(policy v1.PreemptionPolicy, queued, running *v1.Server) bool {
+	switch policy {
+	case v1.PreemptLowerPriority:
+		return running.Spec.Priority < queued.Spec.Priority
+	case v1.PreemptEqualOrLowerPriority:
+		return running.Spec.Priority <= queued.Spec.Priority
+	}
+	return false
+}
+
+// PreemptionVictim returns the running Server the queued Server may stop to make room: the one without players
+// that's been idle the longest. It returns nil when there's none.
+func PreemptionVictim(policy v1.PreemptionPolicy, queued *v1.Server, running []*v1.Server) *v1.Server {
+	var victim *v1.Server
+	for _, other := range running {
+		if !other.Spec.Enabled || len(other.Status.Players) > 0 || !MayPreempt(policy, queued, other) {
+			continue
+		}
+		// the longest idle server has the oldest IdleTime, servers that never had players have none
+		if victim == nil || other.Status.IdleTime < victim.Status.IdleTime {
+			victim = other
+		}
+	}
+	return victim
+}
@@ -0,0 +1,134 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// capacityServer returns an enabled Server for the capacity tests
+func capacityServer(name string, priority int32, status v1.ServerStatus) *v1.Server {
+	return &v1.Server{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "family", Name: name},
+		Spec:       v1.ServerSpec{Enabled: true, Priority: priority},
+		Status:     status,
+	}
+}
+
+func TestUsesCapacity(t *testing.T) {
+	tests := []struct {
+		name     string
+		phase    v1.ServerPhase
+		enabled  bool
+		scaledUp bool
+		expected bool
+	}{
+		{name: "starting", phase: v1.ServerPhaseStarting, enabled: true, expected: true},
+		{name: "running", phase: v1.ServerPhaseRunning, enabled: true, expected: true},
+		{name: "stopping", phase: v1.ServerPhaseStopping, expected: true},
+		{name: "queued", phase: v1.ServerPhaseQueued, enabled: true},
+		{name: "queued, scaled up", phase: v1.ServerPhaseQueued, enabled: true, scaledUp: true},
+		{name: "stopped", phase: v1.ServerPhaseStopped},
+		{name: "no phase, new", enabled: true},
+		{name: "no phase, scaled up", enabled: true, scaledUp: true, expected: true},
+		{name: "no phase, disabled"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &v1.Server{Spec: v1.ServerSpec{Enabled: test.enabled}, Status: v1.ServerStatus{Phase: test.phase}}
+			if found := UsesCapacity(server, test.scaledUp); found != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
+
+func TestQueuedBefore(t *testing.T) {
+	queue := []*v1.Server{
+		capacityServer("late", 0, v1.ServerStatus{QueuedAt: 300}),
+		capacityServer("b", 0, v1.ServerStatus{QueuedAt: 100}),
+		capacityServer("important", 10, v1.ServerStatus{QueuedAt: 400}),
+		capacityServer("a", 0, v1.ServerStatus{QueuedAt: 100}),
+		capacityServer("unimportant", -1, v1.ServerStatus{QueuedAt: 1}),
+	}
+	sort.Slice(queue, func(i, j int) bool { return QueuedBefore(queue[i], queue[j]) })
+
+	var found []string
+	for _, server := range queue {
+		found = append(found, server.Name)
+	}
+	expected := []string{"important", "a", "b", "late", "unimportant"}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v, got %v", expected, found)
+	}
+}
+
+func TestMayPreempt(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   v1.PreemptionPolicy
+		running  int32
+		expected bool
+	}{
+		{name: "never", policy: v1.PreemptNever, running: 0},
+		{name: "no policy", running: 0},
+		{name: "lower, lower", policy: v1.PreemptLowerPriority, running: 0, expected: true},
+		{name: "lower, equal", policy: v1.PreemptLowerPriority, running: 5},
+		{name: "equal or lower, equal", policy: v1.PreemptEqualOrLowerPriority, running: 5, expected: true},
+		{name: "equal or lower, higher", policy: v1.PreemptEqualOrLowerPriority, running: 6},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queued := capacityServer("queued", 5, v1.ServerStatus{})
+			running := capacityServer("running", test.running, v1.ServerStatus{})
+			if found := MayPreempt(test.policy, queued, running); found != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
+
+func TestPreemptionVictim(t *testing.T) {
+	idle := func(name string, priority int32, idleTime int64) *v1.Server {
+		return capacityServer(name, priority, v1.ServerStatus{Phase: v1.ServerPhaseRunning, IdleTime: idleTime})
+	}
+	playing := capacityServer("playing", 0, v1.ServerStatus{Phase: v1.ServerPhaseRunning, Players: []string{"Steve"}})
+	disabled := idle("disabled", 0, 1)
+	disabled.Spec.Enabled = false
+
+	tests := []struct {
+		name     string
+		policy   v1.PreemptionPolicy
+		running  []*v1.Server
+		expected string
+	}{
+		{name: "longest idle", policy: v1.PreemptLowerPriority,
+			running: []*v1.Server{idle("recent", 0, 300), idle("oldest", 0, 100), idle("older", 0, 200)}, expected: "oldest"},
+		{name: "never had players", policy: v1.PreemptLowerPriority,
+			running: []*v1.Server{idle("recent", 0, 300), idle("empty", 0, 0)}, expected: "empty"},
+		{name: "skips players", policy: v1.PreemptLowerPriority,
+			running: []*v1.Server{playing, idle("idle", 0, 300)}, expected: "idle"},
+		{name: "skips stopping", policy: v1.PreemptLowerPriority,
+			running: []*v1.Server{disabled, idle("idle", 0, 300)}, expected: "idle"},
+		{name: "skips priority", policy: v1.PreemptLowerPriority,
+			running: []*v1.Server{idle("equal", 5, 100), idle("lower", 0, 300)}, expected: "lower"},
+		{name: "equal priority", policy: v1.PreemptEqualOrLowerPriority,
+			running: []*v1.Server{idle("equal", 5, 100), idle("lower", 0, 300)}, expected: "equal"},
+		{name: "never", policy: v1.PreemptNever, running: []*v1.Server{idle("idle", 0, 100)}},
+		{name: "only players", policy: v1.PreemptLowerPriority, running: []*v1.Server{playing}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queued := capacityServer("queued", 5, v1.ServerStatus{Phase: v1.ServerPhaseQueued})
+			found := ""
+			if victim := PreemptionVictim(test.policy, queued, test.running); victim != nil {
+				found = victim.Name
+			}
+			if found != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultJavaImages are used when the OperatorConfig has no JavaImages
+var DefaultJavaImages = []v1.JavaImageRule{
+	{MaxVersion: "1.17", JavaVersion: 8, Image: "eclipse-temurin:8-jre"},
+	{MinVersion: "1.17", MaxVersion: "1.20.5", JavaVersion: 17, Image: "eclipse-temurin:17-jre"},
+	{MinVersion: "1.20.5", JavaVersion: 21, Image: "eclipse-temurin:21-jre"},
+}
+
+// imageJavaVersionPattern finds the Java version at the start of an image tag, like 8 in adoptopenjdk:8-jre-hotspot
+var imageJavaVersionPattern = regexp.MustCompile(`^(?:jdk|jre|java)?-?(\d+)(?:[.\-u_]|$)`)
+
+// JavaImageRule returns the first of the rules that covers the Minecraft version
+func JavaImageRule(rules []v1.JavaImageRule, version MinecraftVersion) (*v1.JavaImageRule, error) {
+	for index := range rules {
+		rule := &rules[index]
+		if rule.MinVersion != "" {
+			min, err := ParseMinecraftVersion(rule.MinVersion)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing minVersion of Java image rule")
+			}
+			if version.Compare(min) < 0 {
+				continue
+			}
+		}
+		if rule.MaxVersion != "" {
+			max, err := ParseMinecraftVersion(rule.MaxVersion)
+			if err != nil {
+				return nil, errors.Wrap(err, "parsing maxVersion of Java image rule")
+			}
+			if version.Compare(max) >= 0 {
+				continue
+			}
+		}
+		return rule, nil
+	}
+	return nil, errors.Errorf("no Java image for Minecraft version %s", version)
+}
+
+// ImageJavaVersion guesses the Java version of an image from its tag, it returns 0 when it can't tell
+func ImageJavaVersion(image string) int {
+	index := strings.LastIndex(image, ":")
+	if index < 0 || strings.Contains(image[index:], "/") {
+		return 0
+	}
+	match := imageJavaVersionPattern.FindStringSubmatch(image[index+1:])
+	if match == nil {
+		return 0
+	}
+	version, _ := strconv.Atoi(match[1])
+	if version == 1 {
+		// 1.8 style tags
+		return 0
+	}
+	return version
+}
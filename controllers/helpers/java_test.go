@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"testing"
+)
+
+func TestJavaImageRule(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []v1.JavaImageRule
+		version  string
+		expected string
+		wantErr  bool
+	}{
+		{name: "old", rules: DefaultJavaImages, version: "1.12.2", expected: "eclipse-temurin:8-jre"},
+		{name: "before 1.17", rules: DefaultJavaImages, version: "1.16.5", expected: "eclipse-temurin:8-jre"},
+		{name: "1.17", rules: DefaultJavaImages, version: "1.17", expected: "eclipse-temurin:17-jre"},
+		{name: "before 1.20.5", rules: DefaultJavaImages, version: "1.20.4", expected: "eclipse-temurin:17-jre"},
+		{name: "1.20.5", rules: DefaultJavaImages, version: "1.20.5", expected: "eclipse-temurin:21-jre"},
+		{name: "new", rules: DefaultJavaImages, version: "1.21.1", expected: "eclipse-temurin:21-jre"},
+		{name: "first rule wins", version: "1.20.4", expected: "custom:17", rules: []v1.JavaImageRule{
+			{MinVersion: "1.20", Image: "custom:17"}, {Image: "custom:any"}}},
+		{name: "not covered", version: "1.12.2", wantErr: true, rules: []v1.JavaImageRule{{MinVersion: "1.17", Image: "custom:17"}}},
+		{name: "no rules", version: "1.20.4", wantErr: true},
+		{name: "invalid minVersion", version: "1.20.4", wantErr: true, rules: []v1.JavaImageRule{{MinVersion: "latest", Image: "custom:17"}}},
+		{name: "invalid maxVersion", version: "1.20.4", wantErr: true, rules: []v1.JavaImageRule{{MaxVersion: "1.x", Image: "custom:17"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			version, err := ParseMinecraftVersion(test.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rule, err := JavaImageRule(test.rules, version)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if test.wantErr {
+				return
+			}
+			if rule.Image != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, rule.Image)
+			}
+		})
+	}
+}
+
+func TestImageJavaVersion(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected int
+	}{
+		{image: "eclipse-temurin:17-jre", expected: 17},
+		{image: "eclipse-temurin:21", expected: 21},
+		{image: "adoptopenjdk:8-jre-hotspot", expected: 8},
+		{image: "openjdk:8u312-jre", expected: 8},
+		{image: "amazoncorretto:11.0.21", expected: 11},
+		{image: "itzg/minecraft-server:java17", expected: 17},
+		{image: "registry.example.com:5000/java:jdk-21", expected: 21},
+		{image: "openjdk:1.8"},
+		{image: "eclipse-temurin:latest"},
+		{image: "eclipse-temurin"},
+		{image: "registry.example.com:5000/java"},
+	}
+	for _, test := range tests {
+		t.Run(test.image, func(t *testing.T) {
+			if found := ImageJavaVersion(test.image); found != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, found)
+			}
+		})
+	}
+}
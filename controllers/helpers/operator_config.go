@@ -0,0 +1,26 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"sort"
+)
+
+// OperatorConfigFor picks the OperatorConfig for Servers in the namespace: the one in the namespace, or else the
+// only OperatorConfig. When a namespace has more, the first by name is used. Returns nil when there's none.
+func OperatorConfigFor(configs []v1.OperatorConfig, namespace string) *v1.OperatorConfig {
+	sorted := make([]*v1.OperatorConfig, 0, len(configs))
+	for index := range configs {
+		sorted = append(sorted, &configs[index])
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, config := range sorted {
+		if config.Namespace == namespace {
+			return config
+		}
+	}
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func TestOperatorConfigFor(t *testing.T) {
+	config := func(namespace, name string) v1.OperatorConfig {
+		return v1.OperatorConfig{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	}
+
+	tests := []struct {
+		name      string
+		configs   []v1.OperatorConfig
+		namespace string
+		expected  string
+	}{
+		{name: "none", namespace: "family"},
+		{name: "only config", configs: []v1.OperatorConfig{config("minecraft", "config")}, namespace: "family",
+			expected: "minecraft/config"},
+		{name: "own namespace", configs: []v1.OperatorConfig{config("minecraft", "config"), config("family", "config")},
+			namespace: "family", expected: "family/config"},
+		{name: "first by name", configs: []v1.OperatorConfig{config("family", "b"), config("family", "a")},
+			namespace: "family", expected: "family/a"},
+		{name: "other namespaces", configs: []v1.OperatorConfig{config("minecraft", "config"), config("school", "config")},
+			namespace: "family"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found := ""
+			if config := OperatorConfigFor(test.configs, test.namespace); config != nil {
+				found = config.Namespace + "/" + config.Name
+			}
+			if found != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package helpers
+
+import (
+	"strings"
+)
+
+// ParsePlayerList returns the players in the response to the RCON list command, like
+// "There are 2 of a max of 20 players online: Steve, Alex"
+func ParsePlayerList(response string) []string {
+	players := []string{}
+	index := strings.Index(response, ":")
+	if index < 0 {
+		return players
+	}
+	for _, player := range strings.Split(response[index+1:], ",") {
+		player = strings.TrimSpace(player)
+		if player != "" {
+			players = append(players, player)
+		}
+	}
+	return players
+}
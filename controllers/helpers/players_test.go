@@ -0,0 +1,27 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlayerList(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected []string
+	}{
+		{name: "players", response: "There are 2 of a max of 20 players online: Steve, Alex", expected: []string{"Steve", "Alex"}},
+		{name: "one player", response: "There are 1 of a max of 20 players online: Steve", expected: []string{"Steve"}},
+		{name: "no players", response: "There are 0 of a max of 20 players online: ", expected: []string{}},
+		{name: "old format", response: "There are 2/20 players online:\nSteve, Alex\n", expected: []string{"Steve", "Alex"}},
+		{name: "no list", response: "Unknown command", expected: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if found := ParsePlayerList(test.response); !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
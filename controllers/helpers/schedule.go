@@ -0,0 +1,241 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ActiveScheduleWindow returns the window of the schedule that's open at the given time, with the time it opened.
+// It returns nil when no window is open.
+func ActiveScheduleWindow(schedule *v1.Schedule, now time.Time) (*v1.ScheduleWindow, time.Time, error) {
+	location := time.UTC
+	if schedule.Timezone != "" {
+		var err error
+		location, err = time.LoadLocation(schedule.Timezone)
+		if err != nil {
+			return nil, time.Time{}, errors.Wrap(err, "loading timezone")
+		}
+	}
+	now = now.In(location)
+
+	for index := range schedule.Windows {
+		window := &schedule.Windows[index]
+		var start time.Time
+		var open bool
+		var err error
+		if window.Cron != "" {
+			start, open, err = cronWindowStart(window, now)
+		} else {
+			start, open, err = dailyWindowStart(window, now)
+		}
+		if err != nil {
+			return nil, time.Time{}, errors.Wrapf(err, "schedule window %d", index)
+		}
+		if open {
+			return window, start, nil
+		}
+	}
+	return nil, time.Time{}, nil
+}
+
+// ScheduleAllowsRunning tells if the Server may run at the given time
+func ScheduleAllowsRunning(server *v1.Server, now time.Time) (bool, error) {
+	if server.Spec.Schedule == nil {
+		return true, nil
+	}
+	window, _, err := ActiveScheduleWindow(server.Spec.Schedule, now)
+	if err != nil {
+		return false, err
+	}
+	return window != nil, nil
+}
+
+// dailyWindowStart returns the start of the window when it's open, looking at windows that started today or yesterday
+func dailyWindowStart(window *v1.ScheduleWindow, now time.Time) (time.Time, bool, error) {
+	startMinutes, err := parseClock(window.Start)
+	if err != nil {
+		return time.Time{}, false, errors.Wrap(err, "parsing start")
+	}
+	endMinutes, err := parseClock(window.End)
+	if err != nil {
+		return time.Time{}, false, errors.Wrap(err, "parsing end")
+	}
+	length := endMinutes - startMinutes
+	if length <= 0 {
+		length += 24 * 60
+	}
+
+	for _, offset := range []int{0, -1} {
+		day := time.Date(now.Year(), now.Month(), now.Day()+offset, 0, 0, 0, 0, now.Location())
+		if !dayMatches(window.Days, day.Weekday()) {
+			continue
+		}
+		// on the wall clock, so the window keeps its times on the days the clock changes
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, startMinutes, 0, 0, now.Location())
+		end := time.Date(day.Year(), day.Month(), day.Day(), 0, startMinutes+length, 0, 0, now.Location())
+		if !now.Before(start) && now.Before(end) {
+			return start, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// cronWindowStart returns the start of the window when it's open, by looking for a matching start within the duration
+func cronWindowStart(window *v1.ScheduleWindow, now time.Time) (time.Time, bool, error) {
+	if window.DurationMinutes <= 0 {
+		return time.Time{}, false, errors.New("a cron window needs durationMinutes")
+	}
+	expression, err := parseCron(window.Cron)
+	if err != nil {
+		return time.Time{}, false, errors.Wrap(err, "parsing cron")
+	}
+
+	minute := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, now.Location())
+	for elapsed := 0; elapsed < int(window.DurationMinutes); elapsed++ {
+		start := minute.Add(-time.Duration(elapsed) * time.Minute)
+		if expression.matches(start) {
+			return start, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// dayMatches tells if the weekday is in the list of days, an empty list matches every day
+func dayMatches(days []string, weekday time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, day := range days {
+		if value, ok := weekdays[strings.ToLower(day)]; ok && value == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses HH:MM into minutes since midnight
+func parseClock(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// cronExpression holds the allowed values for each field of a cron expression
+type cronExpression struct {
+	minutes, hours, days, months, weekdays map[int]bool
+	anyDay, anyWeekday                     bool
+}
+
+// matches tells if the cron expression fires at the given minute.
+// Like cron, when both the day of month and the day of week are restricted, either of them has to match.
+func (c *cronExpression) matches(t time.Time) bool {
+	if !c.minutes[t.Minute()] || !c.hours[t.Hour()] || !c.months[int(t.Month())] {
+		return false
+	}
+	dayMatch := c.days[t.Day()]
+	weekdayMatch := c.weekdays[int(t.Weekday())]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekdayMatch
+	case c.anyWeekday:
+		return dayMatch
+	default:
+		return dayMatch || weekdayMatch
+	}
+}
+
+// parseCron parses a cron expression with 5 fields, supporting *, lists, ranges and steps
+func parseCron(expression string) (*cronExpression, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("expected 5 fields, got %d", len(fields))
+	}
+
+	var c cronExpression
+	var err error
+	if c.minutes, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, errors.Wrap(err, "minute")
+	}
+	if c.hours, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, errors.Wrap(err, "hour")
+	}
+	if c.days, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, errors.Wrap(err, "day of month")
+	}
+	if c.months, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, errors.Wrap(err, "month")
+	}
+	if c.weekdays, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, errors.Wrap(err, "day of week")
+	}
+	if c.weekdays[7] {
+		c.weekdays[0] = true
+	}
+	c.anyDay = fields[2] == "*"
+	c.anyWeekday = fields[4] == "*"
+	return &c, nil
+}
+
+// parseCronField parses a single field of a cron expression into the set of values it allows
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		stepped := false
+		if index := strings.Index(part, "/"); index >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step < 1 {
+				return nil, errors.Errorf("invalid step in %q", part)
+			}
+			part = part[:index]
+			stepped = true
+		}
+
+		low, high := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			low, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, errors.Errorf("invalid value %q", bounds[0])
+			}
+			high = low
+			if stepped {
+				// like cron, a single value with a step runs up to the end of the range
+				high = max
+			}
+			if len(bounds) == 2 {
+				high, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, errors.Errorf("invalid value %q", bounds[1])
+				}
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, errors.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
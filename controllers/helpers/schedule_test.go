@@ -0,0 +1,187 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestParseCronField(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    string
+		min, max int
+		expected []int
+		wantErr  bool
+	}{
+		{name: "any", field: "*", min: 1, max: 5, expected: []int{1, 2, 3, 4, 5}},
+		{name: "value", field: "5", max: 59, expected: []int{5}},
+		{name: "list", field: "1,3,5", max: 59, expected: []int{1, 3, 5}},
+		{name: "range", field: "1-4", max: 59, expected: []int{1, 2, 3, 4}},
+		{name: "any with step", field: "*/15", max: 59, expected: []int{0, 15, 30, 45}},
+		{name: "value with step", field: "10/20", max: 59, expected: []int{10, 30, 50}},
+		{name: "range with step", field: "1-10/3", max: 59, expected: []int{1, 4, 7, 10}},
+		{name: "list of ranges", field: "1-2,20-21", max: 59, expected: []int{1, 2, 20, 21}},
+		{name: "above max", field: "60", max: 59, wantErr: true},
+		{name: "below min", field: "0", min: 1, max: 31, wantErr: true},
+		{name: "reversed range", field: "5-1", max: 59, wantErr: true},
+		{name: "zero step", field: "*/0", max: 59, wantErr: true},
+		{name: "invalid step", field: "*/x", max: 59, wantErr: true},
+		{name: "invalid value", field: "mon", max: 7, wantErr: true},
+		{name: "invalid range end", field: "1-x", max: 59, wantErr: true},
+		{name: "empty", field: "", max: 59, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, err := parseCronField(test.field, test.min, test.max)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if test.wantErr {
+				return
+			}
+			var found []int
+			for value := range values {
+				found = append(found, value)
+			}
+			sort.Ints(found)
+			if !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	// a Friday
+	friday := time.Date(2024, 5, 10, 19, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		expression string
+		time       time.Time
+		expected   bool
+		wantErr    bool
+	}{
+		{name: "matches", expression: "0 19 * * 5", time: friday, expected: true},
+		{name: "other minute", expression: "0 19 * * 5", time: friday.Add(time.Minute)},
+		{name: "other weekday", expression: "0 19 * * 5", time: friday.AddDate(0, 0, 1)},
+		{name: "sunday as 7", expression: "0 19 * * 7", time: friday.AddDate(0, 0, 2), expected: true},
+		{name: "sunday as 0", expression: "0 19 * * 0", time: friday.AddDate(0, 0, 2), expected: true},
+		{name: "day of month", expression: "0 19 10 * *", time: friday, expected: true},
+		{name: "other day of month", expression: "0 19 11 * *", time: friday},
+		{name: "day of month or weekday", expression: "0 19 1 * 5", time: friday, expected: true},
+		{name: "day of month or other weekday", expression: "0 19 1 * 1", time: friday},
+		{name: "month", expression: "0 19 * 6 *", time: friday},
+		{name: "too few fields", expression: "0 19 * *", wantErr: true},
+		{name: "hour out of range", expression: "0 24 * * *", wantErr: true},
+		{name: "day out of range", expression: "0 0 0 * *", wantErr: true},
+		{name: "month out of range", expression: "0 0 * 13 *", wantErr: true},
+		{name: "weekday out of range", expression: "0 0 * * 8", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expression, err := parseCron(test.expression)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if test.wantErr {
+				return
+			}
+			if matches := expression.matches(test.time); matches != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, matches)
+			}
+		})
+	}
+}
+
+func TestDailyWindowStart(t *testing.T) {
+	amsterdam, err := time.LoadLocation("Europe/Amsterdam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weekend := v1.ScheduleWindow{Days: []string{"Sat", "sun"}, Start: "10:00", End: "18:00"}
+	night := v1.ScheduleWindow{Days: []string{"Fri"}, Start: "22:00", End: "02:00"}
+	// a Saturday
+	saturday := func(hour, minute int) time.Time { return time.Date(2024, 5, 11, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		window   v1.ScheduleWindow
+		now      time.Time
+		expected time.Time
+		wantErr  bool
+	}{
+		{name: "open", window: weekend, now: saturday(12, 0), expected: saturday(10, 0)},
+		{name: "at the start", window: weekend, now: saturday(10, 0), expected: saturday(10, 0)},
+		{name: "before the start", window: weekend, now: saturday(9, 59)},
+		{name: "at the end", window: weekend, now: saturday(18, 0)},
+		{name: "other day", window: weekend, now: saturday(12, 0).AddDate(0, 0, 2)},
+		{name: "every day", window: v1.ScheduleWindow{Start: "10:00", End: "18:00"}, now: saturday(12, 0).AddDate(0, 0, 2),
+			expected: saturday(10, 0).AddDate(0, 0, 2)},
+		{name: "before midnight", window: night, now: saturday(23, 0).AddDate(0, 0, -1), expected: saturday(22, 0).AddDate(0, 0, -1)},
+		{name: "after midnight", window: night, now: saturday(1, 0), expected: saturday(22, 0).AddDate(0, 0, -1)},
+		{name: "after the end, after midnight", window: night, now: saturday(2, 0)},
+		{name: "clock goes back", window: v1.ScheduleWindow{Start: "03:00", End: "05:00"},
+			now: time.Date(2024, 10, 27, 2, 30, 0, 0, time.UTC).In(amsterdam), expected: time.Date(2024, 10, 27, 3, 0, 0, 0, amsterdam)},
+		{name: "clock goes forward", window: v1.ScheduleWindow{Start: "01:00", End: "04:00"},
+			now: time.Date(2024, 3, 31, 4, 30, 0, 0, amsterdam)},
+		{name: "invalid start", window: v1.ScheduleWindow{Start: "25:00", End: "04:00"}, now: saturday(12, 0), wantErr: true},
+		{name: "invalid end", window: v1.ScheduleWindow{Start: "10:00"}, now: saturday(12, 0), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, open, err := dailyWindowStart(&test.window, test.now)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if open != !test.expected.IsZero() {
+				t.Fatalf("expected open %v, got %v", !test.expected.IsZero(), open)
+			}
+			if open && !start.Equal(test.expected) {
+				t.Errorf("expected start %v, got %v", test.expected, start)
+			}
+		})
+	}
+}
+
+func TestCronWindowStart(t *testing.T) {
+	// a Friday
+	friday := func(hour, minute int) time.Time { return time.Date(2024, 5, 10, hour, minute, 0, 0, time.UTC) }
+	evening := v1.ScheduleWindow{Cron: "0 19 * * 5", DurationMinutes: 180}
+
+	tests := []struct {
+		name     string
+		window   v1.ScheduleWindow
+		now      time.Time
+		expected time.Time
+		wantErr  bool
+	}{
+		{name: "open", window: evening, now: friday(20, 30), expected: friday(19, 0)},
+		{name: "at the start", window: evening, now: friday(19, 0), expected: friday(19, 0)},
+		{name: "before the start", window: evening, now: friday(18, 59)},
+		{name: "at the end", window: evening, now: friday(22, 0)},
+		{name: "past midnight", window: v1.ScheduleWindow{Cron: "0 23 * * 5", DurationMinutes: 120},
+			now: friday(0, 30).AddDate(0, 0, 1), expected: friday(23, 0)},
+		{name: "latest start", window: v1.ScheduleWindow{Cron: "*/30 * * * *", DurationMinutes: 60},
+			now: friday(20, 45), expected: friday(20, 30)},
+		{name: "no duration", window: v1.ScheduleWindow{Cron: "0 19 * * 5"}, now: friday(20, 0), wantErr: true},
+		{name: "invalid cron", window: v1.ScheduleWindow{Cron: "0 19 * *", DurationMinutes: 60}, now: friday(20, 0), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, open, err := cronWindowStart(&test.window, test.now)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if open != !test.expected.IsZero() {
+				t.Fatalf("expected open %v, got %v", !test.expected.IsZero(), open)
+			}
+			if open && !start.Equal(test.expected) {
+				t.Errorf("expected start %v, got %v", test.expected, start)
+			}
+		})
+	}
+}
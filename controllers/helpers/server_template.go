@@ -0,0 +1,140 @@
+package helpers
+
+import (
+	"encoding/json"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+// serverOwnFields are the fields of the spec that are up to the Server, the template doesn't set them
+var serverOwnFields = []string{"enabled", "rollback", "templateRef"}
+
+// MergeTemplate returns the spec of the Server with the defaults of the template filled in. The fields of the Server
+// override the template when they're in the allowed overrides, or the template doesn't set them. Objects (like
+// properties) are merged field by field, lists are replaced as a whole. Zero values (0, "", false, empty lists) don't
+// count as set. The overrides that aren't allowed are returned, the template wins for those.
+func MergeTemplate(template *v1.ServerTemplate, spec v1.ServerSpec) (v1.ServerSpec, []string, error) {
+	defaults, err := specFields(template.Spec.Defaults)
+	if err != nil {
+		return spec, nil, errors.Wrap(err, "reading template")
+	}
+	own, err := specFields(spec)
+	if err != nil {
+		return spec, nil, errors.Wrap(err, "reading spec")
+	}
+	for _, field := range serverOwnFields {
+		delete(defaults, field)
+		delete(own, field)
+	}
+
+	allowed := func(path string) bool {
+		for _, override := range template.Spec.AllowedOverrides {
+			if path == override || strings.HasPrefix(path, override+".") {
+				return true
+			}
+		}
+		return false
+	}
+	var ignored []string
+	merged := mergeFields(defaults, own, "", allowed, &ignored)
+	sort.Strings(ignored)
+
+	content, err := json.Marshal(merged)
+	if err != nil {
+		return spec, nil, errors.Wrap(err, "merging template")
+	}
+	var effective v1.ServerSpec
+	if err := json.Unmarshal(content, &effective); err != nil {
+		return spec, nil, errors.Wrap(err, "merging template")
+	}
+	effective.Enabled = spec.Enabled
+	effective.Rollback = spec.Rollback
+	effective.TemplateRef = spec.TemplateRef
+	if effective.InitMemory == 0 {
+		effective.InitMemory = effective.MaxMemory
+	}
+	return effective, ignored, nil
+}
+
+// specFields returns the fields of the spec as they're in the manifest
+func specFields(spec v1.ServerSpec) (map[string]interface{}, error) {
+	content, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]interface{}
+	return fields, json.Unmarshal(content, &fields)
+}
+
+// mergeFields merges the fields of the Server into the defaults, and adds the paths of overrides that aren't allowed
+// to ignored
+func mergeFields(defaults, own map[string]interface{}, prefix string, allowed func(string) bool, ignored *[]string) map[string]interface{} {
+	merged := make(map[string]interface{}, len(defaults))
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range own {
+		if isZeroField(value) {
+			continue
+		}
+		path := prefix + key
+		base := merged[key]
+		if isZeroField(base) {
+			merged[key] = value
+			continue
+		}
+		baseFields, baseIsObject := base.(map[string]interface{})
+		ownFields, ownIsObject := value.(map[string]interface{})
+		switch {
+		case baseIsObject && ownIsObject:
+			merged[key] = mergeFields(baseFields, ownFields, path+".", allowed, ignored)
+		case allowed(path):
+			merged[key] = value
+		case !jsonEqual(base, value):
+			*ignored = append(*ignored, path)
+		}
+	}
+	return merged
+}
+
+// isZeroField tells if a field of the manifest has its zero value, which means it isn't set
+func isZeroField(value interface{}) bool {
+	switch value := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !value
+	case float64:
+		return value == 0
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+// jsonEqual tells if two fields of the manifest are the same
+func jsonEqual(a, b interface{}) bool {
+	aContent, aErr := json.Marshal(a)
+	bContent, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aContent) == string(bContent)
+}
+
+// EffectiveServer returns the Server with the spec it runs with, for Servers that use a ServerTemplate. It's meant
+// for reading the spec of Servers the reconciler doesn't have at hand, it should never be stored.
+func EffectiveServer(server *v1.Server) *v1.Server {
+	if server.Spec.TemplateRef == "" || server.Status.EffectiveSpec == nil {
+		return server
+	}
+	effective := server.DeepCopy()
+	effective.Spec = *server.Status.EffectiveSpec.DeepCopy()
+	// these are up to the Server, and can be newer than the last reconcile
+	effective.Spec.Enabled = server.Spec.Enabled
+	effective.Spec.Rollback = server.Spec.Rollback
+	return effective
+}
@@ -0,0 +1,73 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"reflect"
+	"testing"
+)
+
+func TestMergeTemplate(t *testing.T) {
+	template := &v1.ServerTemplate{Spec: v1.ServerTemplateSpec{
+		Defaults: v1.ServerSpec{
+			ServerVersion:      "paper-1.20.4",
+			MaxMemory:          4096,
+			IdleTimeoutSeconds: 600,
+			Enabled:            true,
+			Properties:         map[string]string{"difficulty": "normal", "motd": "A server"},
+			ModJars:            []string{"a.jar", "b.jar"},
+		},
+		AllowedOverrides: []string{"properties.motd", "maxMemoryMB"},
+	}}
+
+	tests := []struct {
+		name     string
+		spec     v1.ServerSpec
+		expected v1.ServerSpec
+		ignored  []string
+	}{
+		{
+			name: "defaults",
+			spec: v1.ServerSpec{TemplateRef: "family"},
+			expected: v1.ServerSpec{TemplateRef: "family", ServerVersion: "paper-1.20.4", MaxMemory: 4096, InitMemory: 4096,
+				IdleTimeoutSeconds: 600, Properties: map[string]string{"difficulty": "normal", "motd": "A server"},
+				ModJars: []string{"a.jar", "b.jar"}},
+		},
+		{
+			name: "allowed overrides",
+			spec: v1.ServerSpec{Enabled: true, MaxMemory: 2048, Properties: map[string]string{"motd": "Castle", "pvp": "false"}},
+			expected: v1.ServerSpec{Enabled: true, ServerVersion: "paper-1.20.4", MaxMemory: 2048, InitMemory: 2048,
+				IdleTimeoutSeconds: 600, Properties: map[string]string{"difficulty": "normal", "motd": "Castle", "pvp": "false"},
+				ModJars: []string{"a.jar", "b.jar"}},
+		},
+		{
+			name: "ignored overrides",
+			spec: v1.ServerSpec{ServerVersion: "paper-1.20.2", IdleTimeoutSeconds: 600, ModJars: []string{"c.jar"},
+				Properties: map[string]string{"difficulty": "hard"}},
+			expected: v1.ServerSpec{ServerVersion: "paper-1.20.4", MaxMemory: 4096, InitMemory: 4096,
+				IdleTimeoutSeconds: 600, Properties: map[string]string{"difficulty": "normal", "motd": "A server"},
+				ModJars: []string{"a.jar", "b.jar"}},
+			ignored: []string{"mod-jars", "properties.difficulty", "server-version"},
+		},
+		{
+			name: "fields the template doesn't set",
+			spec: v1.ServerSpec{HostPort: 25566, Rollback: "snapshot", ActiveWorld: "island"},
+			expected: v1.ServerSpec{ServerVersion: "paper-1.20.4", MaxMemory: 4096, InitMemory: 4096, HostPort: 25566,
+				IdleTimeoutSeconds: 600, Properties: map[string]string{"difficulty": "normal", "motd": "A server"},
+				ModJars: []string{"a.jar", "b.jar"}, Rollback: "snapshot", ActiveWorld: "island"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			effective, ignored, err := MergeTemplate(template, test.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(effective, test.expected) {
+				t.Errorf("expected\n%+v\ngot\n%+v", test.expected, effective)
+			}
+			if !reflect.DeepEqual(ignored, test.ignored) {
+				t.Errorf("expected ignored %v, got %v", test.ignored, ignored)
+			}
+		})
+	}
+}
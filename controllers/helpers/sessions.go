@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"sort"
+	"time"
+)
+
+// Session is a period a player was on a Server. End is 0 while the player is online
+type Session struct {
+	Player string `json:"player"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end,omitempty"`
+}
+
+// UpdateSessions starts sessions for the players that came online and ends the sessions of the players that are gone,
+// at the given time. It tells if the sessions changed.
+func UpdateSessions(sessions []Session, players []string, now time.Time) ([]Session, bool) {
+	online := make(map[string]bool, len(players))
+	for _, player := range players {
+		online[player] = true
+	}
+
+	changed := false
+	for index := range sessions {
+		session := &sessions[index]
+		if session.End != 0 {
+			continue
+		}
+		if online[session.Player] {
+			// already has a session
+			delete(online, session.Player)
+			continue
+		}
+		session.End = now.Unix()
+		changed = true
+	}
+
+	// the rest came online, in the order of the players for stable output
+	for _, player := range players {
+		if online[player] {
+			sessions = append(sessions, Session{Player: player, Start: now.Unix()})
+			delete(online, player)
+			changed = true
+		}
+	}
+	return sessions, changed
+}
+
+// PruneSessions drops the sessions that ended before the given time
+func PruneSessions(sessions []Session, before time.Time) ([]Session, bool) {
+	kept := sessions[:0]
+	for _, session := range sessions {
+		if session.End != 0 && session.End < before.Unix() {
+			continue
+		}
+		kept = append(kept, session)
+	}
+	return kept, len(kept) != len(sessions)
+}
+
+// Playtime returns the time each player played between from and to. Open sessions count up to to.
+func Playtime(sessions []Session, from, to time.Time) map[string]time.Duration {
+	playtime := make(map[string]time.Duration)
+	for _, session := range sessions {
+		start := time.Unix(session.Start, 0)
+		end := to
+		if session.End != 0 {
+			end = time.Unix(session.End, 0)
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			playtime[session.Player] += end.Sub(start)
+		}
+	}
+	return playtime
+}
+
+// SortSessions sorts the sessions by start, newest first
+func SortSessions(sessions []Session) {
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].Start > sessions[j].Start
+	})
+}
+
+// StartOfDay returns the midnight before the given time, in its location
+func StartOfDay(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+}
+
+// StartOfWeek returns the midnight at the start of the Monday before the given time, in its location
+func StartOfWeek(now time.Time) time.Time {
+	day := StartOfDay(now)
+	// Sunday is 0, and the last day of the week
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
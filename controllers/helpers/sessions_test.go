@@ -0,0 +1,110 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUpdateSessions(t *testing.T) {
+	now := time.Date(2024, 5, 10, 19, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		sessions []Session
+		players  []string
+		expected []Session
+		changed  bool
+	}{
+		{name: "no players", expected: nil},
+		{name: "joined", players: []string{"Steve", "Alex"}, changed: true,
+			expected: []Session{{Player: "Steve", Start: now.Unix()}, {Player: "Alex", Start: now.Unix()}}},
+		{name: "still online", sessions: []Session{{Player: "Steve", Start: earlier}}, players: []string{"Steve"},
+			expected: []Session{{Player: "Steve", Start: earlier}}},
+		{name: "left", sessions: []Session{{Player: "Steve", Start: earlier}}, changed: true,
+			expected: []Session{{Player: "Steve", Start: earlier, End: now.Unix()}}},
+		{name: "joined again", sessions: []Session{{Player: "Steve", Start: earlier, End: earlier + 60}}, players: []string{"Steve"},
+			changed:  true,
+			expected: []Session{{Player: "Steve", Start: earlier, End: earlier + 60}, {Player: "Steve", Start: now.Unix()}}},
+		{name: "one left, one joined", sessions: []Session{{Player: "Steve", Start: earlier}}, players: []string{"Alex"},
+			changed:  true,
+			expected: []Session{{Player: "Steve", Start: earlier, End: now.Unix()}, {Player: "Alex", Start: now.Unix()}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found, changed := UpdateSessions(test.sessions, test.players, now)
+			if changed != test.changed {
+				t.Errorf("expected changed %v, got %v", test.changed, changed)
+			}
+			if !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
+
+func TestPruneSessions(t *testing.T) {
+	before := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	old := Session{Player: "Steve", Start: before.Unix() - 7200, End: before.Unix() - 3600}
+	overlapping := Session{Player: "Alex", Start: before.Unix() - 3600, End: before.Unix() + 3600}
+	open := Session{Player: "Steve", Start: before.Unix() - 3600}
+	recent := Session{Player: "Alex", Start: before.Unix() + 3600, End: before.Unix() + 7200}
+
+	tests := []struct {
+		name     string
+		sessions []Session
+		expected []Session
+		pruned   bool
+	}{
+		{name: "none", sessions: []Session{}, expected: []Session{}},
+		{name: "nothing old", sessions: []Session{overlapping, open, recent}, expected: []Session{overlapping, open, recent}},
+		{name: "old", sessions: []Session{old, open, recent}, expected: []Session{open, recent}, pruned: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			found, pruned := PruneSessions(test.sessions, before)
+			if pruned != test.pruned {
+				t.Errorf("expected pruned %v, got %v", test.pruned, pruned)
+			}
+			if !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
+
+func TestPlaytime(t *testing.T) {
+	from := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	to := from.Add(20 * time.Hour)
+	at := func(hours float64) int64 { return from.Add(time.Duration(hours * float64(time.Hour))).Unix() }
+
+	tests := []struct {
+		name     string
+		sessions []Session
+		expected map[string]time.Duration
+	}{
+		{name: "none", expected: map[string]time.Duration{}},
+		{name: "within", sessions: []Session{{Player: "Steve", Start: at(1), End: at(2)}},
+			expected: map[string]time.Duration{"Steve": time.Hour}},
+		{name: "added up", sessions: []Session{{Player: "Steve", Start: at(1), End: at(2)}, {Player: "Steve", Start: at(3), End: at(3.5)}},
+			expected: map[string]time.Duration{"Steve": 90 * time.Minute}},
+		{name: "per player", sessions: []Session{{Player: "Steve", Start: at(1), End: at(2)}, {Player: "Alex", Start: at(1), End: at(1.5)}},
+			expected: map[string]time.Duration{"Steve": time.Hour, "Alex": 30 * time.Minute}},
+		{name: "started before", sessions: []Session{{Player: "Steve", Start: at(-2), End: at(1)}},
+			expected: map[string]time.Duration{"Steve": time.Hour}},
+		{name: "open", sessions: []Session{{Player: "Steve", Start: at(18)}},
+			expected: map[string]time.Duration{"Steve": 2 * time.Hour}},
+		{name: "ended after", sessions: []Session{{Player: "Steve", Start: at(19), End: at(22)}},
+			expected: map[string]time.Duration{"Steve": time.Hour}},
+		{name: "outside", sessions: []Session{{Player: "Steve", Start: at(-3), End: at(-1)}, {Player: "Alex", Start: at(21), End: at(22)}},
+			expected: map[string]time.Duration{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if found := Playtime(test.sessions, from, to); !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
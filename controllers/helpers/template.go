@@ -19,3 +19,18 @@ func RenderTemplate(templateString string, data interface{}) (error, string) {
 	}
 	return nil, result.String()
 }
+
+// WithoutKeys returns a copy of properties without the keys that are set in any of the overrides, so a key isn't
+// rendered twice
+func WithoutKeys(properties map[string]string, overrides ...map[string]string) map[string]string {
+	result := make(map[string]string, len(properties))
+	for key, value := range properties {
+		result[key] = value
+	}
+	for _, override := range overrides {
+		for key := range override {
+			delete(result, key)
+		}
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithoutKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties map[string]string
+		overrides  []map[string]string
+		expected   map[string]string
+	}{
+		{
+			name:       "no overrides",
+			properties: map[string]string{"level-seed": "42", "pvp": "false"},
+			expected:   map[string]string{"level-seed": "42", "pvp": "false"},
+		},
+		{
+			name:       "overridden keys dropped",
+			properties: map[string]string{"level-seed": "42", "level-type": "flat", "pvp": "false"},
+			overrides:  []map[string]string{{"level-seed": "1"}, {"level-type": "amplified", "online-mode": "false"}},
+			expected:   map[string]string{"pvp": "false"},
+		},
+		{
+			name:     "no properties",
+			expected: map[string]string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if found := WithoutKeys(test.properties, test.overrides...); !reflect.DeepEqual(found, test.expected) {
+				t.Errorf("expected %v, got %v", test.expected, found)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"github.com/pkg/errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var minecraftVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?$`)
+
+// MinecraftVersion is a parsed Minecraft release version, like 1.16.5
+type MinecraftVersion struct {
+	Major, Minor, Patch int
+}
+
+// String returns the version as it's written by Mojang, leaving out a zero patch
+func (v MinecraftVersion) String() string {
+	version := strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor)
+	if v.Patch > 0 {
+		version += "." + strconv.Itoa(v.Patch)
+	}
+	return version
+}
+
+// Compare returns -1, 0 or 1 when v is before, equal to or after other
+func (v MinecraftVersion) Compare(other MinecraftVersion) int {
+	for _, diff := range []int{v.Major - other.Major, v.Minor - other.Minor, v.Patch - other.Patch} {
+		if diff < 0 {
+			return -1
+		}
+		if diff > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// ParseMinecraftVersion parses a version like 1.16.5
+func ParseMinecraftVersion(version string) (MinecraftVersion, error) {
+	match := minecraftVersionPattern.FindStringSubmatch(version)
+	if match == nil {
+		return MinecraftVersion{}, errors.Errorf("%q is not a Minecraft version", version)
+	}
+	var parsed MinecraftVersion
+	parsed.Major, _ = strconv.Atoi(match[1])
+	parsed.Minor, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		parsed.Patch, _ = strconv.Atoi(match[3])
+	}
+	return parsed, nil
+}
+
+// ParseServerVersion splits a ServerVersion (e.g.: forge-1.16.5, vanilla-1.20.4, forge-1.16.5-36.2.2)
+// into the flavor and the Minecraft version
+func ParseServerVersion(serverVersion string) (string, MinecraftVersion, error) {
+	parts := strings.Split(serverVersion, "-")
+	for index, part := range parts {
+		version, err := ParseMinecraftVersion(part)
+		if err == nil {
+			return strings.Join(parts[:index], "-"), version, nil
+		}
+	}
+	return "", MinecraftVersion{}, errors.Errorf("no Minecraft version found in %q", serverVersion)
+}
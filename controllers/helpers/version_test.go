@@ -0,0 +1,68 @@
+package helpers
+
+import (
+	"testing"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		serverVersion string
+		flavor        string
+		version       MinecraftVersion
+		wantErr       bool
+	}{
+		{serverVersion: "forge-1.12.2", flavor: "forge", version: MinecraftVersion{1, 12, 2}},
+		{serverVersion: "paper-1.20.4", flavor: "paper", version: MinecraftVersion{1, 20, 4}},
+		{serverVersion: "vanilla-1.20", flavor: "vanilla", version: MinecraftVersion{1, 20, 0}},
+		{serverVersion: "forge-1.16.5-36.2.2", flavor: "forge", version: MinecraftVersion{1, 16, 5}},
+		{serverVersion: "neo-forge-1.21.1", flavor: "neo-forge", version: MinecraftVersion{1, 21, 1}},
+		{serverVersion: "1.20.4", version: MinecraftVersion{1, 20, 4}},
+		{serverVersion: "paper", wantErr: true},
+		{serverVersion: "paper-latest", wantErr: true},
+		{serverVersion: "paper-1.20.4.1", wantErr: true},
+		{serverVersion: "paper-1", wantErr: true},
+		{serverVersion: "", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.serverVersion, func(t *testing.T) {
+			flavor, version, err := ParseServerVersion(test.serverVersion)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if test.wantErr {
+				return
+			}
+			if flavor != test.flavor || version != test.version {
+				t.Errorf("expected %s %v, got %s %v", test.flavor, test.version, flavor, version)
+			}
+		})
+	}
+}
+
+func TestMinecraftVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "1.20.4", b: "1.20.4", expected: 0},
+		{a: "1.20", b: "1.20.0", expected: 0},
+		{a: "1.20.4", b: "1.20.5", expected: -1},
+		{a: "1.21", b: "1.20.6", expected: 1},
+		{a: "1.9", b: "1.12.2", expected: -1},
+	}
+	for _, test := range tests {
+		t.Run(test.a+" "+test.b, func(t *testing.T) {
+			a, err := ParseMinecraftVersion(test.a)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := ParseMinecraftVersion(test.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found := a.Compare(b); found != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, found)
+			}
+		})
+	}
+}
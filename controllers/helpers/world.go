@@ -0,0 +1,91 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	"path"
+)
+
+// DefaultWorldName is the name of the world that's defined by ServerSpec.World
+const DefaultWorldName = "default"
+
+// ActiveWorldName returns the name of the world the Server should run
+func ActiveWorldName(server *v1.Server) string {
+	if server.Spec.ActiveWorld == "" {
+		return DefaultWorldName
+	}
+	return server.Spec.ActiveWorld
+}
+
+// RunningWorldName returns the name of the world the Server runs: the active world, or the default world when the
+// active world isn't defined
+func RunningWorldName(server *v1.Server) string {
+	name := ActiveWorldName(server)
+	if _, err := WorldSpec(server, name); err != nil {
+		return DefaultWorldName
+	}
+	return name
+}
+
+// WorldSpec returns the spec for the named world, or nil when it has no settings
+func WorldSpec(server *v1.Server, name string) (*v1.WorldSpec, error) {
+	if name == DefaultWorldName {
+		return server.Spec.World, nil
+	}
+	for index := range server.Spec.Worlds {
+		if server.Spec.Worlds[index].Name == name {
+			return &server.Spec.Worlds[index].WorldSpec, nil
+		}
+	}
+	return nil, errors.Errorf("world %q is not defined", name)
+}
+
+// WorldNames returns the names of all worlds of the Server, starting with the default world
+func WorldNames(server *v1.Server) []string {
+	names := []string{DefaultWorldName}
+	for _, world := range server.Spec.Worlds {
+		if world.Name == DefaultWorldName {
+			// the default world takes its place, see ValidateWorlds
+			continue
+		}
+		names = append(names, world.Name)
+	}
+	return names
+}
+
+// ValidateWorlds checks that the names of the worlds are unique, and that none takes the name of the default world,
+// which would hide it. The error tells which world is used instead.
+func ValidateWorlds(server *v1.Server) error {
+	seen := map[string]bool{DefaultWorldName: true}
+	for _, world := range server.Spec.Worlds {
+		if world.Name == DefaultWorldName {
+			return errors.Errorf("world name %q is reserved for the default world, which is configured with world, "+
+				"the world with this name in worlds is ignored", world.Name)
+		}
+		if seen[world.Name] {
+			return errors.Errorf("world %q is defined more than once, only the first world with the name is used",
+				world.Name)
+		}
+		seen[world.Name] = true
+	}
+	return nil
+}
+
+// WorldPath returns the path of the named world on the Server's volume.
+// The default world lives in the directory named after the Server, the others under .worlds/
+func WorldPath(server *v1.Server, name string) string {
+	if name == DefaultWorldName {
+		return server.Name
+	}
+	return path.Join(".worlds", server.Name, name)
+}
+
+// CrashReportsPath returns the directory on the Server's volume that holds its crash reports
+func CrashReportsPath(server *v1.Server) string {
+	return path.Join(".crash-reports", server.Name)
+}
+
+// StatePath returns the directory on the Server's volume that holds the files the game changes, like ops.json
+func StatePath(server *v1.Server) string {
+	return path.Join(".state", server.Name)
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"testing"
+)
+
+func TestValidateWorlds(t *testing.T) {
+	tests := []struct {
+		name     string
+		worlds   []v1.NamedWorld
+		expected string
+	}{
+		{name: "none"},
+		{name: "unique", worlds: []v1.NamedWorld{{Name: "creative"}, {Name: "survival"}}},
+		{name: "default", worlds: []v1.NamedWorld{{Name: "creative"}, {Name: DefaultWorldName}},
+			expected: `world name "default" is reserved for the default world, which is configured with world, ` +
+				`the world with this name in worlds is ignored`},
+		{name: "duplicate", worlds: []v1.NamedWorld{{Name: "creative"}, {Name: "creative"}},
+			expected: `world "creative" is defined more than once, only the first world with the name is used`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &v1.Server{Spec: v1.ServerSpec{Worlds: test.worlds}}
+			err := ValidateWorlds(server)
+			if (err != nil) != (test.expected != "") {
+				t.Fatalf("expected error %q, got %v", test.expected, err)
+			}
+			if err != nil && err.Error() != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestRunningWorldName(t *testing.T) {
+	worlds := []v1.NamedWorld{{Name: "creative"}}
+	tests := []struct {
+		name     string
+		active   string
+		expected string
+	}{
+		{name: "default", expected: DefaultWorldName},
+		{name: "named", active: "creative", expected: "creative"},
+		{name: "undefined", active: "survival", expected: DefaultWorldName},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := &v1.Server{Spec: v1.ServerSpec{Worlds: worlds, ActiveWorld: test.active}}
+			if found := RunningWorldName(server); found != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, found)
+			}
+		})
+	}
+}
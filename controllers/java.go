@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionJavaRuntimeMatches tells if the image has the Java version the Minecraft version needs
+const ConditionJavaRuntimeMatches = "JavaRuntimeMatches"
+
+// javaImageRules returns the Java runtime images configured for the namespace, or the defaults
+func javaImageRules(namespace string) []v1.JavaImageRule {
+	javaImages := ConfigFor(namespace).JavaImages
+	if len(javaImages) == 0 {
+		return helpers.DefaultJavaImages
+	}
+	return javaImages
+}
+
+// javaImageRule returns the rule for the ServerVersion of the Server
+func javaImageRule(server *v1.Server) (*v1.JavaImageRule, error) {
+	_, version, err := helpers.ParseServerVersion(serverVersion(server))
+	if err != nil {
+		return nil, err
+	}
+	return helpers.JavaImageRule(javaImageRules(server.Namespace), version)
+}
+
+// serverImage returns the image to run: the Image from the spec, or the Java runtime image for the ServerVersion
+func serverImage(server *v1.Server) (string, error) {
+	if server.Spec.Image != "" {
+		return server.Spec.Image, nil
+	}
+	rule, err := javaImageRule(server)
+	if err != nil {
+		return "", errors.Wrap(err, "no image set, and can't pick one")
+	}
+	return rule.Image, nil
+}
+
+// UpdateJavaStatus records the image of the Server, and flags an image that doesn't have the Java version the
+// Minecraft version needs. It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) UpdateJavaStatus(log logr.Logger, server *v1.Server) {
+	log.V(loglevels.Verbose).Info("updating Java runtime status")
+
+	image, err := serverImage(server)
+	server.Status.Image = image
+	if err != nil {
+		log.V(loglevels.Info).Info("can't pick an image for the server", "error", err)
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionJavaRuntimeMatches,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "NoImage",
+			Message:            err.Error(),
+		})
+		return
+	}
+
+	rule, err := javaImageRule(server)
+	if err != nil {
+		log.V(loglevels.Flow).Info("can't tell which Java version the server needs", "error", err)
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionJavaRuntimeMatches,
+			Status:             metav1.ConditionUnknown,
+			ObservedGeneration: server.Generation,
+			Reason:             "UnknownMinecraftVersion",
+			Message:            err.Error(),
+		})
+		return
+	}
+
+	if image == rule.Image {
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionJavaRuntimeMatches,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: server.Generation,
+			Reason:             "JavaVersionMatches",
+			Message:            fmt.Sprintf("The image has Java %d", rule.JavaVersion),
+		})
+		return
+	}
+
+	javaVersion := helpers.ImageJavaVersion(image)
+	switch {
+	case javaVersion == 0:
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionJavaRuntimeMatches,
+			Status:             metav1.ConditionUnknown,
+			ObservedGeneration: server.Generation,
+			Reason:             "UnknownJavaVersion",
+			Message:            fmt.Sprintf("Can't tell the Java version of the image, the server needs Java %d", rule.JavaVersion),
+		})
+	case javaVersion != int(rule.JavaVersion):
+		log.V(loglevels.Info).Info("image doesn't have the Java version the server needs",
+			"image", image, "needs", rule.JavaVersion, "has", javaVersion)
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionJavaRuntimeMatches,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "JavaVersionMismatch",
+			Message:            fmt.Sprintf("The server needs Java %d, the image has Java %d (%s would)", rule.JavaVersion, javaVersion, rule.Image),
+		})
+	default:
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionJavaRuntimeMatches,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: server.Generation,
+			Reason:             "JavaVersionMatches",
+			Message:            fmt.Sprintf("The image has Java %d", javaVersion),
+		})
+	}
+}
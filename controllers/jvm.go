@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"fmt"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"strings"
+)
+
+// defaultMemoryOverheadMB is the memory for the JVM on top of the heap, for metaspace, threads, buffers and such
+const defaultMemoryOverheadMB = 512
+
+// aikarFlags are Aikar's G1 flags, see https://mcflags.emc.gs
+var aikarFlags = []string{
+	"-XX:+UseG1GC",
+	"-XX:+ParallelRefProcEnabled",
+	"-XX:MaxGCPauseMillis=200",
+	"-XX:+UnlockExperimentalVMOptions",
+	"-XX:+DisableExplicitGC",
+	"-XX:+AlwaysPreTouch",
+	"-XX:G1HeapWastePercent=5",
+	"-XX:G1MixedGCCountTarget=4",
+	"-XX:G1MixedGCLiveThresholdPercent=90",
+	"-XX:G1RSetUpdatingPauseTimePercent=5",
+	"-XX:SurvivorRatio=32",
+	"-XX:+PerfDisableSharedMem",
+	"-XX:MaxTenuringThreshold=1",
+	"-Dusing.aikars.flags=https://mcflags.emc.gs",
+	"-Daikars.new.flags=true",
+}
+
+// aikarSmallHeapFlags are added to aikarFlags for heaps up to 12GB
+var aikarSmallHeapFlags = []string{
+	"-XX:G1NewSizePercent=30",
+	"-XX:G1MaxNewSizePercent=40",
+	"-XX:G1HeapRegionSize=8M",
+	"-XX:G1ReservePercent=20",
+	"-XX:InitiatingHeapOccupancyPercent=15",
+}
+
+// aikarLargeHeapFlags are added to aikarFlags for heaps over 12GB
+var aikarLargeHeapFlags = []string{
+	"-XX:G1NewSizePercent=40",
+	"-XX:G1MaxNewSizePercent=50",
+	"-XX:G1HeapRegionSize=16M",
+	"-XX:G1ReservePercent=15",
+	"-XX:InitiatingHeapOccupancyPercent=20",
+}
+
+// memoryOverheadMB returns the memory for the JVM on top of the heap
+func memoryOverheadMB(server *v1.Server) int64 {
+	if server.Spec.JVM == nil || server.Spec.JVM.MemoryOverheadMB == nil {
+		return defaultMemoryOverheadMB
+	}
+	return int64(*server.Spec.JVM.MemoryOverheadMB)
+}
+
+// containerMemoryMB returns the memory request and limit for the minecraft container
+func containerMemoryMB(server *v1.Server) int64 {
+	return int64(server.Spec.MaxMemory) + memoryOverheadMB(server)
+}
+
+// javaOpts renders the JVM flags for the Server, which start.sh is expected to pass to java as $JAVA_OPTS
+func javaOpts(server *v1.Server) string {
+	flags := []string{
+		fmt.Sprintf("-Xms%dM", server.Spec.InitMemory),
+		fmt.Sprintf("-Xmx%dM", server.Spec.MaxMemory),
+	}
+	if server.Spec.JVM == nil {
+		return strings.Join(flags, " ")
+	}
+
+	switch server.Spec.JVM.Preset {
+	case v1.JVMPresetG1:
+		flags = append(flags, "-XX:+UseG1GC")
+	case v1.JVMPresetAikar:
+		flags = append(flags, aikarFlags...)
+		if server.Spec.MaxMemory > 12*1024 {
+			flags = append(flags, aikarLargeHeapFlags...)
+		} else {
+			flags = append(flags, aikarSmallHeapFlags...)
+		}
+	}
+	flags = append(flags, server.Spec.JVM.ExtraFlags...)
+	return strings.Join(flags, " ")
+}
+
+// containerResources renders the resources for the minecraft container.
+// Memory request and limit are the same, so the scheduler reserves what the JVM can use.
+func containerResources(server *v1.Server) (corev1.ResourceRequirements, error) {
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{},
+		Limits:   corev1.ResourceList{},
+	}
+
+	if server.Spec.MaxMemory > 0 {
+		memory := resource.MustParse(fmt.Sprintf("%dMi", containerMemoryMB(server)))
+		resources.Requests[corev1.ResourceMemory] = memory
+		resources.Limits[corev1.ResourceMemory] = memory
+	}
+
+	if server.Spec.JVM == nil {
+		return resources, nil
+	}
+	if server.Spec.JVM.CPURequest != "" {
+		cpu, err := resource.ParseQuantity(server.Spec.JVM.CPURequest)
+		if err != nil {
+			return resources, errors.Wrap(err, "parsing CPU request")
+		}
+		resources.Requests[corev1.ResourceCPU] = cpu
+	}
+	if server.Spec.JVM.CPULimit != "" {
+		cpu, err := resource.ParseQuantity(server.Spec.JVM.CPULimit)
+		if err != nil {
+			return resources, errors.Wrap(err, "parsing CPU limit")
+		}
+		resources.Limits[corev1.ResourceCPU] = cpu
+	}
+	return resources, nil
+}
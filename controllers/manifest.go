@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/initializer"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// ConditionInitSucceeded tells if the init container of the last Pod prepared the Server
+const ConditionInitSucceeded = "InitSucceeded"
+
+// buildInitManifest builds the manifest for the init binary, which prepares /data for the Server
+func buildInitManifest(server *v1.Server, serverProperties string) *initializer.Manifest {
+	manifest := &initializer.Manifest{
+		DataDir:   "/data",
+		WorldDir:  path.Join("/worlds", helpers.WorldPath(server, activeWorld(server))),
+		ServerDir: path.Join("/jars/server", serverVersion(server)),
+		Eula:      true,
+		// the files the game changes are kept on the Server's volume, and linked into /data
+		StateDir:   stateDir(server),
+		StateMount: stateMountPath,
+		Persisted:  buildPersisted(server),
+	}
+
+	buildUpdate(server, manifest)
+
+	if archive := worldArchiveName(server); archive != "" {
+		manifest.Archive = &initializer.Archive{
+			WorldDir:   manifest.WorldDir,
+			ArchiveDir: path.Join("/worlds/.archives", server.Name, archive),
+		}
+	}
+
+	mods := initializer.Directory{
+		Path:        "mods",
+		PruneSuffix: ".jar",
+	}
+	for _, mod := range server.Spec.ModJars {
+		mods.Artifacts = append(mods.Artifacts, initializer.Artifact{Source: path.Join("/jars/mods", mod)})
+	}
+	manifest.Directories = append(manifest.Directories, mods)
+
+	if plugins := pluginsDirectory(server); plugins != nil {
+		manifest.Directories = append(manifest.Directories, *plugins)
+	}
+	if geyser := bedrockDirectory(server); geyser != nil {
+		manifest.Directories = append(manifest.Directories, *geyser)
+	}
+
+	manifest.Files = append(manifest.Files, initializer.File{
+		Path:    "server.properties",
+		Content: serverProperties,
+		// the password comes from the Secret, so it doesn't end up in the ConfigMap
+		EnvProperties: map[string]string{"rcon.password": "RCON_PASSWORD"},
+	})
+
+	// the Files go last, so they can replace what the operator wrote
+	for index, file := range serverFiles(server) {
+		manifest.Files = append(manifest.Files, initializer.File{
+			Path:   file.Path,
+			Source: fileSource(index),
+		})
+	}
+
+	return manifest
+}
+
+// renderInitManifest renders the manifest for the init binary as JSON
+func renderInitManifest(server *v1.Server, serverProperties string) (string, error) {
+	manifest, err := json.MarshalIndent(buildInitManifest(server, serverProperties), "", "  ")
+	if err != nil {
+		return "", errors.Wrap(err, "serializing init manifest")
+	}
+	return string(manifest), nil
+}
+
+// UpdateInitStatus reports the outcome of the init container of the Server's Pod, with the reason it failed.
+// It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) UpdateInitStatus(ctx context.Context, log logr.Logger, server *v1.Server) {
+	log.V(loglevels.Verbose).Info("updating init status")
+
+	var podList corev1.PodList
+	err := r.List(ctx, &podList, client.InNamespace(server.Namespace),
+		client.MatchingLabels{"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name)})
+	if err != nil {
+		// non-critical error
+		log.V(loglevels.Info).Error(err, "failed to list pods for init status")
+		return
+	}
+
+	for _, pod := range podList.Items {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		for _, status := range pod.Status.InitContainerStatuses {
+			if status.Name != "init" {
+				continue
+			}
+			terminated := status.State.Terminated
+			if terminated == nil {
+				terminated = status.LastTerminationState.Terminated
+			}
+			if terminated == nil {
+				continue
+			}
+
+			if terminated.ExitCode == 0 {
+				meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+					Type:               ConditionInitSucceeded,
+					Status:             metav1.ConditionTrue,
+					ObservedGeneration: server.Generation,
+					Reason:             "Prepared",
+					Message:            "The init container prepared the Server",
+				})
+				return
+			}
+
+			log.V(loglevels.Info).Info("init container failed", "pod", pod.Name, "message", terminated.Message)
+			message := strings.TrimSpace(terminated.Message)
+			if message == "" {
+				message = fmt.Sprintf("The init container exited with %d", terminated.ExitCode)
+			}
+			meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+				Type:               ConditionInitSucceeded,
+				Status:             metav1.ConditionFalse,
+				ObservedGeneration: server.Generation,
+				Reason:             "InitFailed",
+				Message:            message,
+			})
+			return
+		}
+	}
+}
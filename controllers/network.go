@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sort"
+)
+
+// ConditionProxyForwarding tells if the Server accepts the players' identity from the proxy of its Network
+const ConditionProxyForwarding = "ProxyForwarding"
+
+// The keys of the forwarding Secret of a Network, next to the secret itself it holds the config of the Servers
+const (
+	forwardingSecretKey      = "forwarding.secret"
+	forwardingPaperKey       = "paper-global.yml"
+	forwardingFabricProxyKey = "FabricProxy-Lite.toml"
+	forwardingSpigotKey      = "spigot.yml"
+)
+
+// modernForwardingFlavors are the server types that take Velocity's modern forwarding, with the file to configure it
+var modernForwardingFlavors = map[string]v1.ServerFile{
+	"paper":      {Path: "config/paper-global.yml", SecretKeyRef: &corev1.SecretKeySelector{Key: forwardingPaperKey}},
+	"purpur":     {Path: "config/paper-global.yml", SecretKeyRef: &corev1.SecretKeySelector{Key: forwardingPaperKey}},
+	"pufferfish": {Path: "config/paper-global.yml", SecretKeyRef: &corev1.SecretKeySelector{Key: forwardingPaperKey}},
+	"folia":      {Path: "config/paper-global.yml", SecretKeyRef: &corev1.SecretKeySelector{Key: forwardingPaperKey}},
+	// these need the FabricProxy-Lite mod in the modJars
+	"fabric": {Path: "config/FabricProxy-Lite.toml", SecretKeyRef: &corev1.SecretKeySelector{Key: forwardingFabricProxyKey}},
+	"quilt":  {Path: "config/FabricProxy-Lite.toml", SecretKeyRef: &corev1.SecretKeySelector{Key: forwardingFabricProxyKey}},
+}
+
+// forwardingSecretName returns the name of the Secret with the forwarding secret of the Network
+func forwardingSecretName(networkName string) string {
+	return networkName + "-forwarding"
+}
+
+// serverAddress returns the address the Server is reached on in the cluster
+func serverAddress(server *v1.Server) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local:25565", server.Name, server.Namespace)
+}
+
+// networkSelects tells if the Network selects the Server
+func networkSelects(network *v1.Network, server *v1.Server) (bool, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&network.Spec.ServerSelector)
+	if err != nil {
+		return false, errors.Wrapf(err, "parsing serverSelector of Network %s", network.Name)
+	}
+	// an empty selector would take every Server in the namespace, which is more likely a mistake
+	return !selector.Empty() && selector.Matches(labels.Set(server.Labels)), nil
+}
+
+// forwardingMode returns how the proxy of the Network forwards the players' identity
+func forwardingMode(network *v1.Network) v1.ForwardingMode {
+	if network.Spec.Forwarding != "" {
+		return network.Spec.Forwarding
+	}
+	if network.Spec.Type == v1.ProxyBungeeCord {
+		return v1.ForwardingLegacy
+	}
+	return v1.ForwardingModern
+}
+
+// forwardingFile returns the file that makes the Server accept the forwarding of its Network, or nil when there's
+// nothing to configure. It tells if the server type supports the forwarding.
+func forwardingFile(server *v1.Server) (*v1.ServerFile, bool) {
+	if server.Status.Network == nil {
+		return nil, true
+	}
+	plugins, flavor := supportsPlugins(server)
+	switch server.Status.Network.Forwarding {
+	case v1.ForwardingModern:
+		file, ok := modernForwardingFlavors[flavor]
+		if !ok {
+			return nil, false
+		}
+		file.SecretKeyRef = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: forwardingSecretName(server.Status.Network.Name)},
+			Key:                  file.SecretKeyRef.Key,
+		}
+		return &file, true
+	case v1.ForwardingLegacy:
+		if !plugins {
+			return nil, false
+		}
+		return &v1.ServerFile{Path: "spigot.yml", SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: forwardingSecretName(server.Status.Network.Name)},
+			Key:                  forwardingSpigotKey,
+		}}, true
+	}
+	return nil, true
+}
+
+// networkFiles returns the files the Server needs to be behind the proxy of its Network
+func networkFiles(server *v1.Server) []v1.ServerFile {
+	if file, _ := forwardingFile(server); file != nil {
+		return []v1.ServerFile{*file}
+	}
+	return nil
+}
+
+// networkProperties returns the server.properties for a Server behind a proxy, which authenticates the players instead
+func networkProperties(server *v1.Server) map[string]string {
+	properties := make(map[string]string)
+	if server.Status.Network != nil {
+		properties["online-mode"] = "false"
+	}
+	return properties
+}
+
+// bypassesProxy tells if the Server behind a Network sets a host port, where players would join it in offline mode
+// without the proxy authenticating them
+func bypassesProxy(server *v1.Server) bool {
+	return server.Status.Network != nil && server.Spec.HostPort != 0
+}
+
+// serverHostPort returns the host port the Server binds to, none when it's behind the proxy of a Network
+func serverHostPort(server *v1.Server) int32 {
+	if server.Status.Network != nil {
+		return 0
+	}
+	return server.Spec.HostPort
+}
+
+// ReconcileNetwork finds the Network that selects the Server, when a Network selects it, and sets up the Server
+// for its proxy. When more Networks select the Server, the first by name gets it.
+// It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) ReconcileNetwork(ctx context.Context, log logr.Logger, server *v1.Server) error {
+	log.V(loglevels.Verbose).Info("start reconciling of network")
+
+	var networks v1.NetworkList
+	if err := r.List(ctx, &networks, client.InNamespace(server.Namespace)); err != nil {
+		return errors.Wrap(err, "listing Networks")
+	}
+	sort.Slice(networks.Items, func(i, j int) bool { return networks.Items[i].Name < networks.Items[j].Name })
+
+	var membership *v1.ServerNetworkStatus
+	for index := range networks.Items {
+		network := &networks.Items[index]
+		selected, err := networkSelects(network, server)
+		if err != nil {
+			log.V(loglevels.Info).Info("skipping Network", "network", network.Name, "error", err)
+			continue
+		}
+		if selected {
+			membership = &v1.ServerNetworkStatus{Name: network.Name, Forwarding: forwardingMode(network)}
+			break
+		}
+	}
+
+	switch {
+	case membership == nil && server.Status.Network != nil:
+		log.V(loglevels.Info).Info("server left its network", "network", server.Status.Network.Name)
+		r.normal(server, EventUpdated, "Left Network %s", server.Status.Network.Name)
+	case membership != nil && (server.Status.Network == nil || *server.Status.Network != *membership):
+		log.V(loglevels.Info).Info("server is behind a network", "network", membership.Name, "forwarding", membership.Forwarding)
+		r.normal(server, EventUpdated, "Behind the proxy of Network %s", membership.Name)
+	}
+	server.Status.Network = membership
+
+	if membership == nil {
+		meta.RemoveStatusCondition(&server.Status.Conditions, ConditionProxyForwarding)
+		return nil
+	}
+	if bypassesProxy(server) {
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionProxyForwarding,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "HostPortSet",
+			Message: fmt.Sprintf("hostPort %d would let players past the proxy of Network %s, the Server won't start until it's removed",
+				server.Spec.HostPort, membership.Name),
+		})
+		return nil
+	}
+	if _, supported := forwardingFile(server); !supported {
+		_, flavor := supportsPlugins(server)
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionProxyForwarding,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "Unsupported",
+			Message: fmt.Sprintf("Server type %q doesn't support %s forwarding, the proxy might not get players in",
+				flavor, membership.Forwarding),
+		})
+		return nil
+	}
+	meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+		Type:               ConditionProxyForwarding,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: server.Generation,
+		Reason:             string(membership.Forwarding),
+		Message:            fmt.Sprintf("Players join through the proxy of Network %s", membership.Name),
+	})
+	return nil
+}
+
+// serversForNetwork returns the requests for the Servers in the namespace of the Network, as its selector decides
+// which of them are behind it
+func (r *ServerReconciler) serversForNetwork(object client.Object) []reconcile.Request {
+	var servers v1.ServerList
+	if err := r.List(context.Background(), &servers, client.InNamespace(object.GetNamespace())); err != nil {
+		r.Log.V(loglevels.Error).Error(err, "failed to list Servers for network", "network", object.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, server := range servers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Name: server.Name, Namespace: server.Namespace}})
+	}
+	return requests
+}
@@ -0,0 +1,495 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	minecraftv1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"sort"
+	"strings"
+	"time"
+)
+
+//go:embed assets/velocity.toml.tmpl
+var velocityConfigTemplate string
+
+//go:embed assets/bungeecord.yml.tmpl
+var bungeeCordConfigTemplate string
+
+// ConditionNetworkConfigured tells if the proxy of the Network can be configured as the spec asks
+const ConditionNetworkConfigured = "Configured"
+
+const (
+	// defaultProxyImage runs Velocity or BungeeCord, depending on the TYPE environment variable
+	defaultProxyImage = "itzg/mc-proxy"
+	// proxyPort is the port the proxy listens on
+	proxyPort = 25577
+	// renderedHashAnnotation holds the hash of the rendered object of the proxy, to see when it has to be replaced
+	renderedHashAnnotation = "minecraft-operator/rendered-hash"
+)
+
+// paperForwardingTemplate is config/paper-global.yml for a Paper Server behind Velocity. Paper adds the other
+// settings with their defaults.
+const paperForwardingTemplate = `# Generated by the minecraft-operator
+proxies:
+  velocity:
+    enabled: true
+    online-mode: true
+    secret: %s
+`
+
+// fabricProxyForwardingTemplate is config/FabricProxy-Lite.toml for a Fabric Server behind Velocity
+const fabricProxyForwardingTemplate = `# Generated by the minecraft-operator
+hackOnlineMode = true
+secret = %s
+`
+
+// spigotForwardingConfig is spigot.yml for a Server of the Bukkit family behind BungeeCord. Spigot adds the other
+// settings with their defaults.
+const spigotForwardingConfig = `# Generated by the minecraft-operator
+settings:
+  bungeecord: true
+`
+
+// NetworkReconciler reconciles a Network object
+type NetworkReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=minecraft.hsmade.com,resources=networks,verbs=get;list;watch
+//+kubebuilder:rbac:groups=minecraft.hsmade.com,resources=networks/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=minecraft.hsmade.com,resources=networks/finalizers,verbs=update
+
+// proxyName returns the name of the Deployment, Service and ConfigMap of the proxy of the Network
+func proxyName(network *minecraftv1.Network) string {
+	return network.Name + "-proxy"
+}
+
+// proxyLabels returns the labels of the proxy of the Network
+func proxyLabels(network *minecraftv1.Network) map[string]string {
+	return map[string]string{
+		"app": fmt.Sprintf("minecraft-operator-network-%s", network.Name),
+	}
+}
+
+// quote quotes the string for the proxy config, a JSON string is a TOML and YAML string as well
+func quote(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted)
+}
+
+// event records an Event on the Network, when the reconciler has a recorder
+func (r *NetworkReconciler) event(network *minecraftv1.Network, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder == nil {
+		return
+	}
+	r.Recorder.Eventf(network, eventType, reason, messageFmt, args...)
+}
+
+// Reconcile runs the proxy of the Network, with a config for the running Servers it selects
+func (r *NetworkReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("network", req.NamespacedName)
+	log.V(loglevels.Verbose).Info("start reconciling loop")
+
+	var network minecraftv1.Network
+	log.V(loglevels.Flow).Info("fetching Network manifest")
+	if err := r.Get(ctx, req.NamespacedName, &network); err != nil {
+		// the proxy goes with the Network, as it owns it
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	log.V(loglevels.Trace).Info("got Network manifest", "network", network)
+
+	if network.Spec.Type == minecraftv1.ProxyBungeeCord && forwardingMode(&network) == minecraftv1.ForwardingModern {
+		log.V(loglevels.Info).Info("BungeeCord doesn't support modern forwarding")
+		meta.SetStatusCondition(&network.Status.Conditions, metav1.Condition{
+			Type:               ConditionNetworkConfigured,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: network.Generation,
+			Reason:             "InvalidForwarding",
+			Message:            "BungeeCord doesn't support Modern forwarding, use Legacy or None",
+		})
+		if err := r.Status().Update(ctx, &network); err != nil {
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, errors.Wrap(err, "storing status")
+		}
+		return ctrl.Result{}, nil
+	}
+
+	err := r.ReconcileForwardingSecret(ctx, log, &network)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile forwarding secret, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	network.Status.Servers, err = r.networkServers(ctx, &network)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to find servers, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	err = r.ReconcileProxyConfigMap(ctx, log, &network)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile proxy configMap, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	err = r.ReconcileProxyDeployment(ctx, log, &network)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile proxy Deployment, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	err = r.ReconcileProxyService(ctx, log, &network)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile proxy Service, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	meta.SetStatusCondition(&network.Status.Conditions, metav1.Condition{
+		Type:               ConditionNetworkConfigured,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: network.Generation,
+		Reason:             "Configured",
+		Message:            fmt.Sprintf("The proxy sends players to %d running Servers", len(runningServers(&network))),
+	})
+	log.V(loglevels.Verbose).Info("storing status")
+	if err := r.Status().Update(ctx, &network); err != nil {
+		log.V(loglevels.Error).Error(err, "failed to update Network status, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	// return for requeue
+	return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+}
+
+// networkServers returns the Servers that are behind the Network, by name. The Servers decide which Network they're
+// behind, so a Server that more Networks select is behind one of them only.
+func (r *NetworkReconciler) networkServers(ctx context.Context, network *minecraftv1.Network) ([]minecraftv1.NetworkServer, error) {
+	var servers minecraftv1.ServerList
+	if err := r.List(ctx, &servers, client.InNamespace(network.Namespace)); err != nil {
+		return nil, errors.Wrap(err, "listing Servers")
+	}
+	var result []minecraftv1.NetworkServer
+	for index := range servers.Items {
+		server := &servers.Items[index]
+		if server.Status.Network == nil || server.Status.Network.Name != network.Name {
+			continue
+		}
+		result = append(result, minecraftv1.NetworkServer{
+			Name:    server.Name,
+			Address: serverAddress(server),
+			Running: server.Status.Running,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// runningServers returns the Servers of the Network that are running, only those are in the config of the proxy
+func runningServers(network *minecraftv1.Network) []minecraftv1.NetworkServer {
+	var running []minecraftv1.NetworkServer
+	for _, server := range network.Status.Servers {
+		if server.Running {
+			running = append(running, server)
+		}
+	}
+	return running
+}
+
+// tryOrder returns the running Servers players join, in order
+func tryOrder(network *minecraftv1.Network) []string {
+	running := make(map[string]bool)
+	var names []string
+	for _, server := range runningServers(network) {
+		running[server.Name] = true
+		names = append(names, server.Name)
+	}
+	if len(network.Spec.Try) == 0 {
+		return names
+	}
+	var try []string
+	for _, name := range network.Spec.Try {
+		if running[name] {
+			try = append(try, name)
+		}
+	}
+	return try
+}
+
+// applyProxyObject creates the rendered object of the proxy, or replaces the existing one when the rendered object
+// changed. It's compared by the hash of what was rendered, as the API server adds defaults to what's stored.
+func (r *NetworkReconciler) applyProxyObject(ctx context.Context, log logr.Logger, network *minecraftv1.Network, kind string,
+	rendered, existing client.Object) error {
+	rendered.SetLabels(proxyLabels(network))
+	hash, err := renderedHash(rendered)
+	if err != nil {
+		return errors.Wrapf(err, "hashing %s", kind)
+	}
+	rendered.SetAnnotations(map[string]string{renderedHashAnnotation: hash})
+	if err := ctrl.SetControllerReference(network, rendered, r.Scheme); err != nil {
+		return errors.Wrapf(err, "setting controller reference for %s", kind)
+	}
+
+	log.V(loglevels.Flow).Info("fetching "+kind, "name", rendered.GetName())
+	err = r.Get(ctx, client.ObjectKeyFromObject(rendered), existing)
+	if apierrors.IsNotFound(err) {
+		log.V(loglevels.Info).Info(kind+" not found, creating new one", "name", rendered.GetName())
+		if err := r.Create(ctx, rendered); err != nil {
+			return errors.Wrapf(err, "creating %s", kind)
+		}
+		r.event(network, corev1.EventTypeNormal, EventCreated, "Created %s %s", kind, rendered.GetName())
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "getting %s", kind)
+	}
+
+	if existing.GetAnnotations()[renderedHashAnnotation] == rendered.GetAnnotations()[renderedHashAnnotation] {
+		log.V(loglevels.Flow).Info(kind+" is already up to date", "name", rendered.GetName())
+		return nil
+	}
+	log.V(loglevels.Info).Info("replacing "+kind, "name", rendered.GetName())
+	rendered.SetResourceVersion(existing.GetResourceVersion())
+	if err := r.Update(ctx, rendered); err != nil {
+		return errors.Wrapf(err, "replacing %s", kind)
+	}
+	r.event(network, corev1.EventTypeNormal, EventUpdated, "Updated %s %s", kind, rendered.GetName())
+	return nil
+}
+
+// ReconcileForwardingSecret makes sure the Secret with the forwarding secret exists, with the config for the Servers
+// that's made with it. The forwarding secret is generated once, so it's never replaced.
+func (r *NetworkReconciler) ReconcileForwardingSecret(ctx context.Context, log logr.Logger, network *minecraftv1.Network) error {
+	log.V(loglevels.Verbose).Info("start reconciling of forwarding secret")
+
+	var existing corev1.Secret
+	err := r.Get(ctx, client.ObjectKey{Name: forwardingSecretName(network.Name), Namespace: network.Namespace}, &existing)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "getting forwarding secret")
+	}
+	forwardingSecret := string(existing.Data[forwardingSecretKey])
+	if forwardingSecret == "" {
+		log.V(loglevels.Flow).Info("generating forwarding secret")
+		random := make([]byte, 16)
+		if _, err := rand.Read(random); err != nil {
+			return errors.Wrap(err, "generating forwarding secret")
+		}
+		forwardingSecret = hex.EncodeToString(random)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: forwardingSecretName(network.Name), Namespace: network.Namespace},
+		Type:       corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			forwardingSecretKey:      []byte(forwardingSecret),
+			forwardingPaperKey:       []byte(fmt.Sprintf(paperForwardingTemplate, quote(forwardingSecret))),
+			forwardingFabricProxyKey: []byte(fmt.Sprintf(fabricProxyForwardingTemplate, quote(forwardingSecret))),
+			forwardingSpigotKey:      []byte(spigotForwardingConfig),
+		},
+	}
+	return r.applyProxyObject(ctx, log, network, "Secret", secret, &existing)
+}
+
+// RenderProxyConfig renders the config file of the proxy, and returns its name
+func RenderProxyConfig(network *minecraftv1.Network) (string, string, error) {
+	var servers []minecraftv1.NetworkServer
+	for _, server := range runningServers(network) {
+		servers = append(servers, minecraftv1.NetworkServer{Name: quote(server.Name), Address: quote(server.Address)})
+	}
+	var try []string
+	for _, name := range tryOrder(network) {
+		try = append(try, quote(name))
+	}
+	data := map[string]interface{}{
+		"Motd":       quote(network.Spec.Motd),
+		"MaxPlayers": network.Spec.MaxPlayers,
+		"Servers":    servers,
+		"Try":        try,
+		"Forwarding": quote(strings.ToLower(string(forwardingMode(network)))),
+		"IPForward":  forwardingMode(network) == minecraftv1.ForwardingLegacy,
+	}
+
+	name, configTemplate := "velocity.toml", velocityConfigTemplate
+	if network.Spec.Type == minecraftv1.ProxyBungeeCord {
+		name, configTemplate = "config.yml", bungeeCordConfigTemplate
+	}
+	err, config := helpers.RenderTemplate(configTemplate, data)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "rendering %s", name)
+	}
+	return name, config, nil
+}
+
+// ReconcileProxyConfigMap makes sure the ConfigMap with the config of the proxy is up to date, and stores the hash
+// of the config in the status
+func (r *NetworkReconciler) ReconcileProxyConfigMap(ctx context.Context, log logr.Logger, network *minecraftv1.Network) error {
+	log.V(loglevels.Verbose).Info("start reconciling of proxy configMap")
+
+	name, config, err := RenderProxyConfig(network)
+	if err != nil {
+		return err
+	}
+	log.V(loglevels.Trace).Info("rendered proxy config", "name", name, "config", config)
+	hash := sha256.Sum256([]byte(config))
+	network.Status.ConfigHash = hex.EncodeToString(hash[:])
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: proxyName(network), Namespace: network.Namespace},
+		Data:       map[string]string{name: config},
+	}
+	return r.applyProxyObject(ctx, log, network, "ConfigMap", configMap, &corev1.ConfigMap{})
+}
+
+// RenderProxyDeployment renders the Deployment of the proxy
+func RenderProxyDeployment(network *minecraftv1.Network) *appsv1.Deployment {
+	image := network.Spec.Image
+	if image == "" {
+		image = defaultProxyImage
+	}
+	proxyType := "VELOCITY"
+	if network.Spec.Type == minecraftv1.ProxyBungeeCord {
+		proxyType = "BUNGEECORD"
+	}
+	var replicas int32 = 1
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: proxyName(network), Namespace: network.Namespace},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Strategy: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+			Selector: &metav1.LabelSelector{MatchLabels: proxyLabels(network)},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: proxyLabels(network),
+					// the proxy restarts when its config changes, as it only reads it when it starts
+					Annotations: map[string]string{"checksum/config": network.Status.ConfigHash},
+				},
+				Spec: corev1.PodSpec{
+					Volumes: []corev1.Volume{
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								Projected: &corev1.ProjectedVolumeSource{
+									Sources: []corev1.VolumeProjection{
+										{ConfigMap: &corev1.ConfigMapProjection{
+											LocalObjectReference: corev1.LocalObjectReference{Name: proxyName(network)},
+										}},
+										{Secret: &corev1.SecretProjection{
+											LocalObjectReference: corev1.LocalObjectReference{Name: forwardingSecretName(network.Name)},
+											Items:                []corev1.KeyToPath{{Key: forwardingSecretKey, Path: forwardingSecretKey}},
+										}},
+									},
+								},
+							},
+						},
+					},
+					Containers: []corev1.Container{
+						{
+							Name:  "proxy",
+							Image: image,
+							Env: []corev1.EnvVar{
+								{Name: "TYPE", Value: proxyType},
+								{Name: "MEMORY", Value: fmt.Sprintf("%dm", network.Spec.MaxMemory)},
+							},
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "tcp-minecraft",
+									ContainerPort: proxyPort,
+									HostPort:      network.Spec.HostPort,
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{Name: "config", MountPath: "/config", ReadOnly: true},
+							},
+							ReadinessProbe: &corev1.Probe{
+								Handler: corev1.Handler{
+									TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(proxyPort)},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// ReconcileProxyDeployment makes sure the proxy runs with the current config
+func (r *NetworkReconciler) ReconcileProxyDeployment(ctx context.Context, log logr.Logger, network *minecraftv1.Network) error {
+	log.V(loglevels.Verbose).Info("start reconciling of proxy Deployment")
+
+	var existing appsv1.Deployment
+	if err := r.applyProxyObject(ctx, log, network, "Deployment", RenderProxyDeployment(network), &existing); err != nil {
+		return err
+	}
+	network.Status.Ready = existing.Status.ReadyReplicas > 0
+	return nil
+}
+
+// ReconcileProxyService makes sure the proxy can be reached in the cluster
+func (r *NetworkReconciler) ReconcileProxyService(ctx context.Context, log logr.Logger, network *minecraftv1.Network) error {
+	log.V(loglevels.Verbose).Info("start reconciling of proxy Service")
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: proxyName(network), Namespace: network.Namespace},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: proxyLabels(network),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "tcp-minecraft",
+					Port:       25565,
+					TargetPort: intstr.FromInt(proxyPort),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+		},
+	}
+	return r.applyProxyObject(ctx, log, network, "Service", service, &corev1.Service{})
+}
+
+// networkForServer returns the request for the Network the Server is behind. It's called for the old and the new
+// Server on updates, so the Network a Server leaves is reconciled as well.
+func (r *NetworkReconciler) networkForServer(object client.Object) []reconcile.Request {
+	server, ok := object.(*minecraftv1.Server)
+	if !ok || server.Status.Network == nil {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: server.Status.Network.Name, Namespace: server.Namespace}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NetworkReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&minecraftv1.Network{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Owns(&corev1.Service{}).
+		Watches(&source.Kind{Type: &minecraftv1.Server{}}, handler.EnqueueRequestsFromMapFunc(r.networkForServer)).
+		Complete(r)
+}
@@ -0,0 +1,168 @@
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/notify"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// EventNotificationFailed is the reason of the Events for notifications that couldn't be sent
+const EventNotificationFailed = "NotificationFailed"
+
+//+kubebuilder:rbac:groups=minecraft.hsmade.com,resources=notificationchannels,verbs=get;list;watch
+
+// playerChanges returns the players that joined and left between the previous and the current players
+func playerChanges(previous, current []string) (joined, left []string) {
+	seen := make(map[string]bool, len(previous))
+	for _, player := range previous {
+		seen[player] = true
+	}
+	for _, player := range current {
+		if !seen[player] {
+			joined = append(joined, player)
+		}
+		delete(seen, player)
+	}
+	for _, player := range previous {
+		if seen[player] {
+			left = append(left, player)
+		}
+	}
+	return joined, left
+}
+
+// NotifyStatusChanges sends the notifications for the Server coming online or going offline, and for the players that
+// joined and left. A running Server only goes offline once it's disabled or missed pingFailureThreshold pings.
+func (r *ServerReconciler) NotifyStatusChanges(ctx context.Context, log logr.Logger, server *v1.Server, wasRunning bool, previousPlayers []string) {
+	switch {
+	case server.Status.Running && !wasRunning:
+		r.Notify(ctx, log, server, notify.Event{Type: v1.NotificationServerOnline})
+	case !server.Status.Running && wasRunning:
+		r.Notify(ctx, log, server, notify.Event{Type: v1.NotificationServerOffline})
+	}
+
+	// a Server that went offline lost its players, that's one notification
+	if !server.Status.Running {
+		return
+	}
+	joined, left := playerChanges(previousPlayers, server.Status.Players)
+	for _, player := range joined {
+		r.Notify(ctx, log, server, notify.Event{Type: v1.NotificationPlayerJoined, Player: player})
+	}
+	for _, player := range left {
+		r.Notify(ctx, log, server, notify.Event{Type: v1.NotificationPlayerLeft, Player: player})
+	}
+}
+
+// Notify sends the event to the NotificationChannels for the Server. The messages are sent in the background, so a
+// slow chat service doesn't hold up the reconciling; failures show up as Events on the Server.
+func (r *ServerReconciler) Notify(ctx context.Context, log logr.Logger, server *v1.Server, event notify.Event) {
+	if r.Notifier == nil {
+		return
+	}
+	event.Namespace = server.Namespace
+	event.Server = server.Name
+
+	log.V(loglevels.Flow).Info("looking up notification channels", "event", event.Type)
+	var channels v1.NotificationChannelList
+	if err := r.List(ctx, &channels, client.InNamespace(server.Namespace)); err != nil {
+		log.V(loglevels.Info).Error(err, "failed to list notification channels")
+		return
+	}
+
+	for _, channel := range channels.Items {
+		if !notifies(&channel, server, event.Type) {
+			continue
+		}
+		resolved, err := r.resolveChannel(ctx, &channel)
+		if err != nil {
+			log.V(loglevels.Info).Error(err, "failed to resolve notification channel", "channel", channel.Name)
+			r.warning(server, EventNotificationFailed, "Notification channel %s: %s", channel.Name, err)
+			continue
+		}
+
+		log.V(loglevels.Verbose).Info("sending notification", "channel", channel.Name, "event", event.Type)
+		go func(server *v1.Server, name string, resolved notify.Channel) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			err := r.Notifier.Send(ctx, resolved, event)
+			if err == notify.ErrRateLimited {
+				log.V(loglevels.Info).Info("dropped notification, the channel reached its rate limit", "channel", name, "event", event.Type)
+				return
+			}
+			if err != nil {
+				log.V(loglevels.Info).Error(err, "failed to send notification", "channel", name, "event", event.Type)
+				r.warning(server, EventNotificationFailed, "Notification channel %s: %s", name, err)
+			}
+		}(server.DeepCopy(), channel.Name, resolved)
+	}
+}
+
+// notifies tells if the channel wants the event of the Server
+func notifies(channel *v1.NotificationChannel, server *v1.Server, event v1.NotificationEvent) bool {
+	if len(channel.Spec.Servers) > 0 {
+		found := false
+		for _, name := range channel.Spec.Servers {
+			found = found || name == server.Name
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(channel.Spec.Events) == 0 {
+		return true
+	}
+	for _, wanted := range channel.Spec.Events {
+		if wanted == event {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveChannel reads the secrets of the channel
+func (r *ServerReconciler) resolveChannel(ctx context.Context, channel *v1.NotificationChannel) (notify.Channel, error) {
+	resolved := notify.Channel{
+		Name:               channel.Namespace + "/" + channel.Name,
+		Type:               channel.Spec.Type,
+		URL:                channel.Spec.URL,
+		Room:               channel.Spec.Room,
+		Templates:          channel.Spec.Templates,
+		RateLimitPerMinute: channel.Spec.RateLimitPerMinute,
+	}
+
+	if channel.Spec.URLSecretKeyRef != nil {
+		value, err := r.secretValue(ctx, channel.Namespace, channel.Spec.URLSecretKeyRef)
+		if err != nil {
+			return resolved, errors.Wrap(err, "reading url")
+		}
+		resolved.URL = value
+	}
+	if channel.Spec.TokenSecretKeyRef != nil {
+		value, err := r.secretValue(ctx, channel.Namespace, channel.Spec.TokenSecretKeyRef)
+		if err != nil {
+			return resolved, errors.Wrap(err, "reading token")
+		}
+		resolved.Token = value
+	}
+	return resolved, nil
+}
+
+// secretValue returns the value of a key of a Secret
+func (r *ServerReconciler) secretValue(ctx context.Context, namespace string, ref *corev1.SecretKeySelector) (string, error) {
+	var secret corev1.Secret
+	if err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: namespace}, &secret); err != nil {
+		return "", errors.Wrapf(err, "getting secret %s", ref.Name)
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", errors.Errorf("key %s not found in secret %s", ref.Key, ref.Name)
+	}
+	return string(value), nil
+}
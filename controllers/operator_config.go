@@ -2,25 +2,190 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"github.com/go-logr/logr"
 	minecraftv1 "github.com/hsmade/minecraft-operator/api/v1"
 	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"sync"
 	"time"
 )
 
+// ConditionJarPVCsFound tells if the OperatorConfig for the Server's namespace has the jar PVCs in that namespace
+const ConditionJarPVCsFound = "JarPVCsFound"
+
+// OperatorSettings are the settings of an OperatorConfig, with the objects it refers to
+type OperatorSettings struct {
+	// Namespace and Name are of the OperatorConfig
+	Namespace string
+	Name      string
+
+	ModJarsPVC         *corev1.PersistentVolumeClaim
+	ServerJarsPVC      *corev1.PersistentVolumeClaim
+	PluginJarsPVC      *corev1.PersistentVolumeClaim
+	ServerPV           *corev1.PersistentVolume
+	InitContainerImage string
+	InitImage          string
+	ResourcePackURL    string
+	Capacity           minecraftv1.CapacitySpec
+	JavaImages         []minecraftv1.JavaImageRule
+}
+
 var (
+	// Config holds the settings of the operator itself, they're set from the command line
 	Config struct {
-		ModJarsPVC         *corev1.PersistentVolumeClaim
-		ServerJarsPVC      *corev1.PersistentVolumeClaim
-		ServerPV           *corev1.PersistentVolume
-		InitContainerImage string
+		// ResourcePacksDir is where the resource packs are mounted in the operator
+		ResourcePacksDir string
+		// DefaultConfigNamespace is the namespace of the OperatorConfig for the namespaces without one
+		DefaultConfigNamespace string
+		// InitImage is the image the operator runs from, which is the init image when the OperatorConfig doesn't say
+		InitImage string
+	}
+
+	// operatorConfigs are the settings of the OperatorConfigs, by their namespace
+	operatorConfigs = struct {
+		sync.RWMutex
+		byNamespace map[string]*OperatorSettings
+	}{byNamespace: make(map[string]*OperatorSettings)}
+)
+
+// ConfigFor returns the settings for the Servers in the namespace: those of the OperatorConfig in the namespace,
+// or else of the one in the default namespace, or else of the only OperatorConfig. Pods can only mount claims in
+// their own namespace, so the settings of an OperatorConfig in another namespace come without the jar PVCs.
+// Without any, the settings are empty, apart from the defaults.
+func ConfigFor(namespace string) *OperatorSettings {
+	operatorConfigs.RLock()
+	defer operatorConfigs.RUnlock()
+	if settings, ok := operatorConfigs.byNamespace[namespace]; ok {
+		return settings
+	}
+	if settings, ok := operatorConfigs.byNamespace[Config.DefaultConfigNamespace]; ok && Config.DefaultConfigNamespace != "" {
+		return withoutClaims(settings)
+	}
+	if len(operatorConfigs.byNamespace) == 1 {
+		for _, settings := range operatorConfigs.byNamespace {
+			return withoutClaims(settings)
+		}
+	}
+	return &OperatorSettings{InitContainerImage: defaultInitContainerImage, InitImage: ownInitImage()}
+}
+
+// withoutClaims returns a copy of the settings without the PersistentVolumeClaims
+func withoutClaims(settings *OperatorSettings) *OperatorSettings {
+	shared := *settings
+	shared.ModJarsPVC = nil
+	shared.ServerJarsPVC = nil
+	shared.PluginJarsPVC = nil
+	return &shared
+}
+
+// sameConfig tells if the settings come from the same OperatorConfig
+func sameConfig(a, b *OperatorSettings) bool {
+	return a.Namespace == b.Namespace && a.Name == b.Name
+}
+
+// UpdateOperatorConfigStatus sets the condition that tells if the Server's namespace has the jar PVCs
+func (r *ServerReconciler) UpdateOperatorConfigStatus(log logr.Logger, server *minecraftv1.Server) {
+	log.V(loglevels.Verbose).Info("updating OperatorConfig status")
+
+	config := ConfigFor(server.Namespace)
+	condition := metav1.Condition{
+		Type:               ConditionJarPVCsFound,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: server.Generation,
+		Reason:             "JarPVCsFound",
+		Message:            fmt.Sprintf("The jar PVCs come from OperatorConfig %s/%s", config.Namespace, config.Name),
+	}
+	switch {
+	case config.Name == "":
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NoOperatorConfig"
+		condition.Message = "No OperatorConfig is loaded"
+	case config.Namespace != server.Namespace:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "OperatorConfigInOtherNamespace"
+		condition.Message = fmt.Sprintf("OperatorConfig %s/%s is in another namespace, and its jar PVCs can't be mounted here: create an OperatorConfig in %s, with jar PVCs in %s",
+			config.Namespace, config.Name, server.Namespace, server.Namespace)
+	}
+	meta.SetStatusCondition(&server.Status.Conditions, condition)
+}
+
+// storeConfig stores the settings of an OperatorConfig. A namespace has one OperatorConfig, when there are more
+// the first by name is used.
+func storeConfig(log logr.Logger, settings *OperatorSettings) {
+	operatorConfigs.Lock()
+	defer operatorConfigs.Unlock()
+	if existing, ok := operatorConfigs.byNamespace[settings.Namespace]; ok && existing.Name < settings.Name {
+		log.V(loglevels.Info).Info("ignoring OperatorConfig, the namespace has another one", "using", existing.Name)
+		return
+	}
+	operatorConfigs.byNamespace[settings.Namespace] = settings
+}
+
+// removeConfig forgets the settings of a removed OperatorConfig
+func removeConfig(namespace, name string) {
+	operatorConfigs.Lock()
+	defer operatorConfigs.Unlock()
+	if existing, ok := operatorConfigs.byNamespace[namespace]; ok && existing.Name == name {
+		delete(operatorConfigs.byNamespace, namespace)
 	}
+}
+
+const (
+	// defaultInitContainerImage is the image of the helper containers, when the OperatorConfig doesn't say
+	defaultInitContainerImage = "busybox"
+	// defaultInitImage is the image of the init container, when the OperatorConfig doesn't say and the operator
+	// doesn't know its own image
+	defaultInitImage = "hsmade/minecraft-operator:latest"
+	// managerContainer is the name of the operator's container in its Pod
+	managerContainer = "manager"
 )
 
+// ownInitImage returns the image for the init container, when the OperatorConfig doesn't say: the operator's own
+// image, so the init binary matches the operator
+func ownInitImage() string {
+	if Config.InitImage != "" {
+		return Config.InitImage
+	}
+	return defaultInitImage
+}
+
+// OwnImage returns the image of the operator's container in its Pod, by digest when the Pod status has it, so a
+// moving tag like latest can't give the init container another version
+func OwnImage(ctx context.Context, reader client.Reader, namespace, name string) (string, error) {
+	if namespace == "" || name == "" {
+		return "", errors.New("the Pod of the operator is unknown, POD_NAMESPACE and POD_NAME aren't set")
+	}
+	var pod corev1.Pod
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, &pod); err != nil {
+		return "", errors.Wrap(err, "getting the Pod of the operator")
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != managerContainer {
+			continue
+		}
+		// docker reports docker-pullable://<repository>@sha256:<digest>, containerd <repository>@sha256:<digest>
+		imageID := strings.TrimPrefix(status.ImageID, "docker-pullable://")
+		if strings.Contains(imageID, "@sha256:") {
+			return imageID, nil
+		}
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == managerContainer {
+			return container.Image, nil
+		}
+	}
+	return "", errors.Errorf("the Pod of the operator has no %s container", managerContainer)
+}
+
 // OperatorConfigReconciler reconciles a OperatorConfig object
 type OperatorConfigReconciler struct {
 	client.Client
@@ -38,7 +203,7 @@ type OperatorConfigReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.7.2/pkg/reconcile
 //
-// This reconciler finds the referenced objects and stores them in a global var
+// This reconciler finds the referenced objects and stores them as the settings for the Servers in its namespace
 func (r *OperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("OperatorConfig", req.NamespacedName)
 	log.V(loglevels.Verbose).Info("start reconciling loop")
@@ -50,18 +215,24 @@ func (r *OperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		// we'll ignore not-found errors, since they can't be fixed by an immediate
 		// requeue (we'll need to wait for a new notification), and we can get them
 		// on deleted requests.
+		if apierrors.IsNotFound(err) {
+			log.V(loglevels.Info).Info("OperatorConfig is removed, forgetting its settings")
+			removeConfig(req.Namespace, req.Name)
+		}
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 	log.V(loglevels.Flow).Info("fetched OperatorConfig manifest ok")
 	log.V(loglevels.Trace).Info("got OperatorConfig manifest", "OperatorConfig", OperatorConfig)
 
+	settings := &OperatorSettings{Namespace: OperatorConfig.Namespace, Name: OperatorConfig.Name}
+
 	var serverJarsPVC corev1.PersistentVolumeClaim
 	log.V(loglevels.Flow).Info("Looking for Server Jar PVC", "pvc-name", OperatorConfig.Spec.ServerJarsPVC)
 	if err := r.Get(ctx, client.ObjectKey{Name: OperatorConfig.Spec.ServerJarsPVC, Namespace: OperatorConfig.Namespace}, &serverJarsPVC); err != nil {
 		log.V(loglevels.Flow).Error(err, "failed to find Server Jar PVC", "name", OperatorConfig.Spec.ServerJarsPVC, "namespace", OperatorConfig.Namespace)
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
 	}
-	Config.ServerJarsPVC = &serverJarsPVC
+	settings.ServerJarsPVC = &serverJarsPVC
 	log.V(loglevels.Verbose).Info("found Server Jar PVC")
 
 	var modJarsPVC corev1.PersistentVolumeClaim
@@ -70,16 +241,46 @@ func (r *OperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		log.V(loglevels.Flow).Error(err, "failed to find Mod Jars PVC", "pvc-name", OperatorConfig.Spec.ModJarsPVC)
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
 	}
-	Config.ModJarsPVC = &modJarsPVC
+	settings.ModJarsPVC = &modJarsPVC
 	log.V(loglevels.Verbose).Info("found Mod Jars PVC")
 
-	Config.ServerPV = OperatorConfig.Spec.ServersPV
+	if OperatorConfig.Spec.PluginJarsPVC != "" {
+		var pluginJarsPVC corev1.PersistentVolumeClaim
+		log.V(loglevels.Flow).Info("Looking for Plugin Jars PVC", "pvc-name", OperatorConfig.Spec.PluginJarsPVC)
+		if err := r.Get(ctx, client.ObjectKey{Name: OperatorConfig.Spec.PluginJarsPVC, Namespace: OperatorConfig.Namespace}, &pluginJarsPVC); err != nil {
+			log.V(loglevels.Flow).Error(err, "failed to find Plugin Jars PVC", "pvc-name", OperatorConfig.Spec.PluginJarsPVC)
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+		}
+		settings.PluginJarsPVC = &pluginJarsPVC
+		log.V(loglevels.Verbose).Info("found Plugin Jars PVC")
+	}
+
+	settings.ServerPV = OperatorConfig.Spec.ServersPV
+
+	settings.InitContainerImage = OperatorConfig.Spec.InitContainerImage
+	if settings.InitContainerImage == "" {
+		settings.InitContainerImage = defaultInitContainerImage
+	}
+	log.V(loglevels.Verbose).Info("init container image set to " + settings.InitContainerImage)
 
-	Config.InitContainerImage = OperatorConfig.Spec.InitContainerImage
-	if Config.InitContainerImage == "" {
-		Config.InitContainerImage = "busybox"
+	settings.InitImage = OperatorConfig.Spec.InitImage
+	if settings.InitImage == "" {
+		settings.InitImage = ownInitImage()
 	}
-	log.V(loglevels.Verbose).Info("init container image set to " + Config.InitContainerImage)
+	log.V(loglevels.Verbose).Info("init image set to " + settings.InitImage)
+
+	settings.ResourcePackURL = OperatorConfig.Spec.ResourcePackURL
+	log.V(loglevels.Verbose).Info("resource pack url set to " + settings.ResourcePackURL)
+
+	if OperatorConfig.Spec.Capacity != nil {
+		settings.Capacity = *OperatorConfig.Spec.Capacity
+	}
+	log.V(loglevels.Verbose).Info("capacity set", "capacity", settings.Capacity)
+
+	settings.JavaImages = OperatorConfig.Spec.JavaImages
+	log.V(loglevels.Verbose).Info("java images set", "javaImages", settings.JavaImages)
+
+	storeConfig(log, settings)
 
 	// return for requeue
 	log.V(loglevels.Flow).Info("Reconcile done")
@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/initializer"
+	"path"
+	"strings"
+)
+
+// stateMountPath is where the server container mounts the state of the Server
+const stateMountPath = "/state"
+
+// persistedPaths are the files and directories every server type changes while it runs
+var persistedPaths = []string{
+	"ops.json",
+	"whitelist.json",
+	"banned-players.json",
+	"banned-ips.json",
+	"usercache.json",
+	"logs/",
+}
+
+// modPersistedPaths are kept for the server types that load mods, which write their configs into config/
+var modPersistedPaths = []string{
+	"config/",
+}
+
+// pluginPersistedPaths are kept for the Bukkit family, which keeps its configs next to the server, and the
+// plugin data in plugins/
+var pluginPersistedPaths = []string{
+	"plugins/",
+	"config/",
+	"bukkit.yml",
+	"spigot.yml",
+	"paper.yml",
+	"purpur.yml",
+	"pufferfish.yml",
+	"commands.yml",
+	"permissions.yml",
+	"help.yml",
+}
+
+// pluginDimensions are the directories next to world/ that the Bukkit family keeps the other dimensions in
+var pluginDimensions = []string{"world_nether/", "world_the_end/"}
+
+// modFlavors are the server types that load mods, including the hybrids that load plugins as well
+var modFlavors = map[string]bool{
+	"forge":     true,
+	"neoforge":  true,
+	"fabric":    true,
+	"quilt":     true,
+	"mohist":    true,
+	"magma":     true,
+	"arclight":  true,
+	"catserver": true,
+}
+
+// buildPersisted returns the paths of the Server that keep what the game changes across restarts: the ones of
+// its server type, and the ones in the spec. The dimensions of the Bukkit family belong to the active world,
+// so they're kept per world.
+func buildPersisted(server *v1.Server) []initializer.Persisted {
+	paths := append([]string{}, persistedPaths...)
+	plugins, flavor := supportsPlugins(server)
+	if modFlavors[flavor] {
+		paths = append(paths, modPersistedPaths...)
+	}
+	if plugins {
+		paths = append(paths, pluginPersistedPaths...)
+	}
+
+	var result []initializer.Persisted
+	seen := make(map[string]bool)
+	add := func(persisted initializer.Persisted) {
+		if seen[persisted.Path] {
+			return
+		}
+		seen[persisted.Path] = true
+		result = append(result, persisted)
+	}
+	if plugins {
+		for _, dimension := range pluginDimensions {
+			add(initializer.Persisted{
+				Path:      path.Clean(dimension),
+				State:     path.Join("worlds", activeWorld(server), dimension),
+				Directory: true,
+			})
+		}
+	}
+	for _, persistedPath := range append(paths, server.Spec.PersistedPaths...) {
+		add(initializer.Persisted{
+			Path:      path.Clean(persistedPath),
+			Directory: strings.HasSuffix(persistedPath, "/"),
+		})
+	}
+	return result
+}
+
+// stateDir returns where the init container finds the state of the Server, on the Server's volume
+func stateDir(server *v1.Server) string {
+	return path.Join("/worlds", helpers.StatePath(server))
+}
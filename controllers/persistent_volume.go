@@ -41,6 +41,7 @@ func (r *ServerReconciler) ReconcilePersistentVolume(ctx context.Context, log lo
 			return errors.Wrap(err, "creating PersistentVolume")
 		}
 		log.V(loglevels.Flow).Info("created PersistentVolume ok")
+		r.normal(server, EventCreated, "Created PersistentVolume %s", PersistentVolume.Name)
 		return nil
 	}
 
@@ -53,6 +54,7 @@ func (r *ServerReconciler) ReconcilePersistentVolume(ctx context.Context, log lo
 		if err != nil {
 			return errors.Wrap(err, "replacing PersistentVolume")
 		}
+		r.normal(server, EventUpdated, "Updated PersistentVolume %s", PersistentVolume.Name)
 	}
 	log.V(loglevels.Flow).Info("PersistentVolume is already up to date")
 
@@ -63,16 +65,18 @@ func (r *ServerReconciler) ReconcilePersistentVolume(ctx context.Context, log lo
 func (r *ServerReconciler) RenderPersistentVolume(log logr.Logger, server *v1.Server) (*corev1.PersistentVolume, error) {
 	log.V(loglevels.Verbose).Info("rendering PersistentVolume")
 
-	if Config.ServerPV == nil {
+	config := ConfigFor(server.Namespace)
+	if config.ServerPV == nil {
+		r.warning(server, EventOperatorConfigMissing, "The OperatorConfig isn't loaded, can't render the PersistentVolume")
 		return nil, errors.New("Operator config isn't initialised (yet)") // FIXME
 	}
 
-	log.V(loglevels.Trace).Info("checking for Config.ServersPV", "value", Config.ServerPV)
-	if Config.ServerPV == nil {
+	log.V(loglevels.Trace).Info("checking for Config.ServersPV", "value", config.ServerPV)
+	if config.ServerPV == nil {
 		return nil, errors.New("ServersPV is not set")
 	}
 
-	pv := Config.ServerPV.DeepCopy()
+	pv := config.ServerPV.DeepCopy()
 	pv.Name = server.Namespace + "-" + server.Name
 	pv.Labels = map[string]string{
 		"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name),
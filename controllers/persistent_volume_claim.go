@@ -44,6 +44,7 @@ func (r *ServerReconciler) ReconcilePersistentVolumeClaim(ctx context.Context, l
 			return errors.Wrap(err, "creating PersistentVolumeClaim")
 		}
 		log.V(loglevels.Flow).Info("created PersistentVolumeClaim ok")
+		r.normal(server, EventCreated, "Created PersistentVolumeClaim %s", PersistentVolumeClaim.Name)
 		return nil
 	}
 
@@ -58,7 +59,9 @@ func (r *ServerReconciler) ReconcilePersistentVolumeClaim(ctx context.Context, l
 				if err != nil {
 					// non-critical error
 					log.V(loglevels.Info).Error(err, "failed to delete PersistentVolumeClaim", "namespace", cm.Namespace, "name", cm.Name)
+					continue
 				}
+				r.normal(server, EventDeleted, "Deleted extra PersistentVolumeClaim %s", cm.Name)
 			}
 		}
 	}
@@ -72,6 +75,7 @@ func (r *ServerReconciler) ReconcilePersistentVolumeClaim(ctx context.Context, l
 		if err != nil {
 			return errors.Wrap(err, "replacing PersistentVolumeClaim")
 		}
+		r.normal(server, EventUpdated, "Updated PersistentVolumeClaim %s", PersistentVolumeClaim.Name)
 	}
 	log.V(loglevels.Flow).Info("PersistentVolumeClaim is already up to date")
 
@@ -82,12 +86,14 @@ func (r *ServerReconciler) ReconcilePersistentVolumeClaim(ctx context.Context, l
 func (r *ServerReconciler) RenderPersistentVolumeClaim(log logr.Logger, server *v1.Server) (*corev1.PersistentVolumeClaim, error) {
 	log.V(loglevels.Verbose).Info("rendering PersistentVolumeClaim")
 
-	if Config.ServerPV == nil || Config.ModJarsPVC == nil || Config.ServerJarsPVC == nil {
+	config := ConfigFor(server.Namespace)
+	if config.ServerPV == nil || config.ModJarsPVC == nil || config.ServerJarsPVC == nil {
+		r.warning(server, EventOperatorConfigMissing, "The OperatorConfig isn't loaded, or has no jar PVCs in the namespace, can't render the PersistentVolumeClaim")
 		return nil, errors.New("Operator config isn't initialised (yet)") // FIXME
 	}
 
-	log.V(loglevels.Trace).Info("checking for Config.ServersPV", "value", Config.ServerPV)
-	if Config.ServerPV == nil {
+	log.V(loglevels.Trace).Info("checking for Config.ServersPV", "value", config.ServerPV)
+	if config.ServerPV == nil {
 		return nil, errors.New("ServersPV is not set")
 	}
 
@@ -111,8 +117,8 @@ func (r *ServerReconciler) RenderPersistentVolumeClaim(log logr.Logger, server *
 				},
 			},
 			VolumeName:       server.Namespace + "-" + server.Name,
-			StorageClassName: &Config.ServerPV.Spec.StorageClassName,
-			VolumeMode:       Config.ServerPV.Spec.VolumeMode,
+			StorageClassName: &config.ServerPV.Spec.StorageClassName,
+			VolumeMode:       config.ServerPV.Spec.VolumeMode,
 		},
 	}
 
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/initializer"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path"
+	"strings"
+)
+
+// ConditionPluginsSupported tells if the server type can run the plugins
+const ConditionPluginsSupported = "PluginsSupported"
+
+// pluginFlavors are the server types that load Bukkit plugins, including the hybrids that load mods as well
+var pluginFlavors = map[string]bool{
+	"bukkit":      true,
+	"craftbukkit": true,
+	"spigot":      true,
+	"paper":       true,
+	"purpur":      true,
+	"pufferfish":  true,
+	"folia":       true,
+	"mohist":      true,
+	"magma":       true,
+	"arclight":    true,
+	"catserver":   true,
+}
+
+// supportsPlugins tells if the server type of the Server loads plugins
+func supportsPlugins(server *v1.Server) (bool, string) {
+	flavor, _, err := helpers.ParseServerVersion(serverVersion(server))
+	if err != nil {
+		return false, ""
+	}
+	flavor = strings.ToLower(flavor)
+	return pluginFlavors[flavor], flavor
+}
+
+// validatePlugin checks that the plugin has exactly one source, and a checksum when it's downloaded
+func validatePlugin(config *OperatorSettings, plugin v1.Plugin) error {
+	switch {
+	case plugin.Name == "" || strings.ContainsAny(plugin.Name, `/\`) || plugin.Name == "." || plugin.Name == "..":
+		return errors.Errorf("plugin has an invalid name %q", plugin.Name)
+	case plugin.Jar != "" && plugin.URL != "":
+		return errors.Errorf("plugin %s has both a jar and a URL", plugin.Name)
+	case plugin.Jar == "" && plugin.URL == "":
+		return errors.Errorf("plugin %s needs a jar or a URL", plugin.Name)
+	case plugin.URL != "" && plugin.SHA256 == "":
+		return errors.Errorf("plugin %s is downloaded, so it needs a sha256", plugin.Name)
+	case plugin.Jar != "" && config.PluginJarsPVC == nil:
+		return errors.Errorf("plugin %s comes from the plugin jars PVC, which isn't configured", plugin.Name)
+	}
+	return nil
+}
+
+// validatePlugins checks the plugins of the Server against its server type
+func validatePlugins(server *v1.Server) error {
+	if len(server.Spec.Plugins) == 0 {
+		return nil
+	}
+	if supported, flavor := supportsPlugins(server); !supported {
+		return errors.Errorf("server type %q doesn't load plugins", flavor)
+	}
+	config := ConfigFor(server.Namespace)
+	for _, plugin := range server.Spec.Plugins {
+		if err := validatePlugin(config, plugin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pluginFiles returns the config files of the plugins, with their paths relative to the Server's directory
+func pluginFiles(server *v1.Server) []v1.ServerFile {
+	if validatePlugins(server) != nil {
+		return nil
+	}
+	var files []v1.ServerFile
+	for _, plugin := range server.Spec.Plugins {
+		for _, file := range plugin.Files {
+			file.Path = path.Join("plugins", plugin.Name, file.Path)
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// serverFiles returns all extra files of the Server: the ones of the plugins, the Network and Geyser first, so the
+// Files can replace them
+func serverFiles(server *v1.Server) []v1.ServerFile {
+	files := append(pluginFiles(server), networkFiles(server)...)
+	files = append(files, bedrockFiles(server)...)
+	return append(files, server.Spec.Files...)
+}
+
+// pluginsDirectory returns the plugins/ directory for the init manifest, or nil when the server type has no plugins
+func pluginsDirectory(server *v1.Server) *initializer.Directory {
+	if supported, _ := supportsPlugins(server); !supported {
+		return nil
+	}
+
+	directory := &initializer.Directory{
+		Path:        "plugins",
+		PruneSuffix: ".jar",
+	}
+	var plugins []v1.Plugin
+	// don't start with a partial set of plugins
+	if validatePlugins(server) == nil {
+		plugins = append(plugins, server.Spec.Plugins...)
+	}
+	for _, plugin := range append(plugins, bedrockPlugins(server)...) {
+		artifact := initializer.Artifact{
+			Name:   plugin.Name + ".jar",
+			SHA256: plugin.SHA256,
+		}
+		if plugin.URL != "" {
+			artifact.URL = plugin.URL
+		} else {
+			artifact.Source = path.Join("/jars/plugins", plugin.Jar)
+		}
+		directory.Artifacts = append(directory.Artifacts, artifact)
+	}
+	return directory
+}
+
+// UpdatePluginStatus flags plugins that the server type can't run, or that are configured wrong.
+// It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) UpdatePluginStatus(log logr.Logger, server *v1.Server) {
+	log.V(loglevels.Verbose).Info("updating plugin status")
+
+	if len(server.Spec.Plugins) == 0 {
+		meta.RemoveStatusCondition(&server.Status.Conditions, ConditionPluginsSupported)
+		return
+	}
+
+	if err := validatePlugins(server); err != nil {
+		log.V(loglevels.Info).Info("plugins can't be installed", "error", err)
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionPluginsSupported,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "InvalidPlugins",
+			Message:            fmt.Sprintf("%s, no plugins are installed", err),
+		})
+		return
+	}
+
+	meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+		Type:               ConditionPluginsSupported,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: server.Generation,
+		Reason:             "Supported",
+		Message:            "The server type loads plugins",
+	})
+}
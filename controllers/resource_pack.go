@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"sync"
+)
+
+// ConditionResourcePackReady tells if the resource pack of the Server is offered to the players
+const ConditionResourcePackReady = "ResourcePackReady"
+
+// ResourcePackFile is the content of a resource pack
+type ResourcePackFile interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// bytesFile is a resource pack from a ConfigMap
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (bytesFile) Close() error { return nil }
+
+// resourcePackHashes caches the SHA1 of the resource packs by Server, so they're only read again when they change
+var resourcePackHashes = struct {
+	sync.Mutex
+	byServer map[types.UID]resourcePackHash
+}{byServer: make(map[types.UID]resourcePackHash)}
+
+// resourcePackHash is the SHA1 of a version of a resource pack
+type resourcePackHash struct {
+	version string
+	sha1    string
+}
+
+// ResourcePackPath returns the path the web UI serves the resource pack of the Server on
+func ResourcePackPath(server *v1.Server) string {
+	return fmt.Sprintf("/resourcepacks/%s/%s.zip", server.Namespace, server.Name)
+}
+
+// OpenResourcePack opens the resource pack of the Server. It also returns a version, that changes with the content.
+func OpenResourcePack(ctx context.Context, c client.Client, server *v1.Server) (ResourcePackFile, string, error) {
+	pack := server.Spec.ResourcePack
+	switch {
+	case pack == nil:
+		return nil, "", errors.New("server has no resource pack")
+
+	case pack.ConfigMapKeyRef != nil && pack.Path != "":
+		return nil, "", errors.New("resource pack has both a configMap and a path")
+
+	case pack.ConfigMapKeyRef != nil:
+		var configMap corev1.ConfigMap
+		err := c.Get(ctx, client.ObjectKey{Name: pack.ConfigMapKeyRef.Name, Namespace: server.Namespace}, &configMap)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "getting configMap %s", pack.ConfigMapKeyRef.Name)
+		}
+		content, ok := configMap.BinaryData[pack.ConfigMapKeyRef.Key]
+		if !ok {
+			value, ok := configMap.Data[pack.ConfigMapKeyRef.Key]
+			if !ok {
+				return nil, "", errors.Errorf("key %s not found in configMap %s", pack.ConfigMapKeyRef.Key, pack.ConfigMapKeyRef.Name)
+			}
+			content = []byte(value)
+		}
+		version := fmt.Sprintf("configmap/%s/%s/%s", configMap.Name, pack.ConfigMapKeyRef.Key, configMap.ResourceVersion)
+		return bytesFile{bytes.NewReader(content)}, version, nil
+
+	case pack.Path != "":
+		if Config.ResourcePacksDir == "" {
+			return nil, "", errors.New("the operator has no resource packs directory")
+		}
+		dir := filepath.Clean(Config.ResourcePacksDir)
+		path := filepath.Join(dir, pack.Path)
+		if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return nil, "", errors.Errorf("resource pack path %q points outside of the resource packs directory", pack.Path)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, "", errors.Wrap(err, "opening resource pack")
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, "", errors.Wrap(err, "opening resource pack")
+		}
+		if info.IsDir() {
+			file.Close()
+			return nil, "", errors.Errorf("resource pack %s is a directory", pack.Path)
+		}
+		version := fmt.Sprintf("path/%s/%d/%d", pack.Path, info.Size(), info.ModTime().UnixNano())
+		return file, version, nil
+	}
+	return nil, "", errors.New("resource pack needs a configMap or a path")
+}
+
+// resourcePackSHA1 returns the SHA1 of the resource pack of the Server, reading it only when it changed
+func (r *ServerReconciler) resourcePackSHA1(ctx context.Context, server *v1.Server) (string, error) {
+	file, version, err := OpenResourcePack(ctx, r.Client, server)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	resourcePackHashes.Lock()
+	cached, ok := resourcePackHashes.byServer[server.UID]
+	resourcePackHashes.Unlock()
+	if ok && cached.version == version {
+		return cached.sha1, nil
+	}
+
+	hash := sha1.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", errors.Wrap(err, "reading resource pack")
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	resourcePackHashes.Lock()
+	resourcePackHashes.byServer[server.UID] = resourcePackHash{version: version, sha1: sum}
+	resourcePackHashes.Unlock()
+	return sum, nil
+}
+
+// ReconcileResourcePack computes the SHA1 of the resource pack, for the server.properties. A resource pack that
+// can't be offered doesn't keep the Server from running, that's reported in the status instead.
+// It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) ReconcileResourcePack(ctx context.Context, log logr.Logger, server *v1.Server) {
+	log.V(loglevels.Verbose).Info("start reconciling of resource pack")
+
+	if server.Spec.ResourcePack == nil {
+		server.Status.ResourcePackSHA1 = ""
+		meta.RemoveStatusCondition(&server.Status.Conditions, ConditionResourcePackReady)
+		resourcePackHashes.Lock()
+		delete(resourcePackHashes.byServer, server.UID)
+		resourcePackHashes.Unlock()
+		return
+	}
+
+	notReady := func(reason, message string) {
+		log.V(loglevels.Info).Info("resource pack isn't offered", "reason", reason, "message", message)
+		server.Status.ResourcePackSHA1 = ""
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionResourcePackReady,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             reason,
+			Message:            message,
+		})
+	}
+
+	if ConfigFor(server.Namespace).ResourcePackURL == "" {
+		notReady("NoURL", "The OperatorConfig has no resource-pack-url, so players can't download the resource pack")
+		return
+	}
+
+	sum, err := r.resourcePackSHA1(ctx, server)
+	if err != nil {
+		notReady("Unavailable", err.Error())
+		return
+	}
+	server.Status.ResourcePackSHA1 = sum
+	log.V(loglevels.Flow).Info("resource pack ok", "sha1", sum)
+	meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+		Type:               ConditionResourcePackReady,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: server.Generation,
+		Reason:             "Served",
+		Message:            "The resource pack is served on " + resourcePackURL(server),
+	})
+}
+
+// resourcePackURL returns the URL players download the resource pack of the Server from
+func resourcePackURL(server *v1.Server) string {
+	return strings.TrimSuffix(ConfigFor(server.Namespace).ResourcePackURL, "/") + ResourcePackPath(server)
+}
+
+// resourcePackProperties returns the server.properties for the resource pack, when it's offered
+func resourcePackProperties(server *v1.Server) map[string]string {
+	properties := make(map[string]string)
+	if server.Spec.ResourcePack == nil || server.Status.ResourcePackSHA1 == "" {
+		return properties
+	}
+
+	// the hash in the query makes clients that cache by URL fetch a changed pack
+	properties["resource-pack"] = resourcePackURL(server) + "?sha1=" + url.QueryEscape(server.Status.ResourcePackSHA1)
+	properties["resource-pack-sha1"] = server.Status.ResourcePackSHA1
+	properties["require-resource-pack"] = fmt.Sprintf("%t", server.Spec.ResourcePack.Required)
+	if server.Spec.ResourcePack.Prompt != "" {
+		// the prompt is a text component, and a JSON string is the plain text one. The backslashes of its escapes
+		// are escaped again, as the properties file unescapes them
+		prompt, _ := json.Marshal(server.Spec.ResourcePack.Prompt)
+		properties["resource-pack-prompt"] = strings.ReplaceAll(string(prompt), `\`, `\\`)
+	}
+	return properties
+}
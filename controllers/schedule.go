@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// ConditionInSchedule tells if the Server is inside one of its schedule windows
+const ConditionInSchedule = "InSchedule"
+
+// inAutoStartWindow tells if the Server is inside a schedule window that starts it automatically
+func inAutoStartWindow(server *v1.Server) bool {
+	if server.Spec.Schedule == nil {
+		return false
+	}
+	window, _, err := helpers.ActiveScheduleWindow(server.Spec.Schedule, time.Now())
+	return err == nil && window != nil && window.AutoStart
+}
+
+// ReconcileSchedule disables the Server outside of its schedule windows, which gracefully stops it,
+// and enables it once when an auto start window opens.
+func (r *ServerReconciler) ReconcileSchedule(ctx context.Context, log logr.Logger, server *v1.Server) error {
+	log.V(loglevels.Verbose).Info("start reconciling of schedule")
+
+	if server.Spec.Schedule == nil {
+		log.V(loglevels.Flow).Info("no schedule set")
+		meta.RemoveStatusCondition(&server.Status.Conditions, ConditionInSchedule)
+		return nil
+	}
+
+	window, start, err := helpers.ActiveScheduleWindow(server.Spec.Schedule, time.Now())
+	if err != nil {
+		// the schedule can't be fixed by retrying, so we report it and leave the Server alone
+		log.V(loglevels.Info).Error(err, "invalid schedule, ignoring it")
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionInSchedule,
+			Status:             metav1.ConditionUnknown,
+			ObservedGeneration: server.Generation,
+			Reason:             "InvalidSchedule",
+			Message:            err.Error(),
+		})
+		return nil
+	}
+
+	if window == nil {
+		if server.Spec.Enabled {
+			log.V(loglevels.Info).Info("server is outside of its schedule, shutting down Pod")
+			log.V(loglevels.Verbose).Info("setting server enable to false")
+			err = r.setEnabled(ctx, server, false)
+			if err != nil {
+				return errors.Wrap(err, "disabling server")
+			}
+			r.normal(server, EventScheduleStop, "The Server is outside of its schedule, stopping it")
+		}
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionInSchedule,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "OutsideSchedule",
+			Message:            "The Server is outside of its schedule windows, and can't be started",
+		})
+		return nil
+	}
+
+	if window.AutoStart && server.Status.ScheduledStart != start.Unix() {
+		if !server.Spec.Enabled {
+			log.V(loglevels.Info).Info("schedule window opened, starting server", "start", start)
+			log.V(loglevels.Verbose).Info("setting server enable to true")
+			err = r.setEnabled(ctx, server, true)
+			if err != nil {
+				return errors.Wrap(err, "enabling server")
+			}
+			r.normal(server, EventScheduleStart, "Schedule window opened, starting the Server")
+		}
+		// only start once per window, so stopping it by hand sticks
+		server.Status.ScheduledStart = start.Unix()
+	}
+
+	meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+		Type:               ConditionInSchedule,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: server.Generation,
+		Reason:             "InsideSchedule",
+		Message:            "The Server is inside one of its schedule windows",
+	})
+	return nil
+}
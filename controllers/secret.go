@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/rcon"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReconcileSecret make sure the RCON secret exists.
+// The password is generated once, so we never replace an existing secret.
+func (r *ServerReconciler) ReconcileSecret(ctx context.Context, log logr.Logger, server *v1.Server) error {
+	log.V(loglevels.Verbose).Info("start reconciling of secret")
+
+	log.V(loglevels.Flow).Info("fetching secret manifest")
+	var existingSecret corev1.Secret
+	err := r.Get(ctx, client.ObjectKey{Name: rcon.SecretName(server), Namespace: server.Namespace}, &existingSecret)
+	if err == nil {
+		log.V(loglevels.Flow).Info("secret already exists")
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to get secret")
+	}
+
+	log.V(loglevels.Flow).Info("render secret")
+	secret, err := r.RenderSecret(log, server)
+	if err != nil {
+		return errors.Wrap(err, "rendering secret")
+	}
+	log.V(loglevels.Flow).Info("rendered secret ok")
+
+	log.V(loglevels.Info).Info("secret not found, creating new one")
+	err = r.Client.Create(ctx, secret)
+	if err != nil {
+		return errors.Wrap(err, "creating secret")
+	}
+	log.V(loglevels.Flow).Info("created secret ok")
+	r.normal(server, EventCreated, "Created Secret %s", secret.Name)
+
+	return nil
+}
+
+// RenderSecret renders the secret holding a freshly generated RCON password for the Server
+func (r *ServerReconciler) RenderSecret(log logr.Logger, server *v1.Server) (*corev1.Secret, error) {
+	log.V(loglevels.Verbose).Info("rendering secret")
+
+	log.V(loglevels.Flow).Info("generating RCON password")
+	password := make([]byte, 16)
+	if _, err := rand.Read(password); err != nil {
+		return nil, errors.Wrap(err, "generating RCON password")
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{
+				"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name),
+			},
+			Annotations: make(map[string]string),
+			Name:        rcon.SecretName(server),
+			Namespace:   server.Namespace,
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			rcon.PasswordKey: []byte(hex.EncodeToString(password)),
+		},
+	}
+	log.V(loglevels.Flow).Info("rendered secret ok")
+
+	log.V(loglevels.Verbose).Info("setting controller reference for secret")
+	if err := ctrl.SetControllerReference(server, secret, r.Scheme); err != nil {
+		log.Info("ERROR failed to set owner reference", "error", err)
+		return nil, err
+	}
+	log.V(loglevels.Flow).Info("set controller reference ok for secret")
+
+	return secret, nil
+}
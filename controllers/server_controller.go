@@ -18,7 +18,9 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/notify"
 	v1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"time"
@@ -26,8 +28,12 @@ import (
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	minecraftv1 "github.com/hsmade/minecraft-operator/api/v1"
 )
@@ -35,8 +41,24 @@ import (
 // ServerReconciler reconciles a Server object
 type ServerReconciler struct {
 	client.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Notifier *notify.Notifier
+
+	// RestConfig is used for the API calls the client doesn't do, like reading logs
+	RestConfig *rest.Config
+
+	// APIReader reads from the API server instead of the cache, for decisions that can't be made on stale data
+	APIReader client.Reader
+}
+
+// apiReader returns the APIReader, or the client when there's none
+func (r *ServerReconciler) apiReader() client.Reader {
+	if r.APIReader == nil {
+		return r.Client
+	}
+	return r.APIReader
 }
 
 var (
@@ -52,12 +74,16 @@ var (
 //+kubebuilder:rbac:groups="apps",resources=deployments/status,verbs=get
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=configmaps/status,verbs=get
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups="",resources=services/status,verbs=get
 //+kubebuilder:rbac:groups="",resources=persistentvolumes,verbs=get;list;watch;create;delete;update
 //+kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;delete;update
-//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;create;delete
 //+kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+//+kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+//+kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;patch
+//+kubebuilder:rbac:groups="authorization.k8s.io",resources=subjectaccessreviews,verbs=create
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -80,7 +106,22 @@ func (r *ServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	log.V(loglevels.Flow).Info("fetched Server manifest ok")
 	log.V(loglevels.Trace).Info("got server manifest", "server", server)
 
-	err := r.ReconcilePersistentVolume(ctx, log, &server)
+	// the rest of the reconcile works with the spec of the Server merged with its template
+	err := r.ReconcileTemplate(ctx, log, &server)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile template, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	err = r.ReconcileSchedule(ctx, log, &server)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile schedule, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	r.ReconcileUpdate(ctx, log, &server)
+
+	err = r.ReconcilePersistentVolume(ctx, log, &server)
 	if err != nil {
 		log.V(loglevels.Error).Error(err, "failed to reconcile PV, retrying in 30s")
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
@@ -92,12 +133,46 @@ func (r *ServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
 	}
 
+	err = r.ReconcileSecret(ctx, log, &server)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile secret, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	err = r.ReconcileNetwork(ctx, log, &server)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile network, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	err = r.ReconcileFiles(ctx, log, &server)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile files, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	r.ReconcileResourcePack(ctx, log, &server)
+
 	err = r.ReconcileConfigMap(ctx, log, &server)
 	if err != nil {
 		log.V(loglevels.Error).Error(err, "failed to reconcile configMap, retrying in 30s")
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
 	}
 
+	err = r.ReconcileCapacity(ctx, log, &server)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile capacity, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	err = r.ReconcileCrashes(ctx, log, &server)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile crashes, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	r.ReconcileShutdown(ctx, log, &server)
+
 	err = r.ReconcileDeployment(ctx, log, &server)
 	if err != nil {
 		log.V(loglevels.Error).Error(err, "failed to reconcile Pod, retrying in 30s")
@@ -116,22 +191,39 @@ func (r *ServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
 	}
 
-	if server.Spec.Enabled && server.Spec.IdleTimeoutSeconds > 0 {
+	err = r.ReconcileSessions(ctx, log, &server)
+	if err != nil {
+		log.V(loglevels.Error).Error(err, "failed to reconcile sessions, retrying in 30s")
+		return ctrl.Result{RequeueAfter: 30 * time.Second}, err
+	}
+
+	if server.Spec.Enabled && server.Spec.IdleTimeoutSeconds > 0 && inAutoStartWindow(&server) {
+		log.V(loglevels.Flow).Info("server is in an auto start window, skipping idle timeout")
+	} else if server.Spec.Enabled && server.Spec.IdleTimeoutSeconds > 0 {
 		log.V(loglevels.Flow).Info("checking for idle timeout")
 		log.V(loglevels.Trace).Info("checking for idle timeout", "idleTime",
 			server.Status.IdleTime, "IdleTimeoutSeconds", server.Spec.IdleTimeoutSeconds)
 		if server.Status.IdleTime > 0 && time.Now().Unix()-server.Status.IdleTime > server.Spec.IdleTimeoutSeconds {
 			log.V(loglevels.Info).Info("server idle timeout reached, shutting down Pod")
 			log.V(loglevels.Verbose).Info("setting server enable to false")
-			server.Spec.Enabled = false
-			err = r.Client.Update(ctx, &server)
+			err = r.setEnabled(ctx, &server, false)
 			if err != nil {
 				log.V(loglevels.Error).Error(err, "failed to update Server, retrying in 30s")
 				return ctrl.Result{RequeueAfter: 30 * time.Second}, err
 			}
+			r.normal(&server, EventIdleTimeout, "No players for %ds, stopping the Server", server.Spec.IdleTimeoutSeconds)
+			r.Notify(ctx, log, &server, notify.Event{
+				Type:    minecraftv1.NotificationIdleShutdown,
+				Message: fmt.Sprintf("no players for %s", time.Duration(server.Spec.IdleTimeoutSeconds)*time.Second),
+			})
 		}
 	}
 
+	if server.Status.Phase == minecraftv1.ServerPhaseStopping {
+		log.V(loglevels.Flow).Info("server is stopping, checking again in 5s")
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, err
+	}
+
 	// return for requeue
 	return ctrl.Result{RequeueAfter: 30 * time.Second}, err
 }
@@ -210,5 +302,7 @@ func (r *ServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&minecraftv1.Server{}).
+		Watches(&source.Kind{Type: &minecraftv1.ServerTemplate{}}, handler.EnqueueRequestsFromMapFunc(r.serversForTemplate)).
+		Watches(&source.Kind{Type: &minecraftv1.Network{}}, handler.EnqueueRequestsFromMapFunc(r.serversForNetwork)).
 		Complete(r)
 }
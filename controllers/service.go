@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/go-logr/logr"
 	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/bedrock"
 	"github.com/hsmade/minecraft-operator/controllers/helpers"
 	"github.com/hsmade/minecraft-operator/loglevels"
 	"github.com/pkg/errors"
@@ -41,6 +42,7 @@ func (r *ServerReconciler) ReconcileService(ctx context.Context, log logr.Logger
 			return errors.Wrap(err, "creating service")
 		}
 		log.V(loglevels.Flow).Info("created service ok")
+		r.normal(server, EventCreated, "Created Service %s", service.Name)
 		return nil
 	}
 
@@ -55,15 +57,44 @@ func (r *ServerReconciler) ReconcileService(ctx context.Context, log logr.Logger
 				if err != nil {
 					// non-critical error
 					log.V(loglevels.Info).Error(err, "failed to delete service", "namespace", cm.Namespace, "name", cm.Name)
+					continue
 				}
+				r.normal(server, EventDeleted, "Deleted extra Service %s", cm.Name)
 			}
 		}
 	}
 
-	// we don't update the service, as the user can't change anything in the Server Spec that changes here
+	// the user can't change anything in the Server Spec that changes here, so only the ports can be outdated
+	log.V(loglevels.Flow).Info("comparing service ports with the rendered ports")
+	if !servicePortsEqual(service.Spec.Ports, servicesList.Items[0].Spec.Ports) {
+		log.V(loglevels.Info).Info("updating service ports")
+		log.V(loglevels.Trace).Info("updating service ports", "rendered", service.Spec.Ports, "found", servicesList.Items[0].Spec.Ports)
+		servicesList.Items[0].Spec.Ports = service.Spec.Ports
+		err = r.Client.Update(ctx, &servicesList.Items[0])
+		if err != nil {
+			return errors.Wrap(err, "updating service")
+		}
+		r.normal(server, EventUpdated, "Updated the ports of Service %s", service.Name)
+	}
+	log.V(loglevels.Flow).Info("service is already up to date")
+
 	return nil
 }
 
+// servicePortsEqual compares the ports we render with the ones found, ignoring the fields the API server defaults
+func servicePortsEqual(rendered, found []corev1.ServicePort) bool {
+	if len(rendered) != len(found) {
+		return false
+	}
+	for index := range rendered {
+		if rendered[index].Name != found[index].Name || rendered[index].Port != found[index].Port ||
+			servicePortProtocol(rendered[index]) != servicePortProtocol(found[index]) {
+			return false
+		}
+	}
+	return true
+}
+
 // RenderService renders the service used for the Server's Pod
 func (r *ServerReconciler) RenderService(log logr.Logger, server *v1.Server) (*corev1.Service, error) {
 	log.V(loglevels.Verbose).Info("rendering service")
@@ -77,15 +108,6 @@ func (r *ServerReconciler) RenderService(log logr.Logger, server *v1.Server) (*c
 		serverProperties, "template", serverPropertiesTemplate, "data", server.Spec.Properties)
 	log.V(loglevels.Flow).Info("rendered server.properties ok")
 
-	log.V(loglevels.Flow).Info("rendering init.sh")
-	err, initScript := helpers.RenderTemplate(initScriptTemplate, server.Spec)
-	if err != nil {
-		return nil, errors.Wrap(err, "rendering init.sh")
-	}
-	log.V(loglevels.Trace).Info("rendered init.sh", "result",
-		initScript, "template", initScriptTemplate, "data", server.Spec)
-	log.V(loglevels.Flow).Info("rendered init.sh ok")
-
 	log.V(loglevels.Flow).Info("rendering service")
 	service := &corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
@@ -96,16 +118,26 @@ func (r *ServerReconciler) RenderService(log logr.Logger, server *v1.Server) (*c
 		},
 		Spec: corev1.ServiceSpec{
 			Type: corev1.ServiceTypeClusterIP,
-			Ports: []corev1.ServicePort{{
-				Name: "tcp-minecraft",
-				Port: 25565,
-			}},
+			Ports: []corev1.ServicePort{
+				{
+					Name: "tcp-minecraft",
+					Port: 25565,
+				},
+			},
 			Selector: map[string]string{
 				"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name),
 				// FIXME: need more
 			},
 		},
 	}
+	if bedrockActive(server) {
+		log.V(loglevels.Flow).Info("adding bedrock port")
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name:     "udp-bedrock",
+			Port:     bedrock.Port,
+			Protocol: corev1.ProtocolUDP,
+		})
+	}
 	log.V(loglevels.Flow).Info("rendered service ok")
 
 	log.V(loglevels.Verbose).Info("setting controller reference for service")
@@ -117,3 +149,11 @@ func (r *ServerReconciler) RenderService(log logr.Logger, server *v1.Server) (*c
 
 	return service, nil
 }
+
+// servicePortProtocol returns the protocol of the port, which the API server defaults to TCP
+func servicePortProtocol(port corev1.ServicePort) corev1.Protocol {
+	if port.Protocol == "" {
+		return corev1.ProtocolTCP
+	}
+	return port.Protocol
+}
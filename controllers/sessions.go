@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/rcon"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"strings"
+	"time"
+)
+
+// SessionsKey is the key in the sessions ConfigMap that holds the sessions, as JSON
+const SessionsKey = "sessions.json"
+
+// EventPlaytimeLimit is the reason of the Events for players that got kicked for their playtime
+const EventPlaytimeLimit = "PlaytimeLimit"
+
+// sessionRetention is how long ended sessions are kept, enough for the playtime of the last weeks
+const sessionRetention = 35 * 24 * time.Hour
+
+// defaultPlaytimeMessage is what kicked players see, when the limit has no message
+const defaultPlaytimeMessage = "You reached your playtime for today, see you tomorrow!"
+
+// SessionsConfigMapName returns the name of the ConfigMap with the player sessions of the Server
+func SessionsConfigMapName(server *v1.Server) string {
+	return server.Name + "-sessions"
+}
+
+// ReadSessions returns the player sessions of the Server. A Server without sessions has none
+func ReadSessions(ctx context.Context, c client.Client, server *v1.Server) ([]helpers.Session, error) {
+	var configMap corev1.ConfigMap
+	err := c.Get(ctx, client.ObjectKey{Name: SessionsConfigMapName(server), Namespace: server.Namespace}, &configMap)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "getting sessions configMap")
+	}
+	return parseSessions(&configMap)
+}
+
+// parseSessions reads the sessions from the ConfigMap
+func parseSessions(configMap *corev1.ConfigMap) ([]helpers.Session, error) {
+	var sessions []helpers.Session
+	content, ok := configMap.Data[SessionsKey]
+	if !ok || content == "" {
+		return nil, nil
+	}
+	if err := json.Unmarshal([]byte(content), &sessions); err != nil {
+		return nil, errors.Wrap(err, "parsing sessions")
+	}
+	return sessions, nil
+}
+
+// PlaytimeLocation returns the timezone the playtime days of the Server start in
+func PlaytimeLocation(server *v1.Server) (*time.Location, error) {
+	name := ""
+	if server.Spec.Schedule != nil {
+		name = server.Spec.Schedule.Timezone
+	}
+	if server.Spec.PlaytimeLimit != nil && server.Spec.PlaytimeLimit.Timezone != "" {
+		name = server.Spec.PlaytimeLimit.Timezone
+	}
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading timezone")
+	}
+	return location, nil
+}
+
+// DailyPlaytimeLimit returns the daily playtime of the player on the Server, or 0 when there's no limit
+func DailyPlaytimeLimit(server *v1.Server, player string) time.Duration {
+	limit := server.Spec.PlaytimeLimit
+	if limit == nil {
+		return 0
+	}
+	minutes := limit.DailyMinutes
+	if perPlayer, ok := limit.Players[player]; ok {
+		minutes = perPlayer
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// ReconcileSessions keeps the sessions of the players on the Server, from the players in the status, and kicks the
+// players that reached their daily playtime
+func (r *ServerReconciler) ReconcileSessions(ctx context.Context, log logr.Logger, server *v1.Server) error {
+	log.V(loglevels.Verbose).Info("start reconciling of sessions")
+	now := time.Now()
+
+	log.V(loglevels.Flow).Info("fetching sessions configMap")
+	var configMap corev1.ConfigMap
+	err := r.Get(ctx, client.ObjectKey{Name: SessionsConfigMapName(server), Namespace: server.Namespace}, &configMap)
+	found := err == nil
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrap(err, "getting sessions configMap")
+	}
+
+	sessions, err := parseSessions(&configMap)
+	if err != nil {
+		// the log can't be fixed by retrying, so it starts over
+		log.V(loglevels.Info).Error(err, "invalid sessions, starting a new log")
+		sessions = nil
+	}
+
+	// a stopped Server has no players, which ends their sessions. A running Server stays running until it misses
+	// pingFailureThreshold pings, so a missed ping doesn't split the sessions.
+	players := server.Status.Players
+	if !server.Status.Running {
+		log.V(loglevels.Flow).Info("server isn't running, ending the sessions", "failedPings", server.Status.FailedPings)
+		players = nil
+	}
+	sessions, updated := helpers.UpdateSessions(sessions, players, now)
+	sessions, pruned := helpers.PruneSessions(sessions, now.Add(-sessionRetention))
+
+	if updated || pruned || !found {
+		content, err := json.Marshal(sessions)
+		if err != nil {
+			return errors.Wrap(err, "serializing sessions")
+		}
+		if !found {
+			configMap = corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name),
+					},
+					Name:      SessionsConfigMapName(server),
+					Namespace: server.Namespace,
+				},
+			}
+			// not as controller, as the reconciler takes the ConfigMaps it controls for its config
+			log.V(loglevels.Verbose).Info("setting owner reference for sessions configMap")
+			if err := controllerutil.SetOwnerReference(server, &configMap, r.Scheme); err != nil {
+				return errors.Wrap(err, "setting owner reference")
+			}
+		}
+		configMap.Data = map[string]string{SessionsKey: string(content)}
+
+		if found {
+			log.V(loglevels.Flow).Info("updating sessions configMap", "sessions", len(sessions))
+			err = r.Client.Update(ctx, &configMap)
+		} else {
+			log.V(loglevels.Info).Info("sessions configMap not found, creating new one")
+			err = r.Client.Create(ctx, &configMap)
+		}
+		if err != nil {
+			return errors.Wrap(err, "storing sessions")
+		}
+	}
+
+	return r.enforcePlaytimeLimit(ctx, log, server, sessions, now)
+}
+
+// enforcePlaytimeLimit kicks the online players that played their daily playtime
+func (r *ServerReconciler) enforcePlaytimeLimit(ctx context.Context, log logr.Logger, server *v1.Server, sessions []helpers.Session, now time.Time) error {
+	limit := server.Spec.PlaytimeLimit
+	if limit == nil || !server.Status.Running || len(server.Status.Players) == 0 {
+		return nil
+	}
+
+	location, err := PlaytimeLocation(server)
+	if err != nil {
+		// the timezone can't be fixed by retrying
+		log.V(loglevels.Info).Error(err, "invalid playtime limit timezone, not enforcing it")
+		return nil
+	}
+	playtime := helpers.Playtime(sessions, helpers.StartOfDay(now.In(location)), now)
+
+	message := limit.Message
+	if message == "" {
+		message = defaultPlaytimeMessage
+	}
+	for _, player := range server.Status.Players {
+		daily := DailyPlaytimeLimit(server, player)
+		if daily <= 0 || playtime[player] < daily {
+			continue
+		}
+		if strings.ContainsAny(player, " \t\n") {
+			// not a player name, so it can't go into a command
+			continue
+		}
+
+		log.V(loglevels.Info).Info("kicking player that reached the playtime limit", "player", player, "playtime", playtime[player])
+		_, err := rcon.Command(ctx, r.Client, server, fmt.Sprintf("kick %s %s", player, message))
+		if err != nil {
+			return errors.Wrapf(err, "kicking %s", player)
+		}
+		r.normal(server, EventPlaytimeLimit, "Kicked %s, who played %s today", player, playtime[player].Round(time.Minute))
+	}
+	return nil
+}
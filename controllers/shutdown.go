@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/rcon"
+	"net"
+	"time"
+)
+
+const (
+	// defaultStopGracePeriodSeconds is the time players get warned before the Server stops
+	defaultStopGracePeriodSeconds = 30
+	// shutdownTimeoutSeconds is the time the Server gets to save and exit, before the Pod is removed anyway
+	shutdownTimeoutSeconds = 120
+)
+
+// stopWarnings are the number of seconds left at which players get another warning
+var stopWarnings = []int64{600, 300, 120, 60, 30, 10, 5}
+
+// preStopScript stops java when the Pod gets removed outside of the stop sequence. start.sh might not pass on the
+// SIGTERM, so we send it to java ourselves and wait for it to save and exit.
+// The [j]ava pattern keeps us from matching this script.
+const preStopScript = `for p in /proc/[0-9]*; do if grep -qs '[j]ava' "$p/cmdline"; then kill -TERM "${p#/proc/}"; fi; done
+while grep -qs '[j]ava' /proc/[0-9]*/cmdline; do sleep 1; done`
+
+// stopGracePeriod returns the time players get warned before the Server stops
+func stopGracePeriod(server *v1.Server) int64 {
+	if server.Spec.StopGracePeriodSeconds == nil {
+		return defaultStopGracePeriodSeconds
+	}
+	return *server.Spec.StopGracePeriodSeconds
+}
+
+// serverReachable tells if the Server is accepting connections
+func serverReachable(server *v1.Server) bool {
+	addr := fmt.Sprintf("%s.%s.svc.cluster.local:25565", server.Name, server.Namespace)
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// ReconcileShutdown runs the stop sequence for a Server that got disabled while running:
+// warn the players, save the world, stop the server and wait for it to exit.
+// While the sequence runs, the phase is Stopping, which keeps the Deployment scaled up.
+// When RCON fails, we give up on the sequence and leave it to the preStop hook.
+func (r *ServerReconciler) ReconcileShutdown(ctx context.Context, log logr.Logger, server *v1.Server) {
+	log.V(loglevels.Verbose).Info("start reconciling of shutdown")
+	now := time.Now().Unix()
+
+	if server.Spec.Enabled {
+		if server.Status.StopRequestedAt != 0 {
+			log.V(loglevels.Info).Info("server got enabled again, cancelling stop sequence")
+		}
+		server.Status.StopRequestedAt = 0
+		server.Status.StopAnnounced = 0
+		server.Status.StopIssuedAt = 0
+		return
+	}
+
+	if server.Status.StopIssuedAt != 0 && server.Status.Phase == v1.ServerPhaseStopped {
+		log.V(loglevels.Flow).Info("stop sequence finished")
+		return
+	}
+
+	if server.Status.StopRequestedAt == 0 {
+		if !server.Status.Running {
+			log.V(loglevels.Flow).Info("server isn't running, no need for a stop sequence")
+			server.Status.Phase = v1.ServerPhaseStopped
+			return
+		}
+		log.V(loglevels.Info).Info("server got disabled, starting stop sequence")
+		server.Status.StopRequestedAt = now
+	}
+
+	if server.Status.StopIssuedAt == 0 {
+		remaining := stopGracePeriod(server) - (now - server.Status.StopRequestedAt)
+		if remaining > 0 && len(server.Status.Players) > 0 {
+			log.V(loglevels.Flow).Info("warning players", "remaining", remaining)
+			r.announceStop(ctx, log, server, remaining)
+			server.Status.Phase = v1.ServerPhaseStopping
+			return
+		}
+
+		log.V(loglevels.Info).Info("saving world and stopping server")
+		server.Status.StopIssuedAt = now
+		_, err := rcon.Command(ctx, r.Client, server, "say Server is stopping now", "save-all flush", "stop")
+		if err != nil {
+			log.V(loglevels.Info).Error(err, "failed to stop server through RCON, leaving it to the preStop hook")
+			server.Status.Phase = v1.ServerPhaseStopped
+			return
+		}
+		server.Status.Phase = v1.ServerPhaseStopping
+		return
+	}
+
+	log.V(loglevels.Flow).Info("waiting for server to exit")
+	if now-server.Status.StopIssuedAt > shutdownTimeoutSeconds {
+		log.V(loglevels.Info).Info("server didn't exit in time, removing it anyway")
+		server.Status.Phase = v1.ServerPhaseStopped
+		return
+	}
+	if serverReachable(server) {
+		server.Status.Phase = v1.ServerPhaseStopping
+		return
+	}
+	log.V(loglevels.Info).Info("server exited, scaling down")
+	server.Status.Phase = v1.ServerPhaseStopped
+}
+
+// announceStop tells the players when the Server stops, at the first call and whenever we pass one of the warnings
+func (r *ServerReconciler) announceStop(ctx context.Context, log logr.Logger, server *v1.Server, remaining int64) {
+	announce := server.Status.StopAnnounced == 0
+	for _, warning := range stopWarnings {
+		if remaining <= warning && warning < server.Status.StopAnnounced {
+			announce = true
+		}
+	}
+	if !announce {
+		return
+	}
+
+	log.V(loglevels.Verbose).Info("announcing stop", "remaining", remaining)
+	_, err := rcon.Command(ctx, r.Client, server, fmt.Sprintf("say Server stops in %d seconds", remaining))
+	if err != nil {
+		// non-critical error
+		log.V(loglevels.Info).Error(err, "failed to warn players")
+	}
+	server.Status.StopAnnounced = remaining
+}
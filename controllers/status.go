@@ -6,7 +6,9 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/go-mc/mcping"
 	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
 	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/rcon"
 	"github.com/pkg/errors"
 	"time"
 )
@@ -15,16 +17,28 @@ import (
 func (r *ServerReconciler) UpdateStatus(ctx context.Context, log logr.Logger, server *v1.Server) error {
 	log.V(loglevels.Verbose).Info("start reconciling of Server status")
 
+	wasRunning := server.Status.Running
+	previousPlayers := server.Status.Players
+	previousPong := server.Status.LastPong
+
 	server.Status.Running = false
 	server.Status.LastPong = 0
 	server.Status.Players = []string{}
+	r.UpdateOperatorConfigStatus(log, server)
+	r.UpdateJavaStatus(log, server)
+	r.UpdatePluginStatus(log, server)
+	r.UpdateInitStatus(ctx, log, server)
 
 	if !server.Spec.Enabled {
 		log.V(loglevels.Flow).Info("server disabled, adjusting status")
+		server.Status.FailedPings = 0
+		r.UpdateBedrockStatus(log, server)
+		r.UpdateWorldStatus(ctx, log, server, false)
+		updatePhase(server)
 
 		log.V(loglevels.Verbose).Info("storing status")
 		log.V(loglevels.Trace).Info("server status", "status", server.Status)
-		err := r.Status().Update(ctx, server)
+		err := r.storeStatus(ctx, server)
 		if err != nil {
 			return errors.Wrap(err, "storing status")
 		}
@@ -36,22 +50,49 @@ func (r *ServerReconciler) UpdateStatus(ctx context.Context, log logr.Logger, se
 	status, _, err := mcping.PingAndList(addr, 578)
 	if err != nil {
 		log.V(loglevels.Info).Info("could not ping server", "error", err)
+		if server.Spec.Enabled {
+			server.Status.FailedPings++
+			// only once per streak, the counter keeps going
+			if server.Status.FailedPings == pingFailureThreshold {
+				r.warning(server, EventPingFailed, "The Server didn't answer %d pings in a row: %s", pingFailureThreshold, err)
+			}
+			// a running Server that misses a few pings is still running, going offline would end the sessions of
+			// its players and notify the channels for every missed ping
+			if wasRunning && server.Status.FailedPings < pingFailureThreshold {
+				log.V(loglevels.Flow).Info("server missed a ping, keeping it running", "failedPings", server.Status.FailedPings)
+				server.Status.Running = true
+				server.Status.LastPong = previousPong
+				server.Status.Players = previousPlayers
+			}
+		}
+		r.UpdateBedrockStatus(log, server)
+		r.UpdateWorldStatus(ctx, log, server, false)
+		updatePhase(server)
 
 		log.V(loglevels.Verbose).Info("storing status")
 		log.V(loglevels.Trace).Info("server status", "status", server.Status)
-		err := r.Status().Update(ctx, server)
+		err := r.storeStatus(ctx, server)
 		if err != nil {
 			return errors.Wrap(err, "storing status")
 		}
+		r.NotifyStatusChanges(ctx, log, server, wasRunning, previousPlayers)
 		return nil
 	}
 	log.V(loglevels.Flow).Info("pinged server ok")
 	log.V(loglevels.Trace).Info("server ping result", "status", status)
 
-	log.V(loglevels.Flow).Info("looping over found players sample to add to status")
-	server.Status.Players = []string{}
-	for _, player := range status.Players.Sample {
-		server.Status.Players = append(server.Status.Players, player.Name)
+	// the sample of the ping lists 12 players at most, so the players come from RCON
+	log.V(loglevels.Flow).Info("listing players through RCON")
+	responses, err := rcon.Command(ctx, r.Client, server, "list")
+	switch {
+	case err == nil:
+		server.Status.Players = helpers.ParsePlayerList(responses[0])
+	case status.Players.Online == 0:
+		log.V(loglevels.Info).Info("could not list players, but the ping shows none", "error", err)
+	default:
+		// keep the players, so their sessions don't end
+		log.V(loglevels.Info).Info("could not list players, keeping the previous ones", "error", err)
+		server.Status.Players = previousPlayers
 	}
 	log.V(loglevels.Trace).Info("players found", "players", server.Status.Players)
 
@@ -62,6 +103,7 @@ func (r *ServerReconciler) UpdateStatus(ctx context.Context, log logr.Logger, se
 
 	server.Status.LastPong = time.Now().Unix()
 	server.Status.Running = true
+	server.Status.FailedPings = 0
 
 	log.V(loglevels.Flow).Info("getting thumbnail from server status")
 	if status.Favicon == "" {
@@ -71,12 +113,38 @@ func (r *ServerReconciler) UpdateStatus(ctx context.Context, log logr.Logger, se
 		log.V(loglevels.Trace).Info("stored thumbnail", "thumbnail", server.Status.Thumbnail)
 	}
 
+	r.UpdateVersionStatus(ctx, log, server)
+	r.UpdateBedrockStatus(log, server)
+	r.UpdateWorldStatus(ctx, log, server, true)
+	updatePhase(server)
+
 	log.V(loglevels.Verbose).Info("storing status")
 	log.V(loglevels.Trace).Info("server status", "status", server.Status)
-	err = r.Status().Update(ctx, server)
+	err = r.storeStatus(ctx, server)
 	if err != nil {
 		return errors.Wrap(err, "storing status")
 	}
+	r.NotifyStatusChanges(ctx, log, server, wasRunning, previousPlayers)
 
 	return nil
 }
+
+// updatePhase sets the phase of an enabled Server from its status.
+// The phase of a disabled Server is up to the stop sequence, and queueing is up to the capacity manager.
+func updatePhase(server *v1.Server) {
+	if !server.Spec.Enabled {
+		if server.Status.Phase == "" {
+			server.Status.Phase = v1.ServerPhaseStopped
+		}
+		return
+	}
+	if server.Status.Phase == v1.ServerPhaseQueued {
+		// up to the capacity manager
+		return
+	}
+	if server.Status.Running {
+		server.Status.Phase = v1.ServerPhaseRunning
+		return
+	}
+	server.Status.Phase = v1.ServerPhaseStarting
+}
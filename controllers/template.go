@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+)
+
+// ConditionTemplateApplied tells if the ServerTemplate of the Server is merged into its spec
+const ConditionTemplateApplied = "TemplateApplied"
+
+const (
+	// EventTemplateNotApplied is the reason of the Events for a Server whose spec can't be made with its template
+	EventTemplateNotApplied = "TemplateNotApplied"
+	// EventTemplateOverridesIgnored is the reason of the Events for fields of a Server the template doesn't allow
+	EventTemplateOverridesIgnored = "TemplateOverridesIgnored"
+)
+
+//+kubebuilder:rbac:groups=minecraft.hsmade.com,resources=servertemplates,verbs=get;list;watch
+
+// setTemplateCondition sets the TemplateApplied condition, and warns about it when its reason changes
+func (r *ServerReconciler) setTemplateCondition(server *v1.Server, status metav1.ConditionStatus, reason, message string) {
+	condition := meta.FindStatusCondition(server.Status.Conditions, ConditionTemplateApplied)
+	changed := condition == nil || condition.Reason != reason || condition.Message != message
+	switch {
+	case !changed || reason == "Applied":
+	case status == metav1.ConditionTrue:
+		r.warning(server, EventTemplateOverridesIgnored, "%s", message)
+	default:
+		r.warning(server, EventTemplateNotApplied, "%s", message)
+	}
+	meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+		Type:               ConditionTemplateApplied,
+		Status:             status,
+		ObservedGeneration: server.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+// ReconcileTemplate merges the ServerTemplate of the Server into its spec, so the rest of the reconcile works with the
+// effective spec, and reports it in the status. When the template is gone, the Server keeps the effective spec it had.
+// The spec of the Server must not be stored after this, only its status and patches of Enabled.
+func (r *ServerReconciler) ReconcileTemplate(ctx context.Context, log logr.Logger, server *v1.Server) error {
+	log.V(loglevels.Verbose).Info("start reconciling of template")
+	if server.Spec.TemplateRef == "" {
+		log.V(loglevels.Flow).Info("server has no template")
+		server.Status.EffectiveSpec = nil
+		meta.RemoveStatusCondition(&server.Status.Conditions, ConditionTemplateApplied)
+		return r.checkSpec(ctx, server)
+	}
+
+	var template v1.ServerTemplate
+	err := r.Get(ctx, client.ObjectKey{Name: server.Spec.TemplateRef, Namespace: server.Namespace}, &template)
+	if apierrors.IsNotFound(err) {
+		message := fmt.Sprintf("ServerTemplate %s doesn't exist", server.Spec.TemplateRef)
+		if server.Status.EffectiveSpec == nil {
+			r.setTemplateCondition(server, metav1.ConditionFalse, "TemplateNotFound", message)
+			return r.storeSpecError(ctx, server, errors.New(message))
+		}
+		log.V(loglevels.Info).Info("template not found, keeping the last effective spec", "template", server.Spec.TemplateRef)
+		r.setTemplateCondition(server, metav1.ConditionFalse, "TemplateNotFound", message+", the Server keeps the spec it had")
+		server.Spec = *helpers.EffectiveServer(server).Spec.DeepCopy()
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "getting template")
+	}
+
+	effective, ignored, err := helpers.MergeTemplate(&template, server.Spec)
+	if err != nil {
+		r.setTemplateCondition(server, metav1.ConditionFalse, "InvalidTemplate", err.Error())
+		return r.storeSpecError(ctx, server, err)
+	}
+	log.V(loglevels.Trace).Info("merged template", "template", template.Name, "spec", effective, "ignored", ignored)
+	if len(ignored) > 0 {
+		r.setTemplateCondition(server, metav1.ConditionTrue, "OverridesIgnored", fmt.Sprintf(
+			"ServerTemplate %s doesn't allow the Server to set %s", template.Name, strings.Join(ignored, ", ")))
+	} else {
+		r.setTemplateCondition(server, metav1.ConditionTrue, "Applied", fmt.Sprintf("ServerTemplate %s is applied", template.Name))
+	}
+
+	server.Spec = effective
+	server.Status.EffectiveSpec = effective.DeepCopy()
+	return r.checkSpec(ctx, server)
+}
+
+// checkSpec checks the Server has the fields that are required, from its own spec or from the template, and fills in
+// the defaults of the Servers without a template
+func (r *ServerReconciler) checkSpec(ctx context.Context, server *v1.Server) error {
+	if server.Spec.InitMemory == 0 {
+		server.Spec.InitMemory = server.Spec.MaxMemory
+	}
+
+	var missing []string
+	if server.Spec.ServerVersion == "" {
+		missing = append(missing, "server-version")
+	}
+	if server.Spec.MaxMemory == 0 {
+		missing = append(missing, "maxMemoryMB")
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	message := fmt.Sprintf("The Server has no %s, it has to be set on the Server or its template", strings.Join(missing, " and "))
+	r.setTemplateCondition(server, metav1.ConditionFalse, "IncompleteSpec", message)
+	return r.storeSpecError(ctx, server, errors.New(message))
+}
+
+// storeSpecError stores the status with the condition that tells why the Server can't be reconciled, and returns the error
+func (r *ServerReconciler) storeSpecError(ctx context.Context, server *v1.Server, err error) error {
+	if storeErr := r.Status().Update(ctx, server); storeErr != nil {
+		return errors.Wrap(storeErr, "storing status")
+	}
+	return err
+}
+
+// setEnabled enables or disables the Server by patching only Enabled, as the spec the reconciler has can hold the
+// fields of its template, which mustn't end up in the Server
+func (r *ServerReconciler) setEnabled(ctx context.Context, server *v1.Server, enabled bool) error {
+	// a patch of a copy leaves the status alone, that's stored later
+	changed := server.DeepCopy()
+	changed.Spec.Enabled = enabled
+	if err := r.Patch(ctx, changed, client.MergeFrom(server)); err != nil {
+		return err
+	}
+	server.Spec.Enabled = enabled
+	server.ResourceVersion = changed.ResourceVersion
+	return nil
+}
+
+// storeStatus stores the status of the Server. The update returns the spec as it's stored, so the effective spec the
+// reconciler works with is put back
+func (r *ServerReconciler) storeStatus(ctx context.Context, server *v1.Server) error {
+	spec := server.Spec
+	err := r.Status().Update(ctx, server)
+	server.Spec = spec
+	return err
+}
+
+// serversForTemplate returns the requests for the Servers that use the ServerTemplate, to apply changes to it
+func (r *ServerReconciler) serversForTemplate(object client.Object) []reconcile.Request {
+	var servers v1.ServerList
+	if err := r.List(context.Background(), &servers, client.InNamespace(object.GetNamespace())); err != nil {
+		r.Log.V(loglevels.Error).Error(err, "failed to list Servers for template", "template", object.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, server := range servers.Items {
+		if server.Spec.TemplateRef == object.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Name: server.Name, Namespace: server.Namespace}})
+		}
+	}
+	return requests
+}
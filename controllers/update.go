@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/initializer"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"path"
+	"time"
+)
+
+// ConditionUpdateAllowed tells if the Server runs the ServerVersion of the spec, or why it doesn't
+const ConditionUpdateAllowed = "UpdateAllowed"
+
+const (
+	// EventUpdateStarted is the reason of the Events for a Server that starts on another server version
+	EventUpdateStarted = "UpdateStarted"
+	// EventUpdateCompleted is the reason of the Events for a Server that runs the new server version
+	EventUpdateCompleted = "UpdateCompleted"
+	// EventUpdateRefused is the reason of the Events for a change of the server version that isn't applied
+	EventUpdateRefused = "UpdateRefused"
+)
+
+const (
+	// defaultKeepSnapshots is the number of snapshots that are kept, when the UpdatePolicy doesn't say
+	defaultKeepSnapshots = 3
+	// versionHistoryLength is the number of version changes kept in the status
+	versionHistoryLength = 20
+)
+
+// versionPlan is what server version the Server runs, and why
+type versionPlan struct {
+	// Version is the server version to run
+	Version string
+	// Restore is the snapshot to restore before running it, for rollbacks
+	Restore string
+	// Allowed tells if Version is the ServerVersion of the spec
+	Allowed bool
+	Reason  string
+	Message string
+}
+
+// updatePolicy returns the UpdatePolicy of the Server, with the defaults filled in
+func updatePolicy(server *v1.Server) v1.UpdatePolicy {
+	policy := v1.UpdatePolicy{}
+	if server.Spec.UpdatePolicy != nil {
+		policy = *server.Spec.UpdatePolicy
+	}
+	if policy.KeepSnapshots == 0 {
+		policy.KeepSnapshots = defaultKeepSnapshots
+	}
+	return policy
+}
+
+// isDowngrade tells if moving from one server version to the other goes to an older Minecraft version
+func isDowngrade(from, to string) bool {
+	_, fromVersion, err := helpers.ParseServerVersion(from)
+	if err != nil {
+		return false
+	}
+	_, toVersion, err := helpers.ParseServerVersion(to)
+	if err != nil {
+		return false
+	}
+	return toVersion.Compare(fromVersion) < 0
+}
+
+// snapshotChange returns the index of the version change in the history that took the named snapshot, or -1
+func snapshotChange(server *v1.Server, snapshot string) int {
+	for index := len(server.Status.VersionHistory) - 1; index >= 0; index-- {
+		if server.Status.VersionHistory[index].Snapshot == snapshot {
+			return index
+		}
+	}
+	return -1
+}
+
+// planVersion decides what server version the Server runs. A Server that hasn't run yet runs the ServerVersion of
+// the spec. After that, a downgrade or a rollback that can't be done keeps it on the version it ran.
+func planVersion(server *v1.Server) versionPlan {
+	running := server.Status.ServerVersion
+	target := server.Spec.ServerVersion
+	if running == "" {
+		return versionPlan{Version: target, Allowed: true, Reason: "UpToDate", Message: "The Server runs the server version of the spec"}
+	}
+
+	// a rollback that's done stays in the spec, after its snapshot is gone too
+	if snapshot := server.Spec.Rollback; snapshot != "" && !(target == running && snapshotChange(server, snapshot) < 0) {
+		index := snapshotChange(server, snapshot)
+		if index < 0 {
+			return versionPlan{Version: running, Reason: "SnapshotNotFound",
+				Message: fmt.Sprintf("Snapshot %s isn't in the version history, or has been removed", snapshot)}
+		}
+		change := server.Status.VersionHistory[index]
+		if change.From != target {
+			return versionPlan{Version: running, Reason: "RollbackVersionMismatch",
+				Message: fmt.Sprintf("Snapshot %s was taken of %s, the server version has to be set to it", snapshot, change.From)}
+		}
+		restored := false
+		for _, later := range server.Status.VersionHistory[index+1:] {
+			restored = restored || later.Restored == snapshot
+		}
+		if !restored {
+			return versionPlan{Version: target, Restore: snapshot, Allowed: true, Reason: "RollingBack",
+				Message: fmt.Sprintf("Rolling back to %s, restoring snapshot %s", target, snapshot)}
+		}
+	}
+
+	switch {
+	case target == running:
+		return versionPlan{Version: target, Allowed: true, Reason: "UpToDate", Message: "The Server runs the server version of the spec"}
+	case isDowngrade(running, target) && !updatePolicy(server).AllowDowngrade:
+		return versionPlan{Version: running, Reason: "DowngradeRefused",
+			Message: fmt.Sprintf("The Server ran %s, moving back to %s needs allowDowngrade or a rollback", running, target)}
+	}
+	return versionPlan{Version: target, Allowed: true, Reason: "Updating", Message: fmt.Sprintf("Updating from %s to %s", running, target)}
+}
+
+// serverVersion returns the server version the Server runs, which can differ from the spec when a change is refused
+func serverVersion(server *v1.Server) string {
+	return planVersion(server).Version
+}
+
+// snapshotName returns the name of the snapshot taken of the server version at the time
+func snapshotName(at int64, version string) string {
+	return time.Unix(at, 0).UTC().Format("20060102-150405") + "-" + unsafeArchiveCharacters.ReplaceAllString(version, "_")
+}
+
+// snapshotDir returns where the init container finds the named snapshot of the Server
+func snapshotDir(server *v1.Server, snapshot string) string {
+	return path.Join("/worlds/.snapshots", server.Name, snapshot)
+}
+
+// keptSnapshots returns the names of the snapshots in the version history, newest first. As the pending update
+// takes a snapshot too, that's one less than the UpdatePolicy keeps.
+func keptSnapshots(server *v1.Server, keep int) []string {
+	var names []string
+	for index := len(server.Status.VersionHistory) - 1; index >= 0 && len(names) < keep; index-- {
+		if snapshot := server.Status.VersionHistory[index].Snapshot; snapshot != "" {
+			names = append(names, snapshot)
+		}
+	}
+	return names
+}
+
+// buildUpdate renders the pending update of the Server into the init manifest: the mod check, the snapshot and
+// the restore of a rollback
+func buildUpdate(server *v1.Server, manifest *initializer.Manifest) {
+	pending := server.Status.PendingUpdate
+	if pending == nil {
+		return
+	}
+	policy := updatePolicy(server)
+
+	update := &initializer.Update{
+		From:          pending.From,
+		To:            pending.To,
+		KeepSnapshots: keptSnapshots(server, int(policy.KeepSnapshots)-1),
+	}
+	if !policy.SkipModCheck {
+		if _, version, err := helpers.ParseServerVersion(pending.To); err == nil {
+			update.MinecraftVersion = version.String()
+		}
+		for _, mod := range server.Spec.ModJars {
+			update.Mods = append(update.Mods, path.Join("/jars/mods", mod))
+		}
+	}
+	if pending.Snapshot != "" {
+		update.SnapshotDir = snapshotDir(server, pending.Snapshot)
+	}
+	manifest.Update = update
+
+	if pending.Restored != "" {
+		manifest.Restore = &initializer.Restore{
+			SnapshotDir: snapshotDir(server, pending.Restored),
+			ArchiveDir:  path.Join("/worlds/.archives", server.Name, "rollback-"+time.Unix(pending.Time, 0).UTC().Format("20060102-150405")),
+		}
+	}
+}
+
+// ReconcileUpdate decides what server version the Server runs, and starts an update when it changes.
+// It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) ReconcileUpdate(ctx context.Context, log logr.Logger, server *v1.Server) {
+	log.V(loglevels.Verbose).Info("start reconciling of server version")
+	plan := planVersion(server)
+	log.V(loglevels.Flow).Info("planned server version", "version", plan.Version, "reason", plan.Reason)
+
+	status := metav1.ConditionTrue
+	if !plan.Allowed {
+		status = metav1.ConditionFalse
+		if condition := meta.FindStatusCondition(server.Status.Conditions, ConditionUpdateAllowed); condition == nil || condition.Reason != plan.Reason {
+			log.V(loglevels.Info).Info("refusing server version", "reason", plan.Reason, "message", plan.Message)
+			r.warning(server, EventUpdateRefused, "%s", plan.Message)
+		}
+	}
+	meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+		Type:               ConditionUpdateAllowed,
+		Status:             status,
+		ObservedGeneration: server.Generation,
+		Reason:             plan.Reason,
+		Message:            plan.Message,
+	})
+
+	running := server.Status.ServerVersion
+	if running == "" || (plan.Version == running && plan.Restore == "") {
+		server.Status.PendingUpdate = nil
+		return
+	}
+
+	pending := server.Status.PendingUpdate
+	if pending != nil && pending.From == running && pending.To == plan.Version && pending.Restored == plan.Restore {
+		return
+	}
+	now := time.Now().Unix()
+	pending = &v1.VersionChange{From: running, To: plan.Version, Time: now, Restored: plan.Restore}
+	if plan.Restore == "" {
+		// a rollback doesn't need one, as the world is archived when the snapshot is restored
+		pending.Snapshot = snapshotName(now, running)
+	}
+	server.Status.PendingUpdate = pending
+	log.V(loglevels.Info).Info("starting update", "from", pending.From, "to", pending.To, "snapshot", pending.Snapshot, "restore", pending.Restored)
+	r.normal(server, EventUpdateStarted, "%s", plan.Message)
+}
+
+// UpdateVersionStatus records the server version once the Server runs it, which completes the pending update.
+// It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) UpdateVersionStatus(ctx context.Context, log logr.Logger, server *v1.Server) {
+	log.V(loglevels.Verbose).Info("updating server version status")
+
+	// right after a change the old Pod can still be up, which still runs the old version
+	current, err := r.runningCurrentSpec(ctx, log, server)
+	if err != nil {
+		log.V(loglevels.Info).Error(err, "failed to check the Pod against the spec")
+		return
+	}
+	if !current {
+		return
+	}
+
+	version := serverVersion(server)
+	server.Status.ServerVersion = version
+	pending := server.Status.PendingUpdate
+	if pending == nil || pending.To != version {
+		return
+	}
+
+	log.V(loglevels.Info).Info("update completed", "from", pending.From, "to", pending.To)
+	server.Status.VersionHistory = append(server.Status.VersionHistory, *pending)
+	server.Status.PendingUpdate = nil
+	if len(server.Status.VersionHistory) > versionHistoryLength {
+		server.Status.VersionHistory = server.Status.VersionHistory[len(server.Status.VersionHistory)-versionHistoryLength:]
+	}
+
+	// the init container removed the snapshots beyond the ones it was told to keep
+	kept := make(map[string]bool)
+	for _, name := range keptSnapshots(server, int(updatePolicy(server).KeepSnapshots)) {
+		kept[name] = true
+	}
+	for index := range server.Status.VersionHistory {
+		if !kept[server.Status.VersionHistory[index].Snapshot] {
+			server.Status.VersionHistory[index].Snapshot = ""
+		}
+	}
+
+	if pending.Restored != "" {
+		r.normal(server, EventUpdateCompleted, "Rolled back to %s", pending.To)
+		return
+	}
+	r.normal(server, EventUpdateCompleted, "Updated from %s to %s, snapshot %s has the world from before", pending.From, pending.To, pending.Snapshot)
+}
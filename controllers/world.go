@@ -0,0 +1,224 @@
+package controllers
+
+import (
+	"context"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/rcon"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// ConditionWorldSettingsApplied tells if the world generation settings in the spec match the current world
+	ConditionWorldSettingsApplied = "WorldSettingsApplied"
+	// ConditionActiveWorldFound tells if the active world is defined in the spec
+	ConditionActiveWorldFound = "ActiveWorldFound"
+	// ConditionWorldNamesValid tells if the names of the worlds in the spec are unique and not reserved
+	ConditionWorldNamesValid = "WorldNamesValid"
+)
+
+var unsafeArchiveCharacters = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// activeWorld returns the name of the world to run, falling back to the default world when it's not defined
+func activeWorld(server *v1.Server) string {
+	return helpers.RunningWorldName(server)
+}
+
+// activeWorldSpec returns the spec of the world to run
+func activeWorldSpec(server *v1.Server) *v1.WorldSpec {
+	spec, _ := helpers.WorldSpec(server, activeWorld(server))
+	return spec
+}
+
+// worldStatus returns the status of the named world, adding it when it's missing
+func worldStatus(server *v1.Server, name string) *v1.WorldStatus {
+	for index := range server.Status.Worlds {
+		if server.Status.Worlds[index].Name == name {
+			return &server.Status.Worlds[index]
+		}
+	}
+	server.Status.Worlds = append(server.Status.Worlds, v1.WorldStatus{Name: name})
+	return &server.Status.Worlds[len(server.Status.Worlds)-1]
+}
+
+// requestedWorldGeneration returns the world generation settings from the spec of the active world
+func requestedWorldGeneration(server *v1.Server) v1.WorldGeneration {
+	spec := activeWorldSpec(server)
+	if spec == nil {
+		return v1.WorldGeneration{}
+	}
+	return spec.WorldGeneration
+}
+
+// worldRegenerationPending tells if the spec asks for a regeneration of the active world that hasn't happened yet
+func worldRegenerationPending(server *v1.Server) bool {
+	spec := activeWorldSpec(server)
+	if spec == nil || spec.Regenerate == "" {
+		return false
+	}
+	for _, world := range server.Status.Worlds {
+		if world.Name == activeWorld(server) {
+			return world.Regenerated != spec.Regenerate
+		}
+	}
+	return true
+}
+
+// worldArchiveName returns the name to archive the active world under, or empty when no regeneration is pending
+func worldArchiveName(server *v1.Server) string {
+	if !worldRegenerationPending(server) {
+		return ""
+	}
+	name := unsafeArchiveCharacters.ReplaceAllString(activeWorldSpec(server).Regenerate, "_")
+	if world := activeWorld(server); world != helpers.DefaultWorldName {
+		name = world + "-" + name
+	}
+	return name
+}
+
+// worldProperties renders the world generation settings into server.properties keys.
+// Once the world exists we keep rendering the settings it was generated with, as changes don't apply to it anyway.
+func worldProperties(server *v1.Server) map[string]string {
+	generation := requestedWorldGeneration(server)
+	for _, world := range server.Status.Worlds {
+		if world.Name == activeWorld(server) && world.Generated != nil && !worldRegenerationPending(server) {
+			generation = *world.Generated
+		}
+	}
+
+	properties := make(map[string]string)
+	if generation.Seed != "" {
+		properties["level-seed"] = generation.Seed
+	}
+	if generation.LevelType != "" {
+		properties["level-type"] = generation.LevelType
+	}
+	if generation.GeneratorSettings != "" {
+		properties["generator-settings"] = generation.GeneratorSettings
+	}
+	return properties
+}
+
+// UpdateWorldStatus records the generation settings once the active world exists, starts pre-generation and
+// warns when the settings in the spec no longer match the world.
+// It only changes server.Status, storing it is up to the caller.
+func (r *ServerReconciler) UpdateWorldStatus(ctx context.Context, log logr.Logger, server *v1.Server, online bool) {
+	log.V(loglevels.Verbose).Info("updating world status")
+
+	log.V(loglevels.Flow).Info("checking for the active world")
+	if _, err := helpers.WorldSpec(server, helpers.ActiveWorldName(server)); err != nil {
+		log.V(loglevels.Info).Info("active world not found, running the default world", "world", server.Spec.ActiveWorld)
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionActiveWorldFound,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "WorldNotDefined",
+			Message:            "The active world isn't in the list of worlds, running the default world instead",
+		})
+	} else {
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionActiveWorldFound,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: server.Generation,
+			Reason:             "WorldDefined",
+			Message:            "The active world is defined",
+		})
+	}
+
+	log.V(loglevels.Flow).Info("checking the names of the worlds")
+	if err := helpers.ValidateWorlds(server); err != nil {
+		log.V(loglevels.Info).Info("invalid world names", "error", err)
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionWorldNamesValid,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "InvalidWorldName",
+			Message:            err.Error(),
+		})
+	} else {
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionWorldNamesValid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: server.Generation,
+			Reason:             "WorldNamesValid",
+			Message:            "The names of the worlds are unique",
+		})
+	}
+
+	log.V(loglevels.Flow).Info("dropping status of removed worlds")
+	var worlds []v1.WorldStatus
+	for _, world := range server.Status.Worlds {
+		if _, err := helpers.WorldSpec(server, world.Name); err == nil {
+			worlds = append(worlds, world)
+		}
+	}
+	server.Status.Worlds = worlds
+
+	world := worldStatus(server, activeWorld(server))
+	spec := activeWorldSpec(server)
+
+	if online {
+		// right after a change the old Pod can still be up, which doesn't tell us anything about the new world
+		current, err := r.runningCurrentSpec(ctx, log, server)
+		if err != nil {
+			log.V(loglevels.Info).Error(err, "failed to check the Pod against the spec")
+		}
+		online = current
+	}
+
+	if online && server.Status.Thumbnail != "" {
+		world.Thumbnail = server.Status.Thumbnail
+	}
+
+	if online && (world.Generated == nil || worldRegenerationPending(server)) {
+		// the server is up with the current spec, so the world has been created with the settings we rendered
+		log.V(loglevels.Info).Info("world created, recording generation settings", "world", world.Name)
+		generation := requestedWorldGeneration(server)
+		world.Generated = &generation
+		world.Pregenerated = false
+		if spec != nil {
+			world.Regenerated = spec.Regenerate
+		}
+	}
+
+	if online && !world.Pregenerated && spec != nil && spec.PregenerateRadius > 0 {
+		log.V(loglevels.Info).Info("starting world pre-generation", "world", world.Name, "radius", spec.PregenerateRadius)
+		_, err := rcon.Command(ctx, r.Client, server,
+			"chunky spawn",
+			"chunky radius "+strconv.Itoa(int(spec.PregenerateRadius)),
+			"chunky start",
+		)
+		if err != nil {
+			// non-critical error, we'll try again next time
+			log.V(loglevels.Info).Error(errors.Wrap(err, "starting pre-generation"), "failed to start world pre-generation")
+		} else {
+			world.Pregenerated = true
+		}
+	}
+
+	log.V(loglevels.Flow).Info("checking world generation settings against the world")
+	if world.Generated != nil && !worldRegenerationPending(server) && *world.Generated != requestedWorldGeneration(server) {
+		log.V(loglevels.Verbose).Info("world generation settings changed after the world was created")
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionWorldSettingsApplied,
+			Status:             metav1.ConditionFalse,
+			ObservedGeneration: server.Generation,
+			Reason:             "WorldAlreadyGenerated",
+			Message:            "The world generation settings changed after the world was created, they only apply after regenerating the world",
+		})
+	} else {
+		meta.SetStatusCondition(&server.Status.Conditions, metav1.Condition{
+			Type:               ConditionWorldSettingsApplied,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: server.Generation,
+			Reason:             "Applied",
+			Message:            "The world generation settings match the world, or will be applied when it is created",
+		})
+	}
+}
@@ -3,6 +3,7 @@ module github.com/hsmade/minecraft-operator
 go 1.16
 
 require (
+	github.com/Tnze/go-mc v1.16.5-pre.0.20210225122206-f8b3501b6045
 	github.com/go-logr/logr v1.1.0
 	github.com/go-mc/mcping v1.2.1
 	github.com/mitchellh/hashstructure/v2 v2.0.2
@@ -13,4 +14,5 @@ require (
 	k8s.io/apimachinery v0.22.1
 	k8s.io/client-go v0.22.1
 	sigs.k8s.io/controller-runtime v0.9.6
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -0,0 +1,248 @@
+package initializer
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/go-logr/logr"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sort"
+	"strings"
+)
+
+// Main runs the init binary: it reads the manifest and prepares the data directory.
+// On failure, the reason is written to the termination message, so it shows up on the Pod.
+func Main(args []string) int {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	manifestPath := flags.String("manifest", "/config/manifest.json", "The manifest that tells what to prepare.")
+	terminationLog := flags.String("termination-log", "/dev/termination-log", "Where to write the failure reason.")
+	opts := zap.Options{
+		Development: true,
+	}
+	opts.BindFlags(flags)
+	flags.Parse(args)
+	log := zap.New(zap.UseFlagOptions(&opts)).WithName("init")
+
+	err := run(log, *manifestPath)
+	if err != nil {
+		log.Error(err, "init failed")
+		if writeErr := ioutil.WriteFile(*terminationLog, []byte(err.Error()), 0o644); writeErr != nil {
+			log.Error(writeErr, "failed to write termination message")
+		}
+		return 1
+	}
+	log.Info("init done")
+	return 0
+}
+
+// run loads the manifest and applies it
+func run(log logr.Logger, manifestPath string) error {
+	log.V(loglevels.Flow).Info("reading manifest", "path", manifestPath)
+	content, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return errors.Wrap(err, "reading manifest")
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return errors.Wrap(err, "parsing manifest")
+	}
+	log.V(loglevels.Trace).Info("read manifest", "manifest", manifest)
+
+	return Apply(log, &manifest)
+}
+
+// Apply prepares the data directory as the manifest describes. It's idempotent, so a restarted Pod gets
+// the same result, and only changed files are copied.
+func Apply(log logr.Logger, manifest *Manifest) error {
+	if manifest.Update != nil {
+		if err := applyUpdate(log, manifest); err != nil {
+			return errors.Wrap(err, "updating server version")
+		}
+	}
+	if manifest.Restore != nil {
+		if err := restoreSnapshot(log, manifest); err != nil {
+			return errors.Wrap(err, "restoring snapshot")
+		}
+	}
+
+	if manifest.Archive != nil {
+		log.V(loglevels.Info).Info("archiving world", "world", manifest.Archive.WorldDir, "archive", manifest.Archive.ArchiveDir)
+		if _, err := os.Stat(manifest.Archive.ArchiveDir); err == nil {
+			log.V(loglevels.Flow).Info("world has been archived before")
+		} else if err := moveContents(manifest.Archive.WorldDir, manifest.Archive.ArchiveDir); err != nil {
+			return errors.Wrap(err, "archiving world")
+		}
+	}
+
+	if err := validatePersisted(manifest); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(manifest.DataDir, 0o755); err != nil {
+		return errors.Wrap(err, "creating data directory")
+	}
+	if err := unlinkPersisted(manifest); err != nil {
+		return errors.Wrap(err, "unlinking persisted paths")
+	}
+
+	log.V(loglevels.Info).Info("syncing server distribution", "source", manifest.ServerDir)
+	if _, err := os.Stat(manifest.ServerDir); err != nil {
+		return errors.Wrap(err, "server distribution not found, check the server-version")
+	}
+	copied, err := syncTree(manifest.ServerDir, manifest.DataDir)
+	if err != nil {
+		return errors.Wrap(err, "syncing server distribution")
+	}
+	log.V(loglevels.Verbose).Info("synced server distribution", "copied", copied)
+
+	for _, directory := range manifest.Directories {
+		if err := syncDirectory(log, manifest, directory); err != nil {
+			return errors.Wrapf(err, "syncing %s", directory.Path)
+		}
+	}
+
+	for _, file := range manifest.Files {
+		log.V(loglevels.Verbose).Info("writing file", "path", file.Path)
+		if err := writeFile(manifest, file); err != nil {
+			return errors.Wrapf(err, "writing %s", file.Path)
+		}
+	}
+
+	if manifest.Eula {
+		log.V(loglevels.Verbose).Info("writing eula.txt")
+		err := writeFile(manifest, File{Path: "eula.txt", Content: "eula=true\n"})
+		if err != nil {
+			return errors.Wrap(err, "writing eula.txt")
+		}
+	}
+
+	// the links go last, as they only point somewhere in the server container
+	if err := persist(log, manifest); err != nil {
+		return errors.Wrap(err, "persisting state")
+	}
+	return nil
+}
+
+// syncDirectory copies the artifacts into the directory, and removes the ones that are no longer listed
+func syncDirectory(log logr.Logger, manifest *Manifest, directory Directory) error {
+	target, err := resolvePath(manifest, directory.Path)
+	if err != nil {
+		return err
+	}
+	log.V(loglevels.Info).Info("syncing directory", "path", directory.Path, "artifacts", len(directory.Artifacts))
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+
+	keep := make(map[string]bool)
+	for _, artifact := range directory.Artifacts {
+		name := artifact.Name
+		if name == "" && artifact.URL != "" {
+			name = path.Base(artifact.URL)
+		} else if name == "" {
+			name = filepath.Base(artifact.Source)
+		}
+		if strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+			return errors.Errorf("invalid artifact name %q", name)
+		}
+		keep[name] = true
+
+		var changed bool
+		if artifact.URL != "" {
+			changed, err = downloadFile(artifact.URL, filepath.Join(target, name), strings.ToLower(artifact.SHA256))
+		} else {
+			var checksum string
+			checksum, err = expectedChecksum(artifact.Source, artifact.SHA256)
+			if err != nil {
+				return err
+			}
+			changed, err = syncFile(artifact.Source, filepath.Join(target, name), checksum)
+		}
+		if err != nil {
+			return err
+		}
+		log.V(loglevels.Verbose).Info("synced artifact", "name", name, "copied", changed)
+	}
+
+	if directory.PruneSuffix != "" {
+		removed, err := pruneDirectory(target, directory.PruneSuffix, keep)
+		if err != nil {
+			return err
+		}
+		if len(removed) > 0 {
+			log.V(loglevels.Info).Info("removed stale files", "path", directory.Path, "files", removed)
+		}
+	}
+	return nil
+}
+
+// writeFile writes the file, replacing it instead of writing into it, so a hardlinked source stays untouched
+func writeFile(manifest *Manifest, file File) error {
+	target, err := resolvePath(manifest, file.Path)
+	if err != nil {
+		return err
+	}
+
+	content := file.Content
+	if file.Source != "" {
+		source, err := ioutil.ReadFile(file.Source)
+		if err != nil {
+			return errors.Wrap(err, "reading source")
+		}
+		content = string(source)
+	}
+	if len(file.EnvProperties) > 0 {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		var keys []string
+		for key := range file.EnvProperties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			content += fmt.Sprintf("%s=%s\n", key, os.Getenv(file.EnvProperties[key]))
+		}
+	}
+
+	mode := os.FileMode(file.Mode)
+	if mode == 0 {
+		mode = 0o644
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return errors.Wrap(err, "creating directory")
+	}
+	tmp := target + ".init-tmp"
+	if err := ioutil.WriteFile(tmp, []byte(content), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// resolvePath returns where a path relative to the server directory is in the init container. Paths in a persisted
+// path are written into the StateDir, as that's what the server sees there.
+func resolvePath(manifest *Manifest, path string) (string, error) {
+	clean := filepath.Clean(path)
+	if manifest.WorldDir != "" && (clean == "world" || strings.HasPrefix(clean, "world"+string(filepath.Separator))) {
+		return safeJoin(manifest.WorldDir, strings.TrimPrefix(clean, "world"))
+	}
+	if persisted, ok, err := resolvePersisted(manifest, clean); ok {
+		return persisted, err
+	}
+	return safeJoin(manifest.DataDir, path)
+}
+
+// safeJoin joins the relative path to dir, refusing paths that point outside of it
+func safeJoin(dir, path string) (string, error) {
+	joined := filepath.Join(dir, path)
+	if joined != filepath.Clean(dir) && !strings.HasPrefix(joined, filepath.Clean(dir)+string(filepath.Separator)) {
+		return "", errors.Errorf("path %q points outside of %s", path, dir)
+	}
+	return joined, nil
+}
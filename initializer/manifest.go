@@ -0,0 +1,143 @@
+package initializer
+
+// Manifest tells the init binary how to prepare the data directory of a Server.
+// It's rendered by the operator into the Server's ConfigMap.
+type Manifest struct {
+	// DataDir is the working directory of the server
+	DataDir string `json:"dataDir"`
+
+	// WorldDir is where the active world is in the init container. Paths under world/ go here, as that's where the
+	// server container mounts the world.
+	WorldDir string `json:"worldDir,omitempty"`
+
+	// Archive moves the current world into an archive before starting, when set
+	Archive *Archive `json:"archive,omitempty"`
+
+	// Update checks the mods and takes a snapshot before the Server starts on another version, when set
+	Update *Update `json:"update,omitempty"`
+
+	// Restore puts a snapshot of the world and the state back before starting, when set
+	Restore *Restore `json:"restore,omitempty"`
+
+	// ServerDir is the directory with the server distribution, that's copied into DataDir
+	ServerDir string `json:"serverDir"`
+
+	// Directories are synced into DataDir, like mods/
+	Directories []Directory `json:"directories,omitempty"`
+
+	// Files are written into DataDir
+	Files []File `json:"files,omitempty"`
+
+	// Eula accepts the Minecraft EULA by writing eula.txt
+	Eula bool `json:"eula"`
+
+	// StateDir is where the game-managed state of the server is in the init container. Paths that are Persisted
+	// go here, and are linked from DataDir.
+	StateDir string `json:"stateDir,omitempty"`
+
+	// StateMount is where the server container mounts the StateDir, which the links in DataDir point to
+	StateMount string `json:"stateMount,omitempty"`
+
+	// Persisted are the paths in DataDir that the game changes, and that are kept in StateDir across restarts
+	Persisted []Persisted `json:"persisted,omitempty"`
+}
+
+// Persisted is a file or directory of the server that outlives the Pod. What the distribution has there is only
+// copied into the StateDir when it isn't there yet, while Directories and Files are written over what's there.
+type Persisted struct {
+	// Path is the path of the file or directory, relative to DataDir
+	Path string `json:"path"`
+
+	// State is the path in StateDir to keep it, which defaults to Path
+	State string `json:"state,omitempty"`
+
+	// Directory tells the path is a directory
+	Directory bool `json:"directory,omitempty"`
+}
+
+// Archive moves the contents of a world into an archive directory
+type Archive struct {
+	// WorldDir is the directory of the world to archive
+	WorldDir string `json:"worldDir"`
+
+	// ArchiveDir is the directory to move the world into. When it already exists, the world has been archived before.
+	ArchiveDir string `json:"archiveDir"`
+}
+
+// Update is the move of the Server to another server version
+type Update struct {
+	// From is the server version the Server ran before
+	From string `json:"from"`
+
+	// To is the server version the Server is starting on
+	To string `json:"to"`
+
+	// MinecraftVersion is the Minecraft version of To, that the Mods have to support. Empty skips the check
+	MinecraftVersion string `json:"minecraftVersion,omitempty"`
+
+	// Mods are the paths of the mod jars to check
+	Mods []string `json:"mods,omitempty"`
+
+	// SnapshotDir is where the world and the state are copied to before starting. When it already exists, the
+	// snapshot has been taken before. Empty skips the snapshot
+	SnapshotDir string `json:"snapshotDir,omitempty"`
+
+	// KeepSnapshots are the names of the snapshots next to SnapshotDir to keep. The others are removed
+	KeepSnapshots []string `json:"keepSnapshots,omitempty"`
+}
+
+// Restore replaces the world and the state with a snapshot
+type Restore struct {
+	// SnapshotDir is the snapshot to restore
+	SnapshotDir string `json:"snapshotDir"`
+
+	// ArchiveDir is where the current world and state are moved to. When it already exists, the snapshot has been
+	// restored before.
+	ArchiveDir string `json:"archiveDir"`
+}
+
+// Directory is a directory under DataDir that holds the listed artifacts
+type Directory struct {
+	// Path is the path of the directory, relative to DataDir (or WorldDir, for paths under world/)
+	Path string `json:"path"`
+
+	// Artifacts are copied into the directory
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+
+	// PruneSuffix removes files with this suffix (e.g.: .jar) that aren't in Artifacts. Empty disables pruning
+	PruneSuffix string `json:"pruneSuffix,omitempty"`
+}
+
+// Artifact is a file that's copied into a Directory
+type Artifact struct {
+	// Source is the path of the file to copy
+	Source string `json:"source,omitempty"`
+
+	// URL is where to download the file from, instead of Source. It needs SHA256
+	URL string `json:"url,omitempty"`
+
+	// Name is the name of the file in the Directory. Defaults to the name of Source or URL
+	Name string `json:"name,omitempty"`
+
+	// SHA256 is the expected checksum of the file. When it's empty, a <source>.sha256 file is used if it's there
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// File is a file that's written with the given content
+type File struct {
+	// Path is the path of the file, relative to DataDir (or WorldDir, for paths under world/)
+	Path string `json:"path"`
+
+	// Content is the content of the file
+	Content string `json:"content,omitempty"`
+
+	// Source is a file to copy the content from, instead of Content
+	Source string `json:"source,omitempty"`
+
+	// Mode is the file mode. Defaults to 0644
+	Mode uint32 `json:"mode,omitempty"`
+
+	// EnvProperties are appended to the file as key=value lines, with the value taken from the named environment
+	// variable. This keeps secrets out of the manifest.
+	EnvProperties map[string]string `json:"envProperties,omitempty"`
+}
@@ -0,0 +1,365 @@
+package initializer
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/json"
+	"github.com/go-logr/logr"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// requirement is the Minecraft versions a mod declares to support, in the notation of its loader
+type requirement struct {
+	// Versions is a version range (forge, e.g.: [1.20.1,1.21)) or version predicates (fabric, e.g.: >=1.20 <1.21)
+	Versions string
+	// Maven tells the Versions are a version range
+	Maven bool
+}
+
+// supports tells if the version is in the requirement. It fails when the requirement can't be read.
+func (r requirement) supports(version helpers.MinecraftVersion) (bool, error) {
+	if r.Maven {
+		return rangeContains(r.Versions, version)
+	}
+	return predicateContains(r.Versions, version)
+}
+
+// checkMods checks that the mod jars declare to support the Minecraft version. Mods that don't declare it, or in
+// a way that can't be read, are taken to support it.
+func checkMods(log logr.Logger, jars []string, minecraftVersion string) error {
+	version, err := helpers.ParseMinecraftVersion(minecraftVersion)
+	if err != nil {
+		log.V(loglevels.Info).Info("not checking the mods of an unknown Minecraft version", "version", minecraftVersion)
+		return nil
+	}
+
+	var incompatible []string
+	for _, jar := range jars {
+		requirements, err := jarRequirements(jar)
+		if err != nil {
+			log.V(loglevels.Info).Info("can't read the metadata of the mod", "mod", filepath.Base(jar), "error", err)
+			continue
+		}
+		for _, requirement := range requirements {
+			supported, err := requirement.supports(version)
+			if err != nil {
+				log.V(loglevels.Info).Info("can't tell if the mod supports the Minecraft version", "mod", filepath.Base(jar),
+					"versions", requirement.Versions, "error", err)
+				continue
+			}
+			log.V(loglevels.Verbose).Info("checked mod", "mod", filepath.Base(jar), "versions", requirement.Versions, "supported", supported)
+			if !supported {
+				incompatible = append(incompatible, filepath.Base(jar)+" (needs "+requirement.Versions+")")
+			}
+		}
+	}
+	if len(incompatible) > 0 {
+		return errors.Errorf("mods don't support Minecraft %s: %s", version, strings.Join(incompatible, ", "))
+	}
+	return nil
+}
+
+// jarRequirements reads the Minecraft versions the mod jar supports from its metadata
+func jarRequirements(jar string) ([]requirement, error) {
+	archive, err := zip.OpenReader(jar)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	var requirements []requirement
+	for _, file := range archive.File {
+		var parse func([]byte) ([]requirement, error)
+		switch file.Name {
+		case "META-INF/mods.toml", "META-INF/neoforge.mods.toml":
+			parse = modsTomlRequirements
+		case "fabric.mod.json":
+			parse = fabricRequirements
+		case "quilt.mod.json":
+			parse = quiltRequirements
+		default:
+			continue
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "opening %s", file.Name)
+		}
+		content, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading %s", file.Name)
+		}
+		found, err := parse(content)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing %s", file.Name)
+		}
+		requirements = append(requirements, found...)
+	}
+	return requirements, nil
+}
+
+// modsTomlRequirements reads the versionRange of the minecraft dependencies of a forge mods.toml
+func modsTomlRequirements(content []byte) ([]requirement, error) {
+	var requirements []requirement
+	var dependency map[string]string
+	done := func() {
+		if dependency == nil || dependency["modId"] != "minecraft" || dependency["versionRange"] == "" {
+			return
+		}
+		if strings.EqualFold(dependency["mandatory"], "false") ||
+			(dependency["type"] != "" && !strings.EqualFold(dependency["type"], "required")) {
+			return
+		}
+		requirements = append(requirements, requirement{Versions: dependency["versionRange"], Maven: true})
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			done()
+			dependency = nil
+			if strings.HasPrefix(line, "[[dependencies.") {
+				dependency = make(map[string]string)
+			}
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if dependency == nil || len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		if index := strings.Index(value, "#"); index >= 0 && !strings.HasPrefix(value, `"`) {
+			value = strings.TrimSpace(value[:index])
+		}
+		if strings.HasPrefix(value, `"`) {
+			if end := strings.Index(value[1:], `"`); end >= 0 {
+				value = value[1 : end+1]
+			}
+		}
+		dependency[strings.TrimSpace(parts[0])] = value
+	}
+	done()
+	return requirements, scanner.Err()
+}
+
+// fabricRequirements reads the minecraft dependency of a fabric.mod.json, which is a predicate or a list of them
+func fabricRequirements(content []byte) ([]requirement, error) {
+	var metadata struct {
+		Depends map[string]json.RawMessage `json:"depends"`
+	}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return nil, err
+	}
+	versions, ok := metadata.Depends["minecraft"]
+	if !ok {
+		return nil, nil
+	}
+	return predicateRequirements(versions)
+}
+
+// quiltRequirements reads the minecraft dependency of a quilt.mod.json
+func quiltRequirements(content []byte) ([]requirement, error) {
+	var metadata struct {
+		Loader struct {
+			Depends []json.RawMessage `json:"depends"`
+		} `json:"quilt_loader"`
+	}
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		return nil, err
+	}
+	for _, raw := range metadata.Loader.Depends {
+		var dependency struct {
+			ID       string          `json:"id"`
+			Versions json.RawMessage `json:"versions"`
+			Optional bool            `json:"optional"`
+		}
+		// dependencies without versions are just the id
+		if err := json.Unmarshal(raw, &dependency); err != nil || dependency.ID != "minecraft" || dependency.Optional {
+			continue
+		}
+		if len(dependency.Versions) == 0 {
+			return nil, nil
+		}
+		return predicateRequirements(dependency.Versions)
+	}
+	return nil, nil
+}
+
+// predicateRequirements reads a predicate, or a list of predicates of which one has to match
+func predicateRequirements(raw json.RawMessage) ([]requirement, error) {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []requirement{{Versions: single}}, nil
+	}
+	var alternatives []string
+	if err := json.Unmarshal(raw, &alternatives); err != nil {
+		return nil, errors.Wrap(err, "reading minecraft versions")
+	}
+	if len(alternatives) == 0 {
+		return nil, nil
+	}
+	return []requirement{{Versions: strings.Join(alternatives, " || ")}}, nil
+}
+
+// parseLooseVersion parses a version of a requirement, leaving out pre-release and build suffixes (e.g.: 1.21-)
+func parseLooseVersion(version string) (helpers.MinecraftVersion, error) {
+	if index := strings.IndexAny(version, "-+"); index >= 0 {
+		version = version[:index]
+	}
+	return helpers.ParseMinecraftVersion(strings.TrimSpace(version))
+}
+
+// rangeContains tells if the version is in the maven version range (e.g.: [1.20.1,1.21), [1.16.5], [1.18,1.19),[1.19.2,)).
+// A version without brackets is only a recommendation, which any version satisfies.
+func rangeContains(versions string, version helpers.MinecraftVersion) (bool, error) {
+	original := versions
+	versions = strings.TrimSpace(versions)
+	if versions == "" || versions == "*" || !strings.ContainsAny(versions[:1], "[(") {
+		return true, nil
+	}
+
+	for versions != "" {
+		end := strings.IndexAny(versions, "])")
+		if !strings.ContainsAny(versions[:1], "[(") || end < 0 {
+			return false, errors.Errorf("invalid version range %q", original)
+		}
+		bounds := strings.Split(versions[1:end], ",")
+		lowerInclusive, upperInclusive := versions[0] == '[', versions[end] == ']'
+		versions = strings.TrimLeft(versions[end+1:], ", ")
+
+		if len(bounds) == 1 {
+			exact, err := parseLooseVersion(bounds[0])
+			if err != nil {
+				return false, err
+			}
+			if version.Compare(exact) == 0 {
+				return true, nil
+			}
+			continue
+		}
+		if len(bounds) != 2 {
+			return false, errors.Errorf("invalid version range %q", original)
+		}
+		inRange := true
+		if lower := strings.TrimSpace(bounds[0]); lower != "" {
+			parsed, err := parseLooseVersion(lower)
+			if err != nil {
+				return false, err
+			}
+			compared := version.Compare(parsed)
+			inRange = compared > 0 || (compared == 0 && lowerInclusive)
+		}
+		if upper := strings.TrimSpace(bounds[1]); upper != "" && inRange {
+			parsed, err := parseLooseVersion(upper)
+			if err != nil {
+				return false, err
+			}
+			compared := version.Compare(parsed)
+			inRange = compared < 0 || (compared == 0 && upperInclusive)
+		}
+		if inRange {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// predicateContains tells if the version matches the fabric version predicates (e.g.: >=1.20 <1.21, 1.20.x, ~1.20.1).
+// Predicates separated by spaces all have to match, and one of the alternatives separated by || has to.
+func predicateContains(versions string, version helpers.MinecraftVersion) (bool, error) {
+	for _, alternative := range strings.Split(versions, "||") {
+		matches := true
+		for _, predicate := range strings.Fields(alternative) {
+			match, err := predicateMatches(predicate, version)
+			if err != nil {
+				return false, err
+			}
+			if !match {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// predicateOperators are the operators of version predicates, the longer ones first
+var predicateOperators = []string{">=", "<=", ">", "<", "=", "~", "^"}
+
+// predicateMatches tells if the version matches a single predicate
+func predicateMatches(predicate string, version helpers.MinecraftVersion) (bool, error) {
+	if predicate == "*" {
+		return true, nil
+	}
+	operator := ""
+	for _, prefix := range predicateOperators {
+		if strings.HasPrefix(predicate, prefix) {
+			operator = prefix
+			break
+		}
+	}
+	target := strings.TrimPrefix(predicate, operator)
+
+	if strings.ContainsAny(target, "xX*") {
+		if operator != "" && operator != "=" {
+			return false, errors.Errorf("invalid version predicate %q", predicate)
+		}
+		return wildcardMatches(target, version)
+	}
+
+	parsed, err := parseLooseVersion(target)
+	if err != nil {
+		return false, err
+	}
+	compared := version.Compare(parsed)
+	switch operator {
+	case "", "=":
+		return compared == 0, nil
+	case ">=":
+		return compared >= 0, nil
+	case "<=":
+		return compared <= 0, nil
+	case ">":
+		return compared > 0, nil
+	case "<":
+		return compared < 0, nil
+	case "~":
+		return compared >= 0 && version.Major == parsed.Major && version.Minor == parsed.Minor, nil
+	case "^":
+		return compared >= 0 && version.Major == parsed.Major, nil
+	}
+	return false, errors.Errorf("invalid version predicate %q", predicate)
+}
+
+// wildcardMatches tells if the version matches a version with wildcards (e.g.: 1.20.x)
+func wildcardMatches(pattern string, version helpers.MinecraftVersion) (bool, error) {
+	parts := strings.Split(pattern, ".")
+	components := []int{version.Major, version.Minor, version.Patch}
+	if len(parts) > len(components) {
+		return false, errors.Errorf("invalid version %q", pattern)
+	}
+	for index, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			return true, nil
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return false, errors.Errorf("invalid version %q", pattern)
+		}
+		if number != components[index] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,120 @@
+package initializer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+func TestRequirementSupports(t *testing.T) {
+	tests := []struct {
+		versions string
+		maven    bool
+		version  string
+		want     bool
+	}{
+		{versions: "[1.20.1,1.21)", maven: true, version: "1.20.1", want: true},
+		{versions: "[1.20.1,1.21)", maven: true, version: "1.20.6", want: true},
+		{versions: "[1.20.1,1.21)", maven: true, version: "1.21", want: false},
+		{versions: "[1.20.1,1.21)", maven: true, version: "1.20", want: false},
+		{versions: "(1.16.5,]", maven: true, version: "1.16.5", want: false},
+		{versions: "[1.16.5]", maven: true, version: "1.16.5", want: true},
+		{versions: "[1.16.5]", maven: true, version: "1.17", want: false},
+		{versions: "[1.18,1.19),[1.19.2,)", maven: true, version: "1.19.1", want: false},
+		{versions: "[1.18,1.19),[1.19.2,)", maven: true, version: "1.20.4", want: true},
+		{versions: "[1.21-,1.22-)", maven: true, version: "1.21.1", want: true},
+		// a version without brackets is a recommendation
+		{versions: "1.16.5", maven: true, version: "1.20.1", want: true},
+		{versions: ">=1.20 <1.21", version: "1.20.4", want: true},
+		{versions: ">=1.20 <1.21", version: "1.21", want: false},
+		{versions: "1.20.x", version: "1.20.2", want: true},
+		{versions: "1.20.x", version: "1.19.4", want: false},
+		{versions: "~1.20.1", version: "1.20.4", want: true},
+		{versions: "~1.20.1", version: "1.21", want: false},
+		{versions: "^1.19", version: "1.20.1", want: true},
+		{versions: "1.20.1", version: "1.20.2", want: false},
+		{versions: "1.19.4 || 1.20.1", version: "1.20.1", want: true},
+		{versions: "*", version: "1.12.2", want: true},
+	}
+
+	for _, test := range tests {
+		version, err := helpers.ParseMinecraftVersion(test.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := requirement{Versions: test.versions, Maven: test.maven}.supports(version)
+		if err != nil {
+			t.Errorf("%q on %s: %s", test.versions, test.version, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q on %s: got %t, want %t", test.versions, test.version, got, test.want)
+		}
+	}
+}
+
+func TestCheckMods(t *testing.T) {
+	dir := t.TempDir()
+	jars := map[string]map[string]string{
+		"forge-mod.jar": {"META-INF/mods.toml": `modLoader="javafml"
+loaderVersion="[47,)"
+
+[[mods]]
+modId="example"
+version="1.0.0"
+
+[[dependencies.example]]
+    modId="forge"
+    mandatory=true
+    versionRange="[47,)"
+[[dependencies.example]]
+    modId="minecraft"
+    mandatory=true # the game
+    versionRange="[1.20.1,1.20.2)"
+`},
+		"fabric-mod.jar": {"fabric.mod.json": `{"id": "example", "depends": {"fabricloader": ">=0.15", "minecraft": ["1.20.x", "1.21"]}}`},
+		"quilt-mod.jar":  {"quilt.mod.json": `{"quilt_loader": {"depends": ["quilt_loader", {"id": "minecraft", "versions": ">=1.20"}]}}`},
+		"library.jar":    {"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\n"},
+		"unreadable.jar": {"fabric.mod.json": `{"depends": {"minecraft": {"not": "a predicate"}}}`},
+	}
+	var paths []string
+	for name, files := range jars {
+		path := filepath.Join(dir, name)
+		writeJar(t, path, files)
+		paths = append(paths, path)
+	}
+
+	if err := checkMods(zap.New(), paths, "1.20.1"); err != nil {
+		t.Errorf("1.20.1: %s", err)
+	}
+	err := checkMods(zap.New(), paths, "1.21")
+	if err == nil || err.Error() != "mods don't support Minecraft 1.21: forge-mod.jar (needs [1.20.1,1.20.2))" {
+		t.Errorf("1.21: got %v", err)
+	}
+}
+
+// writeJar writes a jar with the files
+func writeJar(t *testing.T, path string, files map[string]string) {
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	archive := zip.NewWriter(out)
+	for name, content := range files {
+		writer, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
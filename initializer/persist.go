@@ -0,0 +1,155 @@
+package initializer
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// operatorPaths are written by the operator on every start, so they can't be persisted
+var operatorPaths = []string{"world", "crash-reports", "server.properties", "eula.txt"}
+
+// validatePersisted checks that the persisted paths stay inside DataDir, and don't take over what the operator writes
+func validatePersisted(manifest *Manifest) error {
+	if len(manifest.Persisted) > 0 && (manifest.StateDir == "" || manifest.StateMount == "") {
+		return errors.New("persisted paths need a state directory")
+	}
+	for _, persisted := range manifest.Persisted {
+		clean := filepath.Clean(persisted.Path)
+		if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+			return errors.Errorf("persisted path %q isn't inside the server directory", persisted.Path)
+		}
+		for _, operatorPath := range operatorPaths {
+			if clean == operatorPath || strings.HasPrefix(clean, operatorPath+string(filepath.Separator)) {
+				return errors.Errorf("persisted path %q is managed by the operator", persisted.Path)
+			}
+		}
+		if _, err := safeJoin(manifest.StateDir, statePath(persisted)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statePath returns the path of the persisted path in the StateDir
+func statePath(persisted Persisted) string {
+	if persisted.State != "" {
+		return persisted.State
+	}
+	return persisted.Path
+}
+
+// resolvePersisted returns where a path in a persisted path is kept in the init container, if it's in one
+func resolvePersisted(manifest *Manifest, clean string) (string, bool, error) {
+	for _, persisted := range manifest.Persisted {
+		persistedPath := filepath.Clean(persisted.Path)
+		if clean == persistedPath {
+			joined, err := safeJoin(manifest.StateDir, statePath(persisted))
+			return joined, true, err
+		}
+		if persisted.Directory && strings.HasPrefix(clean, persistedPath+string(filepath.Separator)) {
+			state, err := safeJoin(manifest.StateDir, statePath(persisted))
+			if err != nil {
+				return "", true, err
+			}
+			joined, err := safeJoin(state, strings.TrimPrefix(clean, persistedPath))
+			return joined, true, err
+		}
+	}
+	return "", false, nil
+}
+
+// unlinkPersisted removes the links of an earlier run from DataDir, so the distribution is synced into a
+// directory again
+func unlinkPersisted(manifest *Manifest) error {
+	for _, persisted := range manifest.Persisted {
+		data, err := safeJoin(manifest.DataDir, persisted.Path)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Lstat(data); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			if err := os.Remove(data); err != nil {
+				return errors.Wrapf(err, "removing link %s", data)
+			}
+		}
+	}
+	return nil
+}
+
+// persist moves the persisted paths out of DataDir into the StateDir, and links them from DataDir to where the
+// server container mounts the StateDir. What the server distribution put there only seeds the StateDir.
+func persist(log logr.Logger, manifest *Manifest) error {
+	for _, persisted := range manifest.Persisted {
+		data, err := safeJoin(manifest.DataDir, persisted.Path)
+		if err != nil {
+			return err
+		}
+		state, err := safeJoin(manifest.StateDir, statePath(persisted))
+		if err != nil {
+			return err
+		}
+		log.V(loglevels.Verbose).Info("persisting path", "path", persisted.Path, "state", state)
+
+		created := state
+		if !persisted.Directory {
+			created = filepath.Dir(state)
+		}
+		if err := os.MkdirAll(created, 0o755); err != nil {
+			return errors.Wrapf(err, "creating %s", created)
+		}
+
+		if _, err := os.Lstat(data); err == nil {
+			seeded, err := seedState(data, state)
+			if err != nil {
+				return errors.Wrapf(err, "seeding %s", persisted.Path)
+			}
+			log.V(loglevels.Verbose).Info("seeded state from the distribution", "path", persisted.Path, "copied", seeded)
+			if err := os.RemoveAll(data); err != nil {
+				return errors.Wrapf(err, "removing %s", data)
+			}
+		}
+
+		if err := os.MkdirAll(filepath.Dir(data), 0o755); err != nil {
+			return errors.Wrapf(err, "creating directory for %s", data)
+		}
+		link := filepath.Join(manifest.StateMount, statePath(persisted))
+		if err := os.Symlink(link, data); err != nil {
+			return errors.Wrapf(err, "linking %s", data)
+		}
+	}
+	return nil
+}
+
+// seedState copies the files in source into target that target doesn't have yet, leaving the ones the game changed
+func seedState(source, target string) (int, error) {
+	copied := 0
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(target, relative)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(destination, 0o755)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		if _, err := os.Lstat(destination); err == nil {
+			return nil
+		}
+		if err := copyFile(path, destination, info.Mode()); err != nil {
+			return errors.Wrapf(err, "copying %s", path)
+		}
+		copied++
+		return nil
+	})
+	return copied, err
+}
@@ -0,0 +1,262 @@
+package initializer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// downloadTimeout limits how long a single download may take
+const downloadTimeout = 5 * time.Minute
+
+// linkFile hardlinks a file, the tests replace it to fall back to copying
+var linkFile = os.Link
+
+// fileChecksum returns the hex SHA256 of the file
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// expectedChecksum returns the checksum the source should have: the given one, or the one in <source>.sha256.
+// It returns empty when there's nothing to check against.
+func expectedChecksum(source, checksum string) (string, error) {
+	if checksum != "" {
+		return strings.ToLower(checksum), nil
+	}
+	content, err := ioutil.ReadFile(source + ".sha256")
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "reading checksum file")
+	}
+	// sha256sum output: <checksum>  <name>
+	fields := strings.Fields(string(content))
+	if len(fields) == 0 {
+		return "", errors.Errorf("checksum file %s.sha256 is empty", source)
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// syncFile makes target a verified copy of source. It tries a hardlink for jars, and leaves a target that already
+// has the right content alone. When checksum is set, source has to match it.
+func syncFile(source, target, checksum string) (bool, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return false, errors.Wrap(err, "reading source")
+	}
+
+	sourceChecksum, err := fileChecksum(source)
+	if err != nil {
+		return false, errors.Wrapf(err, "checksumming %s", source)
+	}
+	if checksum != "" && sourceChecksum != checksum {
+		return false, errors.Errorf("checksum mismatch for %s: expected %s, got %s", source, checksum, sourceChecksum)
+	}
+
+	if targetChecksum, err := fileChecksum(target); err == nil && targetChecksum == sourceChecksum {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return false, errors.Wrapf(err, "creating directory for %s", target)
+	}
+
+	tmp := target + ".init-tmp"
+	os.Remove(tmp)
+	// only jars are linked, as the server rewrites other files (like configs) in place, which would change the source
+	linked := strings.HasSuffix(source, ".jar") && linkFile(source, tmp) == nil
+	if !linked {
+		// not a jar, different filesystem, or links aren't allowed: copy it instead
+		if err := copyFile(source, tmp, info.Mode()); err != nil {
+			os.Remove(tmp)
+			return false, errors.Wrapf(err, "copying %s", source)
+		}
+	}
+
+	copyChecksum, err := fileChecksum(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return false, errors.Wrapf(err, "checksumming copy of %s", source)
+	}
+	if copyChecksum != sourceChecksum {
+		os.Remove(tmp)
+		return false, errors.Errorf("copy of %s is corrupt: expected %s, got %s", source, sourceChecksum, copyChecksum)
+	}
+
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return false, errors.Wrapf(err, "moving copy of %s in place", source)
+	}
+	return true, nil
+}
+
+// downloadFile makes target a verified download of url. A target that already has the checksum isn't downloaded again.
+func downloadFile(url, target, checksum string) (bool, error) {
+	if checksum == "" {
+		return false, errors.Errorf("downloading %s needs a checksum", url)
+	}
+	if targetChecksum, err := fileChecksum(target); err == nil && targetChecksum == checksum {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return false, errors.Wrapf(err, "creating directory for %s", target)
+	}
+
+	client := http.Client{Timeout: downloadTimeout}
+	response, err := client.Get(url)
+	if err != nil {
+		return false, errors.Wrapf(err, "downloading %s", url)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return false, errors.Errorf("downloading %s: %s", url, response.Status)
+	}
+
+	tmp := target + ".init-tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return false, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), response.Body); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return false, errors.Wrapf(err, "downloading %s", url)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return false, err
+	}
+
+	if downloaded := hex.EncodeToString(hash.Sum(nil)); downloaded != checksum {
+		os.Remove(tmp)
+		return false, errors.Errorf("checksum mismatch for %s: expected %s, got %s", url, checksum, downloaded)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return false, errors.Wrapf(err, "moving download of %s in place", url)
+	}
+	return true, nil
+}
+
+// copyFile copies source to target with the given mode
+func copyFile(source, target string, mode os.FileMode) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncTree makes target hold a verified copy of every file in source. Files in target that aren't in source are
+// left alone, as the server creates its own files next to the distribution.
+func syncTree(source, target string) (int, error) {
+	copied := 0
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(target, relative)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(destination, 0o755)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return errors.Wrapf(err, "reading link %s", path)
+			}
+			os.Remove(destination)
+			return errors.Wrapf(os.Symlink(link, destination), "creating link %s", destination)
+		case !info.Mode().IsRegular() || strings.HasSuffix(path, ".sha256"):
+			return nil
+		}
+
+		checksum, err := expectedChecksum(path, "")
+		if err != nil {
+			return err
+		}
+		changed, err := syncFile(path, destination, checksum)
+		if changed {
+			copied++
+		}
+		return err
+	})
+	return copied, err
+}
+
+// pruneDirectory removes the files with the suffix from dir that aren't in keep
+func pruneDirectory(dir, suffix string, keep map[string]bool) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "listing %s", dir)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), suffix) || keep[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return removed, errors.Wrapf(err, "removing %s", entry.Name())
+		}
+		removed = append(removed, entry.Name())
+	}
+	return removed, nil
+}
+
+// moveContents moves everything in source into target, creating target through a temporary directory,
+// so a half finished move is retried instead of being taken as done
+func moveContents(source, target string) error {
+	if err := os.MkdirAll(source, 0o755); err != nil {
+		return errors.Wrapf(err, "creating %s", source)
+	}
+	tmp := target + ".tmp"
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return errors.Wrapf(err, "creating %s", tmp)
+	}
+
+	entries, err := ioutil.ReadDir(source)
+	if err != nil {
+		return errors.Wrapf(err, "listing %s", source)
+	}
+	for _, entry := range entries {
+		if err := os.Rename(filepath.Join(source, entry.Name()), filepath.Join(tmp, entry.Name())); err != nil {
+			return errors.Wrapf(err, "moving %s", entry.Name())
+		}
+	}
+	return errors.Wrapf(os.Rename(tmp, target), "moving %s in place", tmp)
+}
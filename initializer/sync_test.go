@@ -0,0 +1,304 @@
+package initializer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// checksum returns the hex SHA256 of the content
+func checksum(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(hash[:])
+}
+
+// writeTestFile writes the content to the path, creating its directory
+func writeTestFile(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readTestFile returns the content of the path
+func readTestFile(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// sameFile tells if both paths are the same file, as with a hardlink
+func sameFile(t *testing.T, a, b string) bool {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return os.SameFile(infoA, infoB)
+}
+
+func TestSyncFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		existing   string
+		checksum   string
+		linkFails  bool
+		expected   bool
+		wantLinked bool
+		wantErr    bool
+	}{
+		{name: "new jar", file: "mod.jar", expected: true, wantLinked: true},
+		{name: "new config", file: "server.properties", expected: true},
+		{name: "unchanged", file: "mod.jar", existing: "content"},
+		{name: "changed source", file: "mod.jar", existing: "old content", expected: true, wantLinked: true},
+		{name: "matching checksum", file: "mod.jar", checksum: checksum("content"), expected: true, wantLinked: true},
+		{name: "checksum mismatch", file: "mod.jar", checksum: checksum("other content"), wantErr: true},
+		{name: "hardlink fails", file: "mod.jar", linkFails: true, expected: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := filepath.Join(dir, "source", test.file)
+			target := filepath.Join(dir, "target", test.file)
+			writeTestFile(t, source, "content")
+			if test.existing != "" {
+				writeTestFile(t, target, test.existing)
+			}
+			if test.linkFails {
+				linkFile = func(string, string) error { return errors.New("cross-device link") }
+				defer func() { linkFile = os.Link }()
+			}
+
+			changed, err := syncFile(source, target, test.checksum)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if test.wantErr {
+				if _, err := os.Stat(target); !os.IsNotExist(err) {
+					t.Errorf("expected no target, got %v", err)
+				}
+				return
+			}
+			if changed != test.expected {
+				t.Errorf("expected changed %v, got %v", test.expected, changed)
+			}
+			if content := readTestFile(t, target); content != "content" {
+				t.Errorf("expected %q, got %q", "content", content)
+			}
+			if linked := sameFile(t, source, target); test.existing == "" || test.expected {
+				if linked != test.wantLinked {
+					t.Errorf("expected linked %v, got %v", test.wantLinked, linked)
+				}
+			}
+			if _, err := os.Stat(target + ".init-tmp"); !os.IsNotExist(err) {
+				t.Errorf("expected the temporary file to be gone, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/mod.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		existing     string
+		checksum     string
+		expected     bool
+		wantRequests int
+		wantErr      bool
+	}{
+		{name: "new", path: "/mod.jar", checksum: checksum("content"), expected: true, wantRequests: 1},
+		{name: "unchanged", path: "/mod.jar", existing: "content", checksum: checksum("content")},
+		{name: "changed", path: "/mod.jar", existing: "old content", checksum: checksum("content"), expected: true, wantRequests: 1},
+		{name: "checksum mismatch", path: "/mod.jar", checksum: checksum("other content"), wantRequests: 1, wantErr: true},
+		{name: "no checksum", path: "/mod.jar", wantErr: true},
+		{name: "not found", path: "/other.jar", checksum: checksum("content"), wantRequests: 1, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests = 0
+			target := filepath.Join(t.TempDir(), "mods", "mod.jar")
+			if test.existing != "" {
+				writeTestFile(t, target, test.existing)
+			}
+
+			changed, err := downloadFile(server.URL+test.path, target, test.checksum)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if requests != test.wantRequests {
+				t.Errorf("expected %d requests, got %d", test.wantRequests, requests)
+			}
+			if _, err := os.Stat(target + ".init-tmp"); !os.IsNotExist(err) {
+				t.Errorf("expected the temporary file to be gone, got %v", err)
+			}
+			if test.wantErr {
+				if _, err := os.Stat(target); !os.IsNotExist(err) {
+					t.Errorf("expected no target, got %v", err)
+				}
+				return
+			}
+			if changed != test.expected {
+				t.Errorf("expected changed %v, got %v", test.expected, changed)
+			}
+			if content := readTestFile(t, target); content != "content" {
+				t.Errorf("expected %q, got %q", "content", content)
+			}
+		})
+	}
+}
+
+func TestPruneDirectory(t *testing.T) {
+	tests := []struct {
+		name     string
+		suffix   string
+		keep     map[string]bool
+		expected []string
+	}{
+		{name: "stale jars", suffix: ".jar", keep: map[string]bool{"kept.jar": true}, expected: []string{"stale.jar"}},
+		{name: "all kept", suffix: ".jar", keep: map[string]bool{"kept.jar": true, "stale.jar": true}},
+		{name: "other suffix", suffix: ".zip", keep: map[string]bool{}, expected: []string{"pack.zip"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range []string{"kept.jar", "stale.jar", "config.toml", "pack.zip", "folder.jar/inside.jar"} {
+				writeTestFile(t, filepath.Join(dir, name), name)
+			}
+
+			removed, err := pruneDirectory(dir, test.suffix, test.keep)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(removed, test.expected) {
+				t.Errorf("expected removed %v, got %v", test.expected, removed)
+			}
+
+			entries, err := ioutil.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var left []string
+			for _, entry := range entries {
+				left = append(left, entry.Name())
+			}
+			for _, name := range test.expected {
+				for _, found := range left {
+					if found == name {
+						t.Errorf("expected %s to be removed", name)
+					}
+				}
+			}
+			if len(left)+len(test.expected) != 5 {
+				t.Errorf("expected only %v to be removed, left %v", test.expected, left)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	serverDir := filepath.Join(dir, "server-jars", "paper-1.20.4")
+	modsDir := filepath.Join(dir, "mod-jars")
+	writeTestFile(t, filepath.Join(serverDir, "server.jar"), "server")
+	writeTestFile(t, filepath.Join(serverDir, "start.sh"), "java -jar server.jar")
+	writeTestFile(t, filepath.Join(modsDir, "a.jar"), "a")
+	writeTestFile(t, filepath.Join(modsDir, "b.jar"), "b")
+	writeTestFile(t, filepath.Join(modsDir, "b.jar.sha256"), checksum("b")+"  b.jar\n")
+
+	manifest := &Manifest{
+		DataDir:   filepath.Join(dir, "data"),
+		ServerDir: serverDir,
+		Directories: []Directory{{
+			Path:        "mods",
+			Artifacts:   []Artifact{{Source: filepath.Join(modsDir, "a.jar")}, {Source: filepath.Join(modsDir, "b.jar")}},
+			PruneSuffix: ".jar",
+		}},
+		Files: []File{{Path: "server.properties", Content: "motd=hello\n"}},
+		Eula:  true,
+	}
+	// left from an earlier run, with a mod that's no longer listed
+	writeTestFile(t, filepath.Join(manifest.DataDir, "mods", "removed.jar"), "removed")
+	writeTestFile(t, filepath.Join(manifest.DataDir, "world", "level.dat"), "world")
+
+	expected := map[string]string{
+		"server.jar":        "server",
+		"start.sh":          "java -jar server.jar",
+		"mods/a.jar":        "a",
+		"mods/b.jar":        "b",
+		"server.properties": "motd=hello\n",
+		"eula.txt":          "eula=true\n",
+		"world/level.dat":   "world",
+	}
+	check := func(run string) {
+		found := make(map[string]string)
+		err := filepath.Walk(manifest.DataDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			relative, _ := filepath.Rel(manifest.DataDir, path)
+			found[filepath.ToSlash(relative)] = readTestFile(t, path)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("%s: expected %v, got %v", run, expected, found)
+		}
+	}
+
+	if err := Apply(zap.New(), manifest); err != nil {
+		t.Fatal(err)
+	}
+	check("first run")
+	before, err := os.Stat(filepath.Join(manifest.DataDir, "mods", "a.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Apply(zap.New(), manifest); err != nil {
+		t.Fatal(err)
+	}
+	check("second run")
+	after, err := os.Stat(filepath.Join(manifest.DataDir, "mods", "a.jar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(before, after) {
+		t.Errorf("expected an unchanged mod to be left alone")
+	}
+
+	writeTestFile(t, filepath.Join(modsDir, "b.jar"), "corrupt")
+	if err := Apply(zap.New(), manifest); err == nil {
+		t.Errorf("expected a checksum mismatch")
+	}
+}
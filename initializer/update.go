@@ -0,0 +1,160 @@
+package initializer
+
+import (
+	"github.com/go-logr/logr"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// snapshotVersionFile is the file in a snapshot with the server version it was taken of
+const snapshotVersionFile = "server-version"
+
+// applyUpdate checks the mods against the new version, and snapshots the world and the state before the Server
+// starts on it
+func applyUpdate(log logr.Logger, manifest *Manifest) error {
+	update := manifest.Update
+	log.V(loglevels.Info).Info("updating server version", "from", update.From, "to", update.To)
+
+	if update.MinecraftVersion != "" {
+		log.V(loglevels.Info).Info("checking mods", "minecraftVersion", update.MinecraftVersion, "mods", len(update.Mods))
+		if err := checkMods(log, update.Mods, update.MinecraftVersion); err != nil {
+			return err
+		}
+	}
+
+	if update.SnapshotDir == "" {
+		return nil
+	}
+	if _, err := os.Stat(update.SnapshotDir); err == nil {
+		log.V(loglevels.Flow).Info("snapshot has been taken before", "snapshot", update.SnapshotDir)
+	} else {
+		log.V(loglevels.Info).Info("taking snapshot", "snapshot", update.SnapshotDir)
+		if err := takeSnapshot(manifest, update.SnapshotDir, update.From); err != nil {
+			return errors.Wrap(err, "taking snapshot")
+		}
+	}
+	removed, err := pruneSnapshots(update.SnapshotDir, update.KeepSnapshots)
+	if len(removed) > 0 {
+		log.V(loglevels.Info).Info("removed old snapshots", "snapshots", removed)
+	}
+	return errors.Wrap(err, "removing old snapshots")
+}
+
+// takeSnapshot copies the world and the state into the snapshot directory, through a temporary directory, so a
+// half finished snapshot is taken again instead of being taken as done
+func takeSnapshot(manifest *Manifest, snapshotDir, version string) error {
+	tmp := snapshotDir + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return errors.Wrapf(err, "removing %s", tmp)
+	}
+	if err := os.MkdirAll(tmp, 0o755); err != nil {
+		return errors.Wrapf(err, "creating %s", tmp)
+	}
+	if err := copyTree(manifest.WorldDir, filepath.Join(tmp, "world")); err != nil {
+		return errors.Wrap(err, "copying world")
+	}
+	if manifest.StateDir != "" {
+		if err := copyTree(manifest.StateDir, filepath.Join(tmp, "state")); err != nil {
+			return errors.Wrap(err, "copying state")
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmp, snapshotVersionFile), []byte(version+"\n"), 0o644); err != nil {
+		return errors.Wrap(err, "writing server version")
+	}
+	return errors.Wrapf(os.Rename(tmp, snapshotDir), "moving %s in place", tmp)
+}
+
+// pruneSnapshots removes the snapshots next to snapshotDir that aren't in keep
+func pruneSnapshots(snapshotDir string, keep []string) ([]string, error) {
+	kept := map[string]bool{filepath.Base(snapshotDir): true}
+	for _, name := range keep {
+		kept[name] = true
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Dir(snapshotDir))
+	if err != nil {
+		return nil, errors.Wrap(err, "listing snapshots")
+	}
+	var removed []string
+	for _, entry := range entries {
+		if !entry.IsDir() || kept[entry.Name()] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(filepath.Dir(snapshotDir), entry.Name())); err != nil {
+			return removed, errors.Wrapf(err, "removing %s", entry.Name())
+		}
+		removed = append(removed, entry.Name())
+	}
+	return removed, nil
+}
+
+// restoreSnapshot moves the world and the state into the archive, and puts the ones of the snapshot back
+func restoreSnapshot(log logr.Logger, manifest *Manifest) error {
+	restore := manifest.Restore
+	if _, err := os.Stat(restore.ArchiveDir); err == nil {
+		log.V(loglevels.Flow).Info("snapshot has been restored before", "snapshot", restore.SnapshotDir)
+		return nil
+	}
+	if _, err := os.Stat(restore.SnapshotDir); err != nil {
+		return errors.Wrap(err, "snapshot not found")
+	}
+	log.V(loglevels.Info).Info("restoring snapshot", "snapshot", restore.SnapshotDir, "archive", restore.ArchiveDir)
+
+	// the archive gets its name when the restore is done, so a half finished one is restored again
+	tmp := restore.ArchiveDir + ".tmp"
+	dirs := map[string]string{"world": manifest.WorldDir}
+	if manifest.StateDir != "" {
+		dirs["state"] = manifest.StateDir
+	}
+	for name, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(tmp, name)); err != nil {
+			if err := moveContents(dir, filepath.Join(tmp, name)); err != nil {
+				return errors.Wrapf(err, "archiving %s", name)
+			}
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return errors.Wrapf(err, "clearing %s", name)
+		}
+		if err := copyTree(filepath.Join(restore.SnapshotDir, name), dir); err != nil {
+			return errors.Wrapf(err, "restoring %s", name)
+		}
+	}
+	return errors.Wrapf(os.Rename(tmp, restore.ArchiveDir), "moving %s in place", tmp)
+}
+
+// copyTree copies everything in source into target. A missing source is an empty one.
+func copyTree(source, target string) error {
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return errors.Wrapf(err, "creating %s", target)
+	}
+	if _, err := os.Stat(source); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(target, relative)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(destination, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return errors.Wrapf(err, "reading link %s", path)
+			}
+			return errors.Wrapf(os.Symlink(link, destination), "creating link %s", destination)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		return errors.Wrapf(copyFile(path, destination, info.Mode()), "copying %s", path)
+	})
+}
@@ -0,0 +1,206 @@
+// Package logparse turns the console output of vanilla, Forge, Fabric and Paper servers into events
+package logparse
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the kind of event a log line shows
+type Type string
+
+const (
+	// Started is the server being ready for players, with the time it took in Duration
+	Started Type = "Started"
+	// PlayerJoined is a player joining the game
+	PlayerJoined Type = "PlayerJoined"
+	// PlayerLeft is a player leaving the game
+	PlayerLeft Type = "PlayerLeft"
+	// Chat is a chat message of a player, in Message
+	Chat Type = "Chat"
+	// Advancement is a player making an advancement, in Message
+	Advancement Type = "Advancement"
+	// Death is a player dying, with the death message in Message
+	Death Type = "Death"
+	// CrashReport is the server crashing. Path is the crash report file, when the line has it
+	CrashReport Type = "CrashReport"
+	// Lag is the server not keeping up, with the time and ticks it's behind in Duration and Ticks
+	Lag Type = "Lag"
+	// ModError is a mod failing to load, or mods that don't go together
+	ModError Type = "ModError"
+)
+
+// Event is something the server logged
+type Event struct {
+	Type Type `json:"type"`
+	// Time is the timestamp of the line, as the server logged it
+	Time string `json:"time,omitempty"`
+	// Level is the log level of the line (e.g.: INFO, WARN)
+	Level    string        `json:"level,omitempty"`
+	Player   string        `json:"player,omitempty"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Ticks    int           `json:"ticks,omitempty"`
+	Path     string        `json:"path,omitempty"`
+}
+
+// Line is a log line split in its parts
+type Line struct {
+	Time    string
+	Thread  string
+	Level   string
+	Logger  string
+	Message string
+}
+
+var (
+	ansi = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+	// vanilla and fabric:  [12:34:56] [Server thread/INFO]: message
+	// forge up to 1.16:    [12:34:56] [Server thread/INFO] [minecraft/DedicatedServer]: message
+	// forge from 1.17:     [19Oct2026 12:34:56.789] [Server thread/INFO] [net.minecraft.server.dedicated.DedicatedServer/]: message
+	// fabric with loggers: [12:34:56] [Server thread/INFO] (Minecraft) message
+	threaded = regexp.MustCompile(`^\[([^\]]+)\] \[([^\]]*)/([A-Z]+)\](?: \[([^\]]*)\]:| \(([^)]*)\)|:) ?(.*)$`)
+
+	// paper and spigot:    [12:34:56 INFO]: message
+	paper = regexp.MustCompile(`^\[(\d\d:\d\d:\d\d) ([A-Z]+)\]: ?(.*)$`)
+
+	player = `([A-Za-z0-9_.]{1,16})`
+
+	done        = regexp.MustCompile(`^Done \(([\d.,]+)s\)!`)
+	joined      = regexp.MustCompile(`^` + player + ` joined the game$`)
+	left        = regexp.MustCompile(`^` + player + ` left the game$`)
+	chat        = regexp.MustCompile(`^(?:\[Not Secure\] )?<` + player + `> (.*)$`)
+	advancement = regexp.MustCompile(`^` + player + ` has (?:made the advancement|completed the challenge|reached the goal) \[(.+)\]$`)
+	lag         = regexp.MustCompile(`^Can't keep up! Is the server overloaded\? Running (\d+)ms or (\d+) ticks behind`)
+	crashSaved  = regexp.MustCompile(`crash report has been saved to: (.+?)\s*$`)
+
+	// deaths are the player name followed by one of the death messages of the game
+	death = regexp.MustCompile(`^` + player + ` (?:` + strings.Join([]string{
+		`was (?:slain|shot|pummeled|fireballed|killed|blown up|squashed|squished|impaled|skewered|stung|poked|pricked|` +
+			`struck by lightning|roasted|frozen|obliterated|doomed|burnt|spitballed|sniped|burned|knocked)`,
+		`drowned`, `died`, `blew up`, `burned to death`, `went up in flames`, `went off with a bang`, `walked into`,
+		`tried to swim in lava`, `hit the ground too hard`, `fell (?:from|off|out of|while|into)`, `starved to death`,
+		`suffocated in a wall`, `withered away`, `froze to death`, `experienced kinetic energy`,
+		`discovered the floor was lava`, `didn't want to live`, `left the confines of this world`,
+	}, "|") + `)(?: .*)?$`)
+
+	// mod loading errors, of forge and fabric, which are logged with and without a prefix
+	modErrors = []string{
+		"Missing or unsupported mandatory dependencies",
+		"Incompatible mods found",
+		"Incompatible mod set",
+		"Some of your mods are incompatible",
+		"Mod loading has failed",
+		"Failed to load mod",
+		"Mod resolution failed",
+		"Missing mods",
+		"Error loading mods",
+	}
+)
+
+// Split splits a log line in its parts. Lines that aren't from the logger, like stack traces, only have a Message.
+func Split(raw string) Line {
+	raw = strings.TrimRight(ansi.ReplaceAllString(raw, ""), "\r\n")
+	if parts := threaded.FindStringSubmatch(raw); parts != nil {
+		logger := parts[4]
+		if logger == "" {
+			logger = parts[5]
+		}
+		return Line{Time: parts[1], Thread: parts[2], Level: parts[3], Logger: logger, Message: parts[6]}
+	}
+	if parts := paper.FindStringSubmatch(raw); parts != nil {
+		return Line{Time: parts[1], Level: parts[2], Message: parts[3]}
+	}
+	return Line{Message: raw}
+}
+
+// ParseLine returns the event the log line shows, if any
+func ParseLine(raw string) (Event, bool) {
+	line := Split(raw)
+	event := Event{Time: line.Time, Level: line.Level}
+	message := line.Message
+
+	// these show up in stack traces and the crash report itself too, so any line counts
+	if parts := crashSaved.FindStringSubmatch(message); parts != nil {
+		event.Type = CrashReport
+		event.Path = parts[1]
+		event.Message = message
+		return event, true
+	}
+	if strings.HasPrefix(strings.TrimSpace(message), "---- Minecraft Crash Report ----") {
+		event.Type = CrashReport
+		event.Message = strings.TrimSpace(message)
+		return event, true
+	}
+	for _, modError := range modErrors {
+		if strings.Contains(message, modError) && line.Level != "INFO" && line.Level != "DEBUG" {
+			event.Type = ModError
+			event.Message = strings.TrimSpace(message)
+			return event, true
+		}
+	}
+
+	// the rest are logged by the game on the server thread, and not by plugins or mods pretending to be players
+	if line.Level == "" || (line.Thread != "" && line.Thread != "Server thread") ||
+		(strings.HasPrefix(message, "[") && !strings.HasPrefix(message, "[Not Secure]")) {
+		return event, false
+	}
+
+	switch {
+	case done.MatchString(message):
+		seconds, err := strconv.ParseFloat(strings.Replace(done.FindStringSubmatch(message)[1], ",", ".", 1), 64)
+		if err != nil {
+			return event, false
+		}
+		event.Type = Started
+		event.Duration = time.Duration(seconds * float64(time.Second))
+	case lag.MatchString(message):
+		parts := lag.FindStringSubmatch(message)
+		milliseconds, _ := strconv.Atoi(parts[1])
+		ticks, _ := strconv.Atoi(parts[2])
+		event.Type = Lag
+		event.Duration = time.Duration(milliseconds) * time.Millisecond
+		event.Ticks = ticks
+	case joined.MatchString(message):
+		event.Type = PlayerJoined
+		event.Player = joined.FindStringSubmatch(message)[1]
+	case left.MatchString(message):
+		event.Type = PlayerLeft
+		event.Player = left.FindStringSubmatch(message)[1]
+	case chat.MatchString(message):
+		parts := chat.FindStringSubmatch(message)
+		event.Type = Chat
+		event.Player = parts[1]
+		event.Message = parts[2]
+	case advancement.MatchString(message):
+		parts := advancement.FindStringSubmatch(message)
+		event.Type = Advancement
+		event.Player = parts[1]
+		event.Message = parts[2]
+	case death.MatchString(message):
+		event.Type = Death
+		event.Player = death.FindStringSubmatch(message)[1]
+		event.Message = message
+	default:
+		return event, false
+	}
+	return event, true
+}
+
+// Parse reads the log, and calls fn for the events in it
+func Parse(log io.Reader, fn func(Event)) error {
+	scanner := bufio.NewScanner(log)
+	// stack traces and mod lists make for long lines
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if event, ok := ParseLine(scanner.Text()); ok {
+			fn(event)
+		}
+	}
+	return scanner.Err()
+}
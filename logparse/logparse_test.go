@@ -0,0 +1,155 @@
+package logparse
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFixtures(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    []Event
+	}{
+		{
+			fixture: "vanilla.log",
+			want: []Event{
+				{Type: Started, Time: "08:12:10", Level: "INFO", Duration: 5733 * time.Millisecond},
+				{Type: PlayerJoined, Time: "08:15:31", Level: "INFO", Player: "Steve"},
+				{Type: Chat, Time: "08:15:40", Level: "INFO", Player: "Steve", Message: "hello, anyone here?"},
+				{Type: Advancement, Time: "08:16:02", Level: "INFO", Player: "Steve", Message: "Stone Age"},
+				{Type: Lag, Time: "08:21:17", Level: "WARN", Duration: 2043 * time.Millisecond, Ticks: 40},
+				{Type: Death, Time: "08:24:55", Level: "INFO", Player: "Steve", Message: "Steve was slain by Zombie"},
+				{Type: Death, Time: "08:25:12", Level: "INFO", Player: "Steve", Message: "Steve fell from a high place"},
+				{Type: PlayerLeft, Time: "08:30:00", Level: "INFO", Player: "Steve"},
+			},
+		},
+		{
+			fixture: "paper.log",
+			want: []Event{
+				{Type: Started, Time: "19:02:19", Level: "INFO", Duration: 8118 * time.Millisecond},
+				{Type: PlayerJoined, Time: "19:03:45", Level: "INFO", Player: "Alex"},
+				{Type: Chat, Time: "19:04:02", Level: "INFO", Player: "Alex", Message: "this is paper"},
+				{Type: Advancement, Time: "19:06:30", Level: "INFO", Player: "Alex", Message: "Monster Hunter"},
+				{Type: Lag, Time: "19:09:12", Level: "WARN", Duration: 5012 * time.Millisecond, Ticks: 100},
+				{Type: Death, Time: "19:12:44", Level: "INFO", Player: "Alex", Message: "Alex drowned"},
+				{Type: PlayerLeft, Time: "19:20:00", Level: "INFO", Player: "Alex"},
+			},
+		},
+		{
+			fixture: "forge-1.16.log",
+			want: []Event{
+				{Type: Started, Time: "14:00:44", Level: "INFO", Duration: 14113 * time.Millisecond},
+				{Type: PlayerJoined, Time: "14:02:10", Level: "INFO", Player: "Notch"},
+				{Type: Chat, Time: "14:02:20", Level: "INFO", Player: "Notch", Message: "forge works"},
+				{Type: Advancement, Time: "14:03:11", Level: "INFO", Player: "Notch", Message: "Sky's the Limit"},
+				{Type: Lag, Time: "14:04:00", Level: "WARN", Duration: 2500 * time.Millisecond, Ticks: 50},
+				{Type: Death, Time: "14:05:00", Level: "INFO", Player: "Notch", Message: "Notch was blown up by Creeper"},
+				{Type: PlayerLeft, Time: "14:05:30", Level: "INFO", Player: "Notch"},
+				{Type: CrashReport, Time: "14:06:00", Level: "ERROR",
+					Message: "This crash report has been saved to: /data/./crash-reports/crash-2026-10-19_14.06.00-server.txt",
+					Path:    "/data/./crash-reports/crash-2026-10-19_14.06.00-server.txt"},
+			},
+		},
+		{
+			fixture: "forge-1.20.log",
+			want: []Event{
+				{Type: ModError, Time: "19Oct2026 10:00:06.901", Level: "ERROR", Message: "Missing or unsupported mandatory dependencies:"},
+			},
+		},
+		{
+			fixture: "fabric.log",
+			want: []Event{
+				{Type: Started, Time: "10:11:28", Level: "INFO", Duration: 7500 * time.Millisecond},
+				{Type: PlayerJoined, Time: "10:12:00", Level: "INFO", Player: "Jeb_"},
+				{Type: Chat, Time: "10:12:30", Level: "INFO", Player: "Jeb_", Message: "fabric!"},
+				{Type: Death, Time: "10:13:00", Level: "INFO", Player: "Jeb_", Message: "Jeb_ tried to swim in lava"},
+				{Type: PlayerLeft, Time: "10:14:00", Level: "INFO", Player: "Jeb_"},
+			},
+		},
+		{
+			fixture: "fabric-incompatible.log",
+			want: []Event{
+				{Type: ModError, Time: "10:20:01", Level: "ERROR", Message: "Incompatible mod set!"},
+				{Type: ModError, Message: "net.fabricmc.loader.impl.FormattedException: Some of your mods are incompatible with the game or each other!"},
+			},
+		},
+		{
+			fixture: "crash.log",
+			want: []Event{
+				{Type: CrashReport, Message: "---- Minecraft Crash Report ----"},
+				{Type: CrashReport, Time: "21:00:01", Level: "ERROR",
+					Message: "This crash report has been saved to: ./crash-reports/crash-2026-10-19_21.00.00-server.txt",
+					Path:    "./crash-reports/crash-2026-10-19_21.00.00-server.txt"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			file, err := os.Open(filepath.Join("testdata", test.fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+
+			var got []Event
+			if err := Parse(file, func(event Event) { got = append(got, event) }); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d events, want %d: %+v", len(got), len(test.want), got)
+			}
+			for index := range got {
+				if !reflect.DeepEqual(got[index], test.want[index]) {
+					t.Errorf("event %d is\n%+v, want\n%+v", index, got[index], test.want[index])
+				}
+			}
+		})
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want *Event
+	}{
+		{
+			line: "\x1b[32m[12:00:00 INFO]: Done (1,25s)! For help, type \"help\"\x1b[0m",
+			want: &Event{Type: Started, Time: "12:00:00", Level: "INFO", Duration: 1250 * time.Millisecond},
+		},
+		{
+			line: "[12:00:00] [Server thread/INFO]: .BedrockSteve joined the game",
+			want: &Event{Type: PlayerJoined, Time: "12:00:00", Level: "INFO", Player: ".BedrockSteve"},
+		},
+		// plugins and other threads can't fake players
+		{line: "[12:00:00 INFO]: [Chat] <Steve> hi"},
+		{line: "[12:00:00] [Worker-Main-1/INFO]: Steve joined the game"},
+		// not a death
+		{line: "[12:00:00] [Server thread/INFO]: Steve lost connection: Disconnected"},
+		{line: "[12:00:00] [Server thread/INFO]: Steve[/10.0.0.1:1234] logged in with entity id 1 at (0.5, 64.0, 0.5)"},
+		// mod names in INFO lines aren't errors
+		{line: "[12:00:00] [main/INFO]: Failed to load mod list cache, rebuilding"},
+		{line: "\tat net.minecraft.server.MinecraftServer.run(MinecraftServer.java:123)"},
+		{line: ""},
+	}
+
+	for _, test := range tests {
+		got, ok := ParseLine(test.line)
+		if test.want == nil {
+			if ok {
+				t.Errorf("%q: got %+v, want no event", test.line, got)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%q: got no event, want %+v", test.line, *test.want)
+			continue
+		}
+		if !reflect.DeepEqual(got, *test.want) {
+			t.Errorf("%q: got %+v, want %+v", test.line, got, *test.want)
+		}
+	}
+}
@@ -17,8 +17,12 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
+	// embed the timezone database, as the distroless image doesn't have one for the Server schedules
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -28,11 +32,15 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	minecraftv1 "github.com/hsmade/minecraft-operator/api/v1"
 	"github.com/hsmade/minecraft-operator/controllers"
+	"github.com/hsmade/minecraft-operator/initializer"
+	"github.com/hsmade/minecraft-operator/modpack"
+	"github.com/hsmade/minecraft-operator/notify"
 	"github.com/hsmade/minecraft-operator/webui"
 	//+kubebuilder:scaffold:imports
 )
@@ -50,13 +58,37 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "init" {
+		// the init container of the Server Pods runs this binary as well
+		os.Exit(initializer.Main(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-modpack" {
+		os.Exit(modpack.Main(os.Args[2:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var webuiAddr string
+	var resourcePacksDir string
+	var namespaces string
+	var defaultConfigNamespace string
+	var webuiUserHeader string
+	var webuiGroupsHeader string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.StringVar(&webuiAddr, "web-ui-bind-address", ":8082", "The address the web ui binds to.")
+	flag.StringVar(&resourcePacksDir, "resource-packs-dir", "/resource-packs",
+		"The directory with the resource packs, that the Servers can refer to by path.")
+	flag.StringVar(&namespaces, "namespaces", "",
+		"Comma separated namespaces to watch for Servers. Defaults to all namespaces.")
+	flag.StringVar(&defaultConfigNamespace, "default-config-namespace", "",
+		"The namespace of the OperatorConfig for Servers in namespaces without one.")
+	flag.StringVar(&webuiUserHeader, "web-ui-user-header", "",
+		"The header with the user, set by the authenticating proxy in front of the web UI. "+
+			"When set, users only see and change the Servers their RBAC permits.")
+	flag.StringVar(&webuiGroupsHeader, "web-ui-groups-header", "",
+		"The header with the comma separated groups of the user, set by the authenticating proxy in front of the web UI.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -67,24 +99,50 @@ func main() {
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	controllers.Config.ResourcePacksDir = resourcePacksDir
+	controllers.Config.DefaultConfigNamespace = defaultConfigNamespace
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "3810ef21.hsmade.com",
-	})
+	}
+	if namespaces != "" {
+		watched := strings.Split(namespaces, ",")
+		setupLog.Info("watching namespaces", "namespaces", watched)
+		if len(watched) == 1 {
+			options.Namespace = watched[0]
+		} else {
+			options.NewCache = cache.MultiNamespacedCacheBuilder(watched)
+		}
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	// the init containers run the operator's own image, unless the OperatorConfig says otherwise
+	ownImage, err := controllers.OwnImage(context.Background(), mgr.GetAPIReader(), os.Getenv("POD_NAMESPACE"), os.Getenv("POD_NAME"))
+	if err != nil {
+		setupLog.Info("can't tell the image of the operator, using the default init image", "error", err)
+	} else {
+		setupLog.Info("using the image of the operator as the default init image", "image", ownImage)
+		controllers.Config.InitImage = ownImage
+	}
+
 	if err = (&controllers.ServerReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("Server"),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Server"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("minecraft-operator"),
+		Notifier: notify.New(),
+
+		RestConfig: mgr.GetConfig(),
+		APIReader:  mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Server")
 		os.Exit(1)
@@ -98,6 +156,15 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "OperatorConfig")
 		os.Exit(1)
 	}
+	if err = (&controllers.NetworkReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Network"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("minecraft-operator"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Network")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -110,7 +177,8 @@ func main() {
 	}
 
 	go func() {
-		if err := webui.Run(webuiAddr, mgr.GetClient(), ctrl.Log.WithName("webui").WithName("Server")); err != nil {
+		if err := webui.Run(webuiAddr, mgr.GetClient(), ctrl.Log.WithName("webui").WithName("Server"),
+			webuiUserHeader, webuiGroupsHeader); err != nil {
 			setupLog.Error(err, "failed to start web UI")
 			os.Exit(1)
 		}
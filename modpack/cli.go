@@ -0,0 +1,199 @@
+package modpack
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/transfer"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"os"
+	"path/filepath"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"strings"
+)
+
+// cliOptions are the flags of the import-modpack command
+type cliOptions struct {
+	Options
+	dryRun           bool
+	mirror           string
+	curseForgeURL    string
+	curseForgeAPIKey string
+	modJarsPVC       string
+}
+
+// Main runs the import-modpack command: it imports the modpack file as a new Server, using the current kube context.
+func Main(args []string) int {
+	flags := flag.NewFlagSet("import-modpack", flag.ExitOnError)
+	var options cliOptions
+	flags.StringVar(&options.Name, "name", "", "The name of the Server. Defaults to the name of the modpack.")
+	flags.StringVar(&options.Namespace, "namespace", "default", "The namespace of the Server.")
+	memory := flags.Int("memory", 4096, "The memory of the Server, in MB.")
+	flags.BoolVar(&options.dryRun, "dry-run", false, "Print the Server and ConfigMap instead of importing.")
+	flags.StringVar(&options.mirror, "mirror", "", "Download the mods from this mirror of the download hosts.")
+	flags.StringVar(&options.curseForgeURL, "curseforge-url", DefaultCurseForgeURL, "The CurseForge API.")
+	flags.StringVar(&options.curseForgeAPIKey, "curseforge-api-key", os.Getenv("CURSEFORGE_API_KEY"),
+		"The CurseForge API key, for CurseForge modpacks. Defaults to $CURSEFORGE_API_KEY.")
+	flags.StringVar(&options.modJarsPVC, "mod-jars-pvc", "",
+		"The mod jars PVC, as namespace/name. Defaults to the one in the OperatorConfig.")
+	zapOptions := zap.Options{
+		Development: true,
+	}
+	zapOptions.BindFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s import-modpack [flags] <modpack.mrpack|modpack.zip>\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	log := zap.New(zap.UseFlagOptions(&zapOptions)).WithName("import-modpack")
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	options.MemoryMB = int32(*memory)
+
+	if err := runCLI(log, flags.Arg(0), options); err != nil {
+		log.Error(err, "import failed")
+		return 1
+	}
+	return 0
+}
+
+// runCLI imports the modpack file, or prints what it would import
+func runCLI(log logr.Logger, file string, options cliOptions) error {
+	ctx := context.Background()
+
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return errors.Wrapf(err, "opening %s", filepath.Base(file))
+	}
+	defer archive.Close()
+
+	importer := &Importer{
+		Downloader: &Downloader{Mirror: options.mirror},
+		CurseForge: &CurseForge{URL: options.curseForgeURL, APIKey: options.curseForgeAPIKey},
+		Log:        log,
+	}
+
+	if options.dryRun {
+		pack, err := Read(ctx, &archive.Reader, importer.CurseForge)
+		if err != nil {
+			return errors.Wrap(err, "reading modpack")
+		}
+		server, configMap, err := BuildServer(pack, options.Options)
+		if err != nil {
+			return err
+		}
+		printSkipped(pack)
+		return printList(server, configMap)
+	}
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return errors.Wrap(err, "getting kube config")
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return err
+	}
+	if err := v1.AddToScheme(scheme); err != nil {
+		return err
+	}
+	kClient, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		return errors.Wrap(err, "creating kube client")
+	}
+
+	modJarsPVC, helperImage, err := findModJarsPVC(ctx, kClient, options.Namespace, options.modJarsPVC)
+	if err != nil {
+		return err
+	}
+
+	t := &transfer.Transfer{
+		Client:      kClient,
+		Config:      config,
+		Log:         log.WithName("transfer"),
+		HelperImage: helperImage,
+	}
+	server, pack, err := importer.Import(ctx, kClient, t, modJarsPVC, &archive.Reader, options.Options)
+	if err != nil {
+		return err
+	}
+	printSkipped(pack)
+	fmt.Printf("created server %s/%s for %s %s, running %s\n", server.Namespace, server.Name, pack.Name, pack.Version,
+		pack.ServerVersion)
+	fmt.Printf("make sure the server jars PVC has %s, then enable the server\n", pack.ServerVersion)
+	return nil
+}
+
+// findModJarsPVC returns the mod jars PVC, from the flag (namespace/name) or from the OperatorConfig for the
+// namespace, and the image for the helper Pod
+func findModJarsPVC(ctx context.Context, kClient client.Client, namespace, flagValue string) (*corev1.PersistentVolumeClaim, string, error) {
+	var configs v1.OperatorConfigList
+	if err := kClient.List(ctx, &configs); err != nil {
+		return nil, "", errors.Wrap(err, "listing OperatorConfigs")
+	}
+	config := helpers.OperatorConfigFor(configs.Items, namespace)
+	helperImage := "busybox"
+	if config != nil && config.Spec.InitContainerImage != "" {
+		helperImage = config.Spec.InitContainerImage
+	}
+
+	var key client.ObjectKey
+	switch {
+	case flagValue != "":
+		parts := strings.SplitN(flagValue, "/", 2)
+		if len(parts) != 2 {
+			return nil, "", errors.Errorf("invalid mod jars PVC %q, expected namespace/name", flagValue)
+		}
+		key = client.ObjectKey{Namespace: parts[0], Name: parts[1]}
+	case config != nil:
+		key = client.ObjectKey{Namespace: config.Namespace, Name: config.Spec.ModJarsPVC}
+	default:
+		return nil, "", errors.Errorf("found no OperatorConfig for namespace %s among %d, pass the mod jars PVC with -mod-jars-pvc",
+			namespace, len(configs.Items))
+	}
+
+	var claim corev1.PersistentVolumeClaim
+	if err := kClient.Get(ctx, key, &claim); err != nil {
+		return nil, "", errors.Wrapf(err, "getting mod jars PVC %s", key)
+	}
+	return &claim, helperImage, nil
+}
+
+// printSkipped tells which files of the modpack aren't installed on the server
+func printSkipped(pack *Pack) {
+	for _, name := range pack.Skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s\n", name)
+	}
+}
+
+// printList prints the objects as a List, that kubectl can apply
+func printList(objects ...runtime.Object) error {
+	list := metav1.List{TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "List"}}
+	for _, object := range objects {
+		raw, err := json.Marshal(object)
+		if err != nil {
+			return err
+		}
+		list.Items = append(list.Items, runtime.RawExtension{Raw: raw})
+	}
+	output, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(output))
+	return nil
+}
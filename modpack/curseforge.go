@@ -0,0 +1,181 @@
+package modpack
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// curseForgeManifest is the file that describes a CurseForge modpack
+const curseForgeManifest = "manifest.json"
+
+// DefaultCurseForgeURL is the CurseForge API
+const DefaultCurseForgeURL = "https://api.curseforge.com"
+
+// curseForgeHashAlgorithms maps the hash algorithms of the CurseForge API to their names
+var curseForgeHashAlgorithms = map[int]string{1: "sha1", 2: "md5"}
+
+// curseForgeModpack is the manifest.json of a CurseForge modpack
+type curseForgeModpack struct {
+	ManifestType string `json:"manifestType"`
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Minecraft    struct {
+		Version    string `json:"version"`
+		ModLoaders []struct {
+			ID      string `json:"id"`
+			Primary bool   `json:"primary"`
+		} `json:"modLoaders"`
+	} `json:"minecraft"`
+	Files []struct {
+		ProjectID int  `json:"projectID"`
+		FileID    int  `json:"fileID"`
+		Required  bool `json:"required"`
+	} `json:"files"`
+	Overrides string `json:"overrides"`
+}
+
+// CurseForge looks up the files of CurseForge modpacks
+type CurseForge struct {
+	// URL is the base URL of the CurseForge API. Defaults to DefaultCurseForgeURL
+	URL string
+
+	// APIKey is the key for the CurseForge API
+	APIKey string
+
+	// Client is the HTTP client to use. Defaults to a client with a timeout
+	Client *http.Client
+}
+
+// curseForgeFile is a file as the CurseForge API returns it
+type curseForgeFile struct {
+	FileName    string `json:"fileName"`
+	FileLength  int64  `json:"fileLength"`
+	DownloadURL string `json:"downloadUrl"`
+	Hashes      []struct {
+		Value string `json:"value"`
+		Algo  int    `json:"algo"`
+	} `json:"hashes"`
+}
+
+// File looks up a file of a CurseForge project
+func (c *CurseForge) File(ctx context.Context, projectID, fileID int) (*curseForgeFile, error) {
+	base := c.URL
+	if base == "" {
+		base = DefaultCurseForgeURL
+	}
+	httpClient := c.Client
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		fmt.Sprintf("%s/v1/mods/%d/files/%d", strings.TrimSuffix(base, "/"), projectID, fileID), nil)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "application/json")
+	request.Header.Set("x-api-key", c.APIKey)
+
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, errors.Wrapf(err, "looking up file %d of project %d", fileID, projectID)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("looking up file %d of project %d: %s", fileID, projectID, response.Status)
+	}
+
+	var result struct {
+		Data curseForgeFile `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, errors.Wrapf(err, "parsing file %d of project %d", fileID, projectID)
+	}
+	return &result.Data, nil
+}
+
+// readCurseForge reads a CurseForge modpack, looking up its files
+func readCurseForge(ctx context.Context, archive *zip.Reader, curseForge *CurseForge) (*Pack, error) {
+	content, err := readFile(archive, curseForgeManifest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest curseForgeModpack
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", curseForgeManifest)
+	}
+	if manifest.ManifestType != "minecraftModpack" {
+		return nil, errors.Errorf("unsupported CurseForge manifest type %q", manifest.ManifestType)
+	}
+
+	pack := &Pack{Name: manifest.Name, Version: manifest.Version}
+
+	// loaders are written as forge-47.2.0
+	var loader, loaderVersion string
+	for _, modLoader := range manifest.Minecraft.ModLoaders {
+		if loader == "" || modLoader.Primary {
+			parts := strings.SplitN(modLoader.ID, "-", 2)
+			if len(parts) != 2 {
+				return nil, errors.Errorf("invalid mod loader %q", modLoader.ID)
+			}
+			loader, loaderVersion = parts[0], parts[1]
+		}
+	}
+	pack.ServerVersion, err = serverVersion(manifest.Minecraft.Version, loader, loaderVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range manifest.Files {
+		if !file.Required {
+			pack.Skipped = append(pack.Skipped, fmt.Sprintf("project %d file %d (optional)", file.ProjectID, file.FileID))
+			continue
+		}
+		found, err := curseForge.File(ctx, file.ProjectID, file.FileID)
+		if err != nil {
+			return nil, err
+		}
+		name, err := safePath(found.FileName)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(name, "/") || !strings.HasSuffix(name, ".jar") {
+			// resource packs and the like are for the client
+			pack.Skipped = append(pack.Skipped, name)
+			continue
+		}
+		if found.DownloadURL == "" {
+			return nil, errors.Errorf("%s (project %d) can't be downloaded outside of CurseForge, its author doesn't allow it",
+				name, file.ProjectID)
+		}
+
+		mod := Mod{
+			Name:   name,
+			URLs:   []string{found.DownloadURL},
+			Hashes: make(map[string]string),
+			Size:   found.FileLength,
+		}
+		for _, hash := range found.Hashes {
+			if algorithm, ok := curseForgeHashAlgorithms[hash.Algo]; ok {
+				mod.Hashes[algorithm] = hash.Value
+			}
+		}
+		pack.Mods = append(pack.Mods, mod)
+	}
+
+	overrides := manifest.Overrides
+	if overrides == "" {
+		overrides = "overrides"
+	}
+	pack.Overrides, err = readOverrides(archive, overrides)
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
@@ -0,0 +1,141 @@
+package modpack
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"github.com/pkg/errors"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// hashAlgorithms are the hash algorithms a mod can be checked with, strongest first
+var hashAlgorithms = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha512", sha512.New},
+	{"sha1", sha1.New},
+	{"md5", md5.New},
+}
+
+// sha256Hex returns the hex sha256 of the content
+func sha256Hex(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Downloader downloads the mods of a modpack
+type Downloader struct {
+	// Client is the HTTP client to use. Defaults to a client with a timeout
+	Client *http.Client
+
+	// Mirror replaces the scheme and host of the download URLs (e.g.: http://mirror.local), when set.
+	// The path is kept, so a mirror of the download hosts serves the same files.
+	Mirror string
+}
+
+// mirrored returns the URL to download from
+func (d *Downloader) mirrored(source string) (string, error) {
+	if d.Mirror == "" {
+		return source, nil
+	}
+	parsed, err := url.Parse(source)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing %s", source)
+	}
+	mirror, err := url.Parse(d.Mirror)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing mirror")
+	}
+	parsed.Scheme, parsed.Host = mirror.Scheme, mirror.Host
+	parsed.Path = strings.TrimSuffix(mirror.Path, "/") + parsed.Path
+	parsed.RawPath = ""
+	return parsed.String(), nil
+}
+
+// Download downloads the mod into a temporary file, checked against the strongest hash of the mod.
+// It returns the file, positioned at the start, and the sha256 of the mod. The caller removes the file.
+func (d *Downloader) Download(ctx context.Context, mod Mod) (*os.File, string, error) {
+	var expected string
+	var checker hash.Hash
+	for _, algorithm := range hashAlgorithms {
+		if value, ok := mod.Hashes[algorithm.name]; ok && value != "" {
+			expected, checker = strings.ToLower(value), algorithm.new()
+			break
+		}
+	}
+	if checker == nil {
+		return nil, "", errors.Errorf("%s has no checksum", mod.Name)
+	}
+
+	var lastErr error
+	for _, source := range mod.URLs {
+		file, checksum, err := d.download(ctx, source, expected, checker)
+		if err == nil {
+			return file, checksum, nil
+		}
+		lastErr = err
+		checker.Reset()
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no download URLs")
+	}
+	return nil, "", errors.Wrapf(lastErr, "downloading %s", mod.Name)
+}
+
+// download downloads the URL into a temporary file, checking that checker sums it to expected
+func (d *Downloader) download(ctx context.Context, source, expected string, checker hash.Hash) (*os.File, string, error) {
+	httpClient := d.Client
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 5 * time.Minute}
+	}
+
+	target, err := d.mirrored(source)
+	if err != nil {
+		return nil, "", err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, "", errors.Errorf("%s: %s", target, response.Status)
+	}
+
+	file, err := ioutil.TempFile("", "mod-*.jar")
+	if err != nil {
+		return nil, "", errors.Wrap(err, "creating temporary file")
+	}
+	checksum := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, checker, checksum), response.Body); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, "", errors.Wrapf(err, "reading %s", target)
+	}
+	if got := hex.EncodeToString(checker.Sum(nil)); got != expected {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, "", errors.Errorf("checksum mismatch for %s: expected %s, got %s", target, expected, got)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, "", err
+	}
+	return file, hex.EncodeToString(checksum.Sum(nil)), nil
+}
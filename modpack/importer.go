@@ -0,0 +1,279 @@
+package modpack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/transfer"
+	"github.com/pkg/errors"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"os"
+	"path"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxOverridesSize limits the size of the overrides that aren't mods, as they're stored in a ConfigMap,
+// and the operator puts them in the files Secret of the Server
+const maxOverridesSize = 1000 * 1000
+
+// Options are the settings for the Server of an imported modpack
+type Options struct {
+	// Name is the name of the Server. Defaults to the name and version of the modpack
+	Name string
+
+	// Namespace is the namespace of the Server
+	Namespace string
+
+	// MemoryMB is the max and initial memory of the Server
+	MemoryMB int32
+}
+
+// Importer imports modpacks as Servers
+type Importer struct {
+	Downloader *Downloader
+	CurseForge *CurseForge
+	Log        logr.Logger
+}
+
+// ModsDir returns the directory on the mod jars PVC that holds the mods of the modpack
+func ModsDir(pack *Pack) string {
+	return path.Join("modpacks", pack.Slug())
+}
+
+// OverridesConfigMapName returns the name of the ConfigMap that holds the overrides for the Server
+func OverridesConfigMapName(name string) string {
+	return name + "-modpack"
+}
+
+// isModOverride tells if the override is a mod, which goes onto the mod jars PVC instead of the ConfigMap
+func isModOverride(override Override) bool {
+	return path.Dir(override.Path) == "mods" && strings.HasSuffix(override.Path, ".jar")
+}
+
+// ModJars returns the mods of the modpack, as they're listed in the Server
+func ModJars(pack *Pack) []string {
+	names := make(map[string]bool)
+	for _, mod := range pack.Mods {
+		names[mod.Name] = true
+	}
+	for _, override := range pack.Overrides {
+		if isModOverride(override) {
+			names[path.Base(override.Path)] = true
+		}
+	}
+
+	var modJars []string
+	for name := range names {
+		modJars = append(modJars, path.Join(ModsDir(pack), name))
+	}
+	sort.Strings(modJars)
+	return modJars
+}
+
+// BuildServer builds the Server for the modpack, and the ConfigMap with the overrides its files come from
+func BuildServer(pack *Pack, options Options) (*v1.Server, *corev1.ConfigMap, error) {
+	if options.Name == "" {
+		options.Name = pack.Slug()
+	}
+
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      OverridesConfigMapName(options.Name),
+			Namespace: options.Namespace,
+		},
+		BinaryData: make(map[string][]byte),
+	}
+
+	server := &v1.Server{
+		TypeMeta: metav1.TypeMeta{APIVersion: v1.GroupVersion.String(), Kind: "Server"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      options.Name,
+			Namespace: options.Namespace,
+			Annotations: map[string]string{
+				"minecraft.hsmade.com/modpack": strings.TrimSpace(pack.Name + " " + pack.Version),
+			},
+		},
+		Spec: v1.ServerSpec{
+			ModJars:       ModJars(pack),
+			Properties:    map[string]string{"motd": pack.Name},
+			MaxMemory:     options.MemoryMB,
+			InitMemory:    options.MemoryMB,
+			ServerVersion: pack.ServerVersion,
+		},
+	}
+
+	size := 0
+	for _, override := range pack.Overrides {
+		if isModOverride(override) {
+			continue
+		}
+		size += len(override.Content)
+		if size > maxOverridesSize {
+			return nil, nil, errors.Errorf("the overrides of the modpack are larger than %d bytes, which doesn't fit a ConfigMap",
+				maxOverridesSize)
+		}
+
+		// ConfigMap keys can't hold slashes, so the files get numbered keys
+		key := fmt.Sprintf("override-%d", len(configMap.BinaryData))
+		configMap.BinaryData[key] = override.Content
+		server.Spec.Files = append(server.Spec.Files, v1.ServerFile{
+			Path: override.Path,
+			ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: configMap.Name},
+				Key:                  key,
+			},
+		})
+	}
+
+	return server, configMap, nil
+}
+
+// WriteMods downloads the mods of the modpack, and writes them as a tar stream for the mod jars PVC, each with a
+// .sha256 file that the init binary checks them against. Mods from the overrides are included.
+func (i *Importer) WriteMods(ctx context.Context, pack *Pack, w io.Writer) error {
+	tarWriter := tar.NewWriter(w)
+	dir := ModsDir(pack)
+	now := time.Now()
+
+	for _, name := range []string{"modpacks", dir} {
+		err := tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0o755, ModTime: now})
+		if err != nil {
+			return errors.Wrapf(err, "writing %s", name)
+		}
+	}
+
+	writeFile := func(name string, content io.Reader, size int64) error {
+		err := tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path.Join(dir, name),
+			Mode:     0o644,
+			Size:     size,
+			ModTime:  now,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "writing %s", name)
+		}
+		_, err = io.Copy(tarWriter, content)
+		return errors.Wrapf(err, "writing %s", name)
+	}
+	writeChecksum := func(name, checksum string) error {
+		line := fmt.Sprintf("%s  %s\n", checksum, name)
+		return writeFile(name+".sha256", strings.NewReader(line), int64(len(line)))
+	}
+
+	overridden := make(map[string]bool)
+	for _, override := range pack.Overrides {
+		if isModOverride(override) {
+			overridden[path.Base(override.Path)] = true
+		}
+	}
+
+	for _, mod := range pack.Mods {
+		if overridden[mod.Name] {
+			continue
+		}
+		i.Log.V(loglevels.Verbose).Info("downloading mod", "mod", mod.Name)
+		file, checksum, err := i.Downloader.Download(ctx, mod)
+		if err != nil {
+			return err
+		}
+		info, err := file.Stat()
+		if err == nil {
+			err = writeFile(mod.Name, file, info.Size())
+		}
+		file.Close()
+		os.Remove(file.Name())
+		if err != nil {
+			return err
+		}
+		if err := writeChecksum(mod.Name, checksum); err != nil {
+			return err
+		}
+	}
+
+	for _, override := range pack.Overrides {
+		if !isModOverride(override) {
+			continue
+		}
+		name := path.Base(override.Path)
+		i.Log.V(loglevels.Verbose).Info("adding mod from overrides", "mod", name)
+		if err := writeFile(name, bytes.NewReader(override.Content), int64(len(override.Content))); err != nil {
+			return err
+		}
+		if err := writeChecksum(name, sha256Hex(override.Content)); err != nil {
+			return err
+		}
+	}
+
+	return tarWriter.Close()
+}
+
+// Import reads the modpack, puts its mods on the mod jars PVC, and creates the Server with the ConfigMap for its
+// overrides. The Server is created disabled, so it can be checked before it starts.
+func (i *Importer) Import(ctx context.Context, kClient client.Client, t *transfer.Transfer,
+	modJarsPVC *corev1.PersistentVolumeClaim, archive *zip.Reader, options Options) (*v1.Server, *Pack, error) {
+	i.Log.V(loglevels.Verbose).Info("importing modpack", "namespace", options.Namespace)
+
+	pack, err := Read(ctx, archive, i.CurseForge)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "reading modpack")
+	}
+	i.Log.V(loglevels.Flow).Info("read modpack", "name", pack.Name, "version", pack.Version,
+		"serverVersion", pack.ServerVersion, "mods", len(pack.Mods), "skipped", pack.Skipped)
+
+	server, configMap, err := BuildServer(pack, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var existing v1.Server
+	err = kClient.Get(ctx, client.ObjectKeyFromObject(server), &existing)
+	if err == nil {
+		return nil, nil, errors.Errorf("server %s already exists", server.Name)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, nil, errors.Wrap(err, "checking for existing server")
+	}
+
+	i.Log.V(loglevels.Info).Info("uploading mods", "pvc", modJarsPVC.Name, "dir", ModsDir(pack))
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(i.WriteMods(ctx, pack, writer))
+	}()
+	err = t.UploadJars(ctx, modJarsPVC, reader)
+	reader.Close()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "uploading mods")
+	}
+
+	i.Log.V(loglevels.Info).Info("creating server", "server", server.Name)
+	if err := kClient.Create(ctx, server); err != nil {
+		return nil, nil, errors.Wrap(err, "creating server")
+	}
+
+	// the overrides go with the Server when it's removed. The Server isn't their controller, as the reconciler takes
+	// the ConfigMaps it controls for its own
+	configMap.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: v1.GroupVersion.String(),
+		Kind:       "Server",
+		Name:       server.Name,
+		UID:        server.UID,
+	}}
+	if err := kClient.Create(ctx, configMap); err != nil {
+		return nil, nil, errors.Wrap(err, "creating overrides configMap")
+	}
+
+	return server, pack, nil
+}
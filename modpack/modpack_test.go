@@ -0,0 +1,430 @@
+package modpack
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/go-logr/logr"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildArchive zips the files into an archive
+func buildArchive(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range files {
+		entry, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+// mustJSON serializes the value
+func mustJSON(t *testing.T, value interface{}) string {
+	t.Helper()
+	content, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func sha1Hex(content string) string {
+	sum := sha1.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha512Hex(content string) string {
+	sum := sha512.Sum512([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// mirror is a local stand-in for the download hosts and the CurseForge API
+type mirror struct {
+	*httptest.Server
+	files    map[string]string
+	requests []string
+}
+
+func newMirror(t *testing.T, files map[string]string) *mirror {
+	m := &mirror{files: files}
+	m.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.requests = append(m.requests, r.URL.Path)
+		if strings.HasPrefix(r.URL.Path, "/v1/") && r.Header.Get("x-api-key") != "test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		content, ok := m.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(m.Close)
+	return m
+}
+
+// readTar returns the regular files in the tar stream
+func readTar(t *testing.T, content []byte) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	reader := tar.NewReader(bytes.NewReader(content))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(data)
+	}
+}
+
+const jeiJar = "jei jar content"
+const sodiumJar = "sodium jar content"
+const lithiumJar = "lithium jar content"
+
+// modrinthPack builds a .mrpack with a server mod, a client-only mod, a resource pack and overrides
+func modrinthPack(t *testing.T, dependencies map[string]string) *zip.Reader {
+	index := map[string]interface{}{
+		"formatVersion": 1,
+		"game":          "minecraft",
+		"versionId":     "1.2.0",
+		"name":          "Family Pack",
+		"files": []interface{}{
+			map[string]interface{}{
+				"path":      "mods/jei.jar",
+				"hashes":    map[string]string{"sha1": sha1Hex(jeiJar), "sha512": sha512Hex(jeiJar)},
+				"env":       map[string]string{"client": "required", "server": "required"},
+				"downloads": []string{"https://cdn.modrinth.com/data/missing/jei.jar", "https://cdn.modrinth.com/data/jei/jei.jar"},
+				"fileSize":  len(jeiJar),
+			},
+			map[string]interface{}{
+				"path":      "mods/sodium.jar",
+				"hashes":    map[string]string{"sha1": sha1Hex(sodiumJar)},
+				"env":       map[string]string{"client": "required", "server": "unsupported"},
+				"downloads": []string{"https://cdn.modrinth.com/data/sodium/sodium.jar"},
+			},
+			map[string]interface{}{
+				"path":      "resourcepacks/faithful.zip",
+				"hashes":    map[string]string{"sha1": sha1Hex("faithful")},
+				"downloads": []string{"https://cdn.modrinth.com/data/faithful/faithful.zip"},
+			},
+		},
+		"dependencies": dependencies,
+	}
+	return buildArchive(t, map[string]string{
+		modrinthIndex:                       mustJSON(t, index),
+		"overrides/config/jei.toml":         "client = true",
+		"overrides/config/common.toml":      "common = 1",
+		"server-overrides/config/jei.toml":  "client = false",
+		"server-overrides/mods/lithium.jar": lithiumJar,
+		"client-overrides/options.txt":      "fov:90",
+	})
+}
+
+func TestReadModrinth(t *testing.T) {
+	tests := []struct {
+		name          string
+		dependencies  map[string]string
+		serverVersion string
+		wantErr       bool
+	}{
+		{"fabric", map[string]string{"minecraft": "1.20.1", "fabric-loader": "0.15.7"}, "fabric-1.20.1-0.15.7", false},
+		{"forge", map[string]string{"minecraft": "1.20.1", "forge": "47.2.0"}, "forge-1.20.1-47.2.0", false},
+		{"neoforge", map[string]string{"minecraft": "1.20.4", "neoforge": "20.4.80"}, "neoforge-1.20.4-20.4.80", false},
+		{"quilt", map[string]string{"minecraft": "1.20.1", "quilt-loader": "0.23.1"}, "quilt-1.20.1-0.23.1", false},
+		{"vanilla", map[string]string{"minecraft": "1.20.1"}, "vanilla-1.20.1", false},
+		{"two loaders", map[string]string{"minecraft": "1.20.1", "forge": "47.2.0", "fabric-loader": "0.15.7"}, "", true},
+		{"no minecraft", map[string]string{"forge": "47.2.0"}, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pack, err := Read(context.Background(), modrinthPack(t, test.dependencies), nil)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if pack.ServerVersion != test.serverVersion {
+				t.Errorf("ServerVersion = %q, want %q", pack.ServerVersion, test.serverVersion)
+			}
+			if pack.Name != "Family Pack" || pack.Version != "1.2.0" {
+				t.Errorf("Name, Version = %q, %q", pack.Name, pack.Version)
+			}
+			if len(pack.Mods) != 1 || pack.Mods[0].Name != "jei.jar" {
+				t.Errorf("Mods = %+v, want only jei.jar", pack.Mods)
+			}
+			if want := []string{"mods/sodium.jar", "resourcepacks/faithful.zip"}; !reflect.DeepEqual(pack.Skipped, want) {
+				t.Errorf("Skipped = %v, want %v", pack.Skipped, want)
+			}
+
+			overrides := make(map[string]string)
+			for _, override := range pack.Overrides {
+				overrides[override.Path] = string(override.Content)
+			}
+			want := map[string]string{
+				"config/jei.toml":    "client = false",
+				"config/common.toml": "common = 1",
+				"mods/lithium.jar":   lithiumJar,
+			}
+			if !reflect.DeepEqual(overrides, want) {
+				t.Errorf("Overrides = %v, want %v", overrides, want)
+			}
+		})
+	}
+}
+
+func TestReadRefusesUnsafePaths(t *testing.T) {
+	index := map[string]interface{}{
+		"formatVersion": 1,
+		"game":          "minecraft",
+		"name":          "Bad Pack",
+		"files": []interface{}{
+			map[string]interface{}{
+				"path":      "../../etc/evil.jar",
+				"hashes":    map[string]string{"sha1": sha1Hex("evil")},
+				"downloads": []string{"https://cdn.modrinth.com/evil.jar"},
+			},
+		},
+		"dependencies": map[string]string{"minecraft": "1.20.1"},
+	}
+	archive := buildArchive(t, map[string]string{modrinthIndex: mustJSON(t, index)})
+	if _, err := Read(context.Background(), archive, nil); err == nil {
+		t.Error("Read() accepted a path outside of the server directory")
+	}
+}
+
+func TestReadNotAModpack(t *testing.T) {
+	archive := buildArchive(t, map[string]string{"level.dat": "world"})
+	if _, err := Read(context.Background(), archive, nil); err == nil {
+		t.Error("Read() accepted an archive without a manifest")
+	}
+}
+
+func TestWriteModsFromMirror(t *testing.T) {
+	m := newMirror(t, map[string]string{"/data/jei/jei.jar": jeiJar})
+	pack, err := Read(context.Background(), modrinthPack(t, map[string]string{"minecraft": "1.20.1", "forge": "47.2.0"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	importer := &Importer{Downloader: &Downloader{Mirror: m.URL}, Log: logr.Discard()}
+	var buffer bytes.Buffer
+	if err := importer.WriteMods(context.Background(), pack, &buffer); err != nil {
+		t.Fatalf("WriteMods() error = %v", err)
+	}
+
+	// the first download URL is missing from the mirror, so the second one is used
+	if want := []string{"/data/missing/jei.jar", "/data/jei/jei.jar"}; !reflect.DeepEqual(m.requests, want) {
+		t.Errorf("requests = %v, want %v", m.requests, want)
+	}
+
+	files := readTar(t, buffer.Bytes())
+	dir := "modpacks/family-pack-1-2-0/"
+	want := map[string]string{
+		dir + "jei.jar":            jeiJar,
+		dir + "jei.jar.sha256":     fmt.Sprintf("%s  jei.jar\n", sha256Hex([]byte(jeiJar))),
+		dir + "lithium.jar":        lithiumJar,
+		dir + "lithium.jar.sha256": fmt.Sprintf("%s  lithium.jar\n", sha256Hex([]byte(lithiumJar))),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("tar = %v, want %v", files, want)
+	}
+}
+
+func TestDownloadChecksMismatch(t *testing.T) {
+	m := newMirror(t, map[string]string{"/jei.jar": "tampered"})
+	downloader := &Downloader{Mirror: m.URL}
+	tests := []struct {
+		name   string
+		hashes map[string]string
+	}{
+		{"sha512", map[string]string{"sha512": sha512Hex(jeiJar), "sha1": sha1Hex("tampered")}},
+		{"sha1", map[string]string{"sha1": sha1Hex(jeiJar)}},
+		{"none", map[string]string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mod := Mod{Name: "jei.jar", URLs: []string{"https://cdn.modrinth.com/jei.jar"}, Hashes: test.hashes}
+			file, _, err := downloader.Download(context.Background(), mod)
+			if err == nil {
+				file.Close()
+				t.Error("Download() accepted a mod that doesn't match its checksum")
+			}
+		})
+	}
+}
+
+func TestReadCurseForge(t *testing.T) {
+	m := newMirror(t, map[string]string{
+		"/v1/mods/100/files/200": mustJSON(t, map[string]interface{}{"data": map[string]interface{}{
+			"fileName":    "jei.jar",
+			"fileLength":  len(jeiJar),
+			"downloadUrl": "https://edge.forgecdn.net/files/200/jei.jar",
+			"hashes":      []interface{}{map[string]interface{}{"value": sha1Hex(jeiJar), "algo": 1}},
+		}}),
+		"/v1/mods/101/files/201": mustJSON(t, map[string]interface{}{"data": map[string]interface{}{
+			"fileName":    "shaders.zip",
+			"downloadUrl": "https://edge.forgecdn.net/files/201/shaders.zip",
+		}}),
+		"/v1/mods/102/files/202": mustJSON(t, map[string]interface{}{"data": map[string]interface{}{
+			"fileName":    "restricted.jar",
+			"downloadUrl": nil,
+		}}),
+		"/files/200/jei.jar": jeiJar,
+	})
+
+	manifest := func(files ...[2]int) string {
+		var entries []interface{}
+		for _, file := range files {
+			entries = append(entries, map[string]interface{}{"projectID": file[0], "fileID": file[1], "required": true})
+		}
+		entries = append(entries, map[string]interface{}{"projectID": 999, "fileID": 999, "required": false})
+		return mustJSON(t, map[string]interface{}{
+			"manifestType": "minecraftModpack",
+			"name":         "Curse Pack",
+			"version":      "3.0",
+			"minecraft": map[string]interface{}{
+				"version": "1.20.1",
+				"modLoaders": []interface{}{
+					map[string]interface{}{"id": "forge-47.2.0", "primary": true},
+				},
+			},
+			"files":     entries,
+			"overrides": "overrides",
+		})
+	}
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		files   [][2]int
+		mods    []string
+		skipped []string
+		wantErr bool
+	}{
+		{"mods", "test-key", [][2]int{{100, 200}, {101, 201}}, []string{"jei.jar"},
+			[]string{"shaders.zip", "project 999 file 999 (optional)"}, false},
+		{"restricted", "test-key", [][2]int{{100, 200}, {102, 202}}, nil, nil, true},
+		{"bad key", "wrong", [][2]int{{100, 200}}, nil, nil, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			archive := buildArchive(t, map[string]string{
+				curseForgeManifest:          manifest(test.files...),
+				"overrides/config/jei.toml": "client = false",
+			})
+			curseForge := &CurseForge{URL: m.URL, APIKey: test.apiKey}
+			pack, err := Read(context.Background(), archive, curseForge)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if pack.ServerVersion != "forge-1.20.1-47.2.0" {
+				t.Errorf("ServerVersion = %q", pack.ServerVersion)
+			}
+			var mods []string
+			for _, mod := range pack.Mods {
+				mods = append(mods, mod.Name)
+			}
+			if !reflect.DeepEqual(mods, test.mods) {
+				t.Errorf("Mods = %v, want %v", mods, test.mods)
+			}
+			if !reflect.DeepEqual(pack.Skipped, test.skipped) {
+				t.Errorf("Skipped = %v, want %v", pack.Skipped, test.skipped)
+			}
+
+			importer := &Importer{Downloader: &Downloader{Mirror: m.URL}, Log: logr.Discard()}
+			var buffer bytes.Buffer
+			if err := importer.WriteMods(context.Background(), pack, &buffer); err != nil {
+				t.Fatalf("WriteMods() error = %v", err)
+			}
+			if files := readTar(t, buffer.Bytes()); files["modpacks/curse-pack-3-0/jei.jar"] != jeiJar {
+				t.Errorf("tar = %v, missing jei.jar", files)
+			}
+		})
+	}
+}
+
+func TestBuildServer(t *testing.T) {
+	pack, err := Read(context.Background(), modrinthPack(t, map[string]string{"minecraft": "1.20.1", "forge": "47.2.0"}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, configMap, err := BuildServer(pack, Options{Namespace: "games", MemoryMB: 6144})
+	if err != nil {
+		t.Fatalf("BuildServer() error = %v", err)
+	}
+	if server.Name != "family-pack-1-2-0" || server.Namespace != "games" {
+		t.Errorf("server is %s/%s", server.Namespace, server.Name)
+	}
+	if server.Spec.Enabled {
+		t.Error("server is enabled")
+	}
+	if server.Spec.ServerVersion != "forge-1.20.1-47.2.0" || server.Spec.MaxMemory != 6144 || server.Spec.InitMemory != 6144 {
+		t.Errorf("spec = %+v", server.Spec)
+	}
+	wantJars := []string{"modpacks/family-pack-1-2-0/jei.jar", "modpacks/family-pack-1-2-0/lithium.jar"}
+	if !reflect.DeepEqual(server.Spec.ModJars, wantJars) {
+		t.Errorf("ModJars = %v, want %v", server.Spec.ModJars, wantJars)
+	}
+
+	if configMap.Name != "family-pack-1-2-0-modpack" {
+		t.Errorf("configMap name = %s", configMap.Name)
+	}
+	files := make(map[string]string)
+	for _, file := range server.Spec.Files {
+		if file.ConfigMapKeyRef == nil || file.ConfigMapKeyRef.Name != configMap.Name {
+			t.Fatalf("file %s doesn't come from the configMap", file.Path)
+		}
+		files[file.Path] = string(configMap.BinaryData[file.ConfigMapKeyRef.Key])
+	}
+	want := map[string]string{"config/jei.toml": "client = false", "config/common.toml": "common = 1"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("files = %v, want %v", files, want)
+	}
+
+	pack.Overrides = append(pack.Overrides, Override{Path: "config/huge.json", Content: make([]byte, maxOverridesSize)})
+	if _, _, err := BuildServer(pack, Options{Name: "huge"}); err == nil {
+		t.Error("BuildServer() accepted overrides that don't fit a ConfigMap")
+	}
+}
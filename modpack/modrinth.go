@@ -0,0 +1,99 @@
+package modpack
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"path"
+	"strings"
+)
+
+// modrinthIndex is the file that describes a Modrinth modpack
+const modrinthIndex = "modrinth.index.json"
+
+// modrinthLoaders are the loader dependencies of a Modrinth modpack
+var modrinthLoaders = []string{"forge", "neoforge", "fabric-loader", "quilt-loader"}
+
+// modrinthManifest is the modrinth.index.json of a .mrpack, see https://docs.modrinth.com/docs/modpacks/format_definition/
+type modrinthManifest struct {
+	FormatVersion int    `json:"formatVersion"`
+	Game          string `json:"game"`
+	VersionID     string `json:"versionId"`
+	Name          string `json:"name"`
+	Files         []struct {
+		Path   string            `json:"path"`
+		Hashes map[string]string `json:"hashes"`
+		Env    *struct {
+			Server string `json:"server"`
+		} `json:"env"`
+		Downloads []string `json:"downloads"`
+		FileSize  int64    `json:"fileSize"`
+	} `json:"files"`
+	Dependencies map[string]string `json:"dependencies"`
+}
+
+// readModrinth reads a Modrinth .mrpack
+func readModrinth(archive *zip.Reader) (*Pack, error) {
+	content, err := readFile(archive, modrinthIndex)
+	if err != nil {
+		return nil, err
+	}
+	var manifest modrinthManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "parsing %s", modrinthIndex)
+	}
+	if manifest.FormatVersion != 1 {
+		return nil, errors.Errorf("unsupported Modrinth format version %d", manifest.FormatVersion)
+	}
+	if manifest.Game != "minecraft" {
+		return nil, errors.Errorf("modpack is for %q, not minecraft", manifest.Game)
+	}
+
+	pack := &Pack{Name: manifest.Name, Version: manifest.VersionID}
+
+	var loader, loaderVersion string
+	for _, name := range modrinthLoaders {
+		if version, ok := manifest.Dependencies[name]; ok {
+			if loader != "" {
+				return nil, errors.Errorf("modpack needs both %s and %s", loader, name)
+			}
+			loader, loaderVersion = name, version
+		}
+	}
+	pack.ServerVersion, err = serverVersion(manifest.Dependencies["minecraft"], loader, loaderVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range manifest.Files {
+		name, err := safePath(file.Path)
+		if err != nil {
+			return nil, err
+		}
+		if file.Env != nil && file.Env.Server == "unsupported" {
+			pack.Skipped = append(pack.Skipped, name)
+			continue
+		}
+		// only mods can be installed from the mod jars PVC, the rest (like resource packs) is for the client
+		if path.Dir(name) != "mods" || !strings.HasSuffix(name, ".jar") {
+			pack.Skipped = append(pack.Skipped, name)
+			continue
+		}
+		if len(file.Downloads) == 0 {
+			return nil, errors.Errorf("%s has no downloads", name)
+		}
+		pack.Mods = append(pack.Mods, Mod{
+			Name:   path.Base(name),
+			URLs:   file.Downloads,
+			Hashes: file.Hashes,
+			Size:   file.FileSize,
+		})
+	}
+
+	// server-overrides go over the overrides, client-overrides are for the client only
+	pack.Overrides, err = readOverrides(archive, "overrides", "server-overrides")
+	if err != nil {
+		return nil, err
+	}
+	return pack, nil
+}
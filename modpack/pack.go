@@ -0,0 +1,168 @@
+package modpack
+
+import (
+	"archive/zip"
+	"context"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pack is a modpack, read from a Modrinth .mrpack or a CurseForge zip
+type Pack struct {
+	// Name is the name of the modpack
+	Name string
+
+	// Version is the version of the modpack
+	Version string
+
+	// ServerVersion is the server version the modpack runs on (e.g.: forge-1.20.1-47.2.0, fabric-1.20.1-0.15.7)
+	ServerVersion string
+
+	// Mods are the mod files the server needs, to be downloaded
+	Mods []Mod
+
+	// Overrides are the files the modpack puts in the server's directory, like configs
+	Overrides []Override
+
+	// Skipped are the files of the modpack that aren't installed on the server, like client-only mods
+	Skipped []string
+}
+
+// Mod is a mod file of a modpack
+type Mod struct {
+	// Name is the file name of the mod
+	Name string
+
+	// URLs are the places to download the mod from, tried in order
+	URLs []string
+
+	// Hashes are the checksums of the mod by algorithm (sha512, sha1 or md5), at least one is needed
+	Hashes map[string]string
+
+	// Size is the size of the mod in bytes, when it's known
+	Size int64
+}
+
+// Override is a file from the overrides of the modpack
+type Override struct {
+	// Path is the path of the file, relative to the server's directory
+	Path string
+
+	// Content is the content of the file
+	Content []byte
+}
+
+// loaderNames maps the loader names of the modpack formats to the flavor of the server version
+var loaderNames = map[string]string{
+	"forge":         "forge",
+	"neoforge":      "neoforge",
+	"fabric":        "fabric",
+	"fabric-loader": "fabric",
+	"quilt":         "quilt",
+	"quilt-loader":  "quilt",
+}
+
+// serverVersion builds the server version from the Minecraft version and the loader
+func serverVersion(minecraft, loader, loaderVersion string) (string, error) {
+	if minecraft == "" {
+		return "", errors.New("modpack has no Minecraft version")
+	}
+	if loader == "" {
+		return "vanilla-" + minecraft, nil
+	}
+	flavor, ok := loaderNames[loader]
+	if !ok {
+		return "", errors.Errorf("unsupported mod loader %q", loader)
+	}
+	return strings.Join([]string{flavor, minecraft, loaderVersion}, "-"), nil
+}
+
+// Read reads the modpack from the archive. CurseForge modpacks only list project and file IDs, so their mods are
+// looked up with curseForge, which may be nil for Modrinth modpacks.
+func Read(ctx context.Context, archive *zip.Reader, curseForge *CurseForge) (*Pack, error) {
+	for _, file := range archive.File {
+		switch file.Name {
+		case modrinthIndex:
+			return readModrinth(archive)
+		case curseForgeManifest:
+			if curseForge == nil {
+				return nil, errors.New("CurseForge modpacks need the CurseForge API")
+			}
+			return readCurseForge(ctx, archive, curseForge)
+		}
+	}
+	return nil, errors.Errorf("archive has no %s or %s, it's not a modpack", modrinthIndex, curseForgeManifest)
+}
+
+// readFile returns the content of the named file in the archive
+func readFile(archive *zip.Reader, name string) ([]byte, error) {
+	file, err := archive.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening %s", name)
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+// safePath cleans a path from the modpack, refusing paths that point outside of the server's directory
+func safePath(name string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(name, `\`, "/"))
+	if path.IsAbs(cleaned) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.Errorf("invalid path %q", name)
+	}
+	return cleaned, nil
+}
+
+// readOverrides returns the files under the given directories of the archive. A file in a later directory
+// replaces the one in an earlier directory.
+func readOverrides(archive *zip.Reader, dirs ...string) ([]Override, error) {
+	var overrides []Override
+	index := make(map[string]int)
+	for _, dir := range dirs {
+		prefix := strings.TrimSuffix(dir, "/") + "/"
+		for _, file := range archive.File {
+			if file.FileInfo().IsDir() || !strings.HasPrefix(file.Name, prefix) {
+				continue
+			}
+			name, err := safePath(strings.TrimPrefix(file.Name, prefix))
+			if err != nil {
+				return nil, err
+			}
+			reader, err := file.Open()
+			if err != nil {
+				return nil, errors.Wrapf(err, "opening %s", file.Name)
+			}
+			content, err := ioutil.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "reading %s", file.Name)
+			}
+
+			override := Override{Path: name, Content: content}
+			if existing, ok := index[name]; ok {
+				overrides[existing] = override
+				continue
+			}
+			index[name] = len(overrides)
+			overrides = append(overrides, override)
+		}
+	}
+	return overrides, nil
+}
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Slug returns the name and version of the modpack in a form that fits Kubernetes names and paths
+func (p *Pack) Slug() string {
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(p.Name+"-"+p.Version), "-"), "-")
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "-")
+	}
+	if slug == "" {
+		slug = "modpack"
+	}
+	return slug
+}
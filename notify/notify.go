@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+)
+
+// DefaultRateLimitPerMinute is the number of messages a channel sends per minute, when it doesn't set its own limit
+const DefaultRateLimitPerMinute = 10
+
+// ErrRateLimited is returned for the messages a channel drops, because it sent too many already
+var ErrRateLimited = errors.New("rate limit of the channel reached")
+
+// DefaultTemplates are the messages for the events, for channels that don't replace them
+var DefaultTemplates = map[v1.NotificationEvent]string{
+	v1.NotificationPlayerJoined:  "{{ .Player }} joined {{ .Server }}",
+	v1.NotificationPlayerLeft:    "{{ .Player }} left {{ .Server }}",
+	v1.NotificationServerOnline:  "{{ .Server }} is online",
+	v1.NotificationServerOffline: "{{ .Server }} is offline",
+	v1.NotificationIdleShutdown:  "{{ .Server }} is stopped, as nobody played on it{{ with .Message }}: {{ . }}{{ end }}",
+	v1.NotificationCrash:         "{{ .Server }} crashed{{ with .Message }}: {{ . }}{{ end }}",
+	v1.NotificationBackup:        "Backup of {{ .Server }}{{ with .Message }}: {{ . }}{{ end }}",
+}
+
+// Event is something that happened to a Server
+type Event struct {
+	Type      v1.NotificationEvent `json:"type"`
+	Namespace string               `json:"namespace"`
+	Server    string               `json:"server"`
+	Player    string               `json:"player,omitempty"`
+	Message   string               `json:"message,omitempty"`
+}
+
+// Channel is a NotificationChannel, with its secrets resolved
+type Channel struct {
+	// Name identifies the channel for the rate limiting, e.g. namespace/name
+	Name               string
+	Type               v1.NotificationChannelType
+	URL                string
+	Room               string
+	Token              string
+	Templates          map[v1.NotificationEvent]string
+	RateLimitPerMinute int32
+}
+
+// Notifier sends messages to channels
+type Notifier struct {
+	Client *http.Client
+
+	// now is the clock of the rate limiting
+	now func() time.Time
+
+	mutex    sync.Mutex
+	limiters map[string]*limiter
+
+	// transactions numbers the Matrix messages, which the homeserver uses to drop retried messages
+	transactions uint64
+}
+
+// New returns a Notifier
+func New() *Notifier {
+	return &Notifier{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		now:      time.Now,
+		limiters: make(map[string]*limiter),
+	}
+}
+
+// Render returns the message of the channel for the event
+func Render(channel Channel, event Event) (string, error) {
+	text, ok := channel.Templates[event.Type]
+	if !ok {
+		text, ok = DefaultTemplates[event.Type]
+	}
+	if !ok {
+		return "", errors.Errorf("no template for event %s", event.Type)
+	}
+
+	tmpl, err := template.New(string(event.Type)).Parse(text)
+	if err != nil {
+		return "", errors.Wrapf(err, "parsing template for event %s", event.Type)
+	}
+	var message strings.Builder
+	if err := tmpl.Execute(&message, event); err != nil {
+		return "", errors.Wrapf(err, "rendering template for event %s", event.Type)
+	}
+	return message.String(), nil
+}
+
+// Send sends the event to the channel, unless the channel reached its rate limit
+func (n *Notifier) Send(ctx context.Context, channel Channel, event Event) error {
+	if !n.allow(channel) {
+		return ErrRateLimited
+	}
+
+	message, err := Render(channel, event)
+	if err != nil {
+		return err
+	}
+
+	request, err := n.request(ctx, channel, event, message)
+	if err != nil {
+		return errors.Wrap(err, "creating request")
+	}
+	response, err := n.Client.Do(request)
+	if err != nil {
+		return errors.Wrap(err, "sending message")
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return errors.Errorf("sending message: got %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// request returns the request that sends the message to the channel
+func (n *Notifier) request(ctx context.Context, channel Channel, event Event, message string) (*http.Request, error) {
+	if channel.URL == "" {
+		return nil, errors.New("channel has no URL")
+	}
+
+	method := http.MethodPost
+	target := channel.URL
+	var payload interface{}
+	switch channel.Type {
+	case v1.NotificationChannelDiscord:
+		payload = map[string]string{"content": message}
+	case v1.NotificationChannelSlack:
+		payload = map[string]string{"text": message}
+	case v1.NotificationChannelMatrix:
+		if channel.Room == "" {
+			return nil, errors.New("matrix channel has no room")
+		}
+		method = http.MethodPut
+		target = fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/minecraft-operator-%d-%d",
+			strings.TrimSuffix(channel.URL, "/"), url.PathEscape(channel.Room), n.now().UnixNano(),
+			atomic.AddUint64(&n.transactions, 1))
+		payload = map[string]string{"msgtype": "m.text", "body": message}
+	case v1.NotificationChannelWebhook:
+		payload = struct {
+			Event
+			Text string `json:"text"`
+		}{event, message}
+	default:
+		return nil, errors.Errorf("unknown channel type %q", channel.Type)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if channel.Type == v1.NotificationChannelMatrix {
+		request.Header.Set("Authorization", "Bearer "+channel.Token)
+	}
+	return request, nil
+}
+
+// limiter is a token bucket, which holds a minute of messages and refills at the rate of the channel
+type limiter struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket of the channel, if there is one
+func (n *Notifier) allow(channel Channel) bool {
+	perMinute := float64(channel.RateLimitPerMinute)
+	if perMinute <= 0 {
+		perMinute = DefaultRateLimitPerMinute
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	now := n.now()
+	bucket, ok := n.limiters[channel.Name]
+	if !ok {
+		bucket = &limiter{tokens: perMinute, last: now}
+		n.limiters[channel.Name] = bucket
+	}
+
+	bucket.tokens += now.Sub(bucket.last).Minutes() * perMinute
+	if bucket.tokens > perMinute {
+		bucket.tokens = perMinute
+	}
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+)
+
+// received is a request the stub got
+type received struct {
+	method        string
+	path          string
+	authorization string
+	body          map[string]interface{}
+}
+
+// stub starts an HTTP server that records the requests, and answers them with the status
+func stub(t *testing.T, status int) (*httptest.Server, *[]received) {
+	t.Helper()
+	var requests []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(content, &body); err != nil {
+			t.Errorf("body isn't JSON: %s", content)
+		}
+		requests = append(requests, received{
+			method:        r.Method,
+			path:          r.URL.Path,
+			authorization: r.Header.Get("Authorization"),
+			body:          body,
+		})
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error":"stub"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var joined = Event{Type: v1.NotificationPlayerJoined, Namespace: "default", Server: "survival", Player: "Steve"}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel Channel
+		method  string
+		path    string
+		auth    string
+		body    map[string]interface{}
+	}{
+		{
+			name:    "discord",
+			channel: Channel{Type: v1.NotificationChannelDiscord, URL: "/api/webhooks/1/token"},
+			method:  http.MethodPost,
+			path:    "/api/webhooks/1/token",
+			body:    map[string]interface{}{"content": "Steve joined survival"},
+		},
+		{
+			name:    "slack",
+			channel: Channel{Type: v1.NotificationChannelSlack, URL: "/services/T/B/X"},
+			method:  http.MethodPost,
+			path:    "/services/T/B/X",
+			body:    map[string]interface{}{"text": "Steve joined survival"},
+		},
+		{
+			name:    "matrix",
+			channel: Channel{Type: v1.NotificationChannelMatrix, URL: "/", Room: "!room:example.org", Token: "secret"},
+			method:  http.MethodPut,
+			path:    "/_matrix/client/v3/rooms/!room:example.org/send/m.room.message/",
+			auth:    "Bearer secret",
+			body:    map[string]interface{}{"msgtype": "m.text", "body": "Steve joined survival"},
+		},
+		{
+			name:    "webhook",
+			channel: Channel{Type: v1.NotificationChannelWebhook, URL: "/hook"},
+			method:  http.MethodPost,
+			path:    "/hook",
+			body: map[string]interface{}{
+				"type":      "PlayerJoined",
+				"namespace": "default",
+				"server":    "survival",
+				"player":    "Steve",
+				"text":      "Steve joined survival",
+			},
+		},
+		{
+			name: "template",
+			channel: Channel{Type: v1.NotificationChannelDiscord, URL: "/hook", Templates: map[v1.NotificationEvent]string{
+				v1.NotificationPlayerJoined: "{{ .Player }} is online on {{ .Namespace }}/{{ .Server }}",
+			}},
+			method: http.MethodPost,
+			path:   "/hook",
+			body:   map[string]interface{}{"content": "Steve is online on default/survival"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, requests := stub(t, http.StatusOK)
+			test.channel.Name = test.name
+			test.channel.URL = strings.TrimSuffix(server.URL+test.channel.URL, "/")
+
+			if err := New().Send(context.Background(), test.channel, joined); err != nil {
+				t.Fatal(err)
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("got %d requests, want 1", len(*requests))
+			}
+			got := (*requests)[0]
+			if got.method != test.method {
+				t.Errorf("method is %s, want %s", got.method, test.method)
+			}
+			if !strings.HasPrefix(got.path, test.path) {
+				t.Errorf("path is %s, want %s...", got.path, test.path)
+			}
+			if got.authorization != test.auth {
+				t.Errorf("authorization is %q, want %q", got.authorization, test.auth)
+			}
+			if !reflect.DeepEqual(got.body, test.body) {
+				t.Errorf("body is %v, want %v", got.body, test.body)
+			}
+		})
+	}
+}
+
+func TestSendError(t *testing.T) {
+	server, _ := stub(t, http.StatusNotFound)
+	channel := Channel{Name: "gone", Type: v1.NotificationChannelDiscord, URL: server.URL}
+	err := New().Send(context.Background(), channel, joined)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("got %v, want a 404 error", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	server, requests := stub(t, http.StatusOK)
+	now := time.Unix(1600000000, 0)
+	notifier := New()
+	notifier.now = func() time.Time { return now }
+	channel := Channel{Name: "default/chat", Type: v1.NotificationChannelSlack, URL: server.URL, RateLimitPerMinute: 2}
+
+	send := func() error { return notifier.Send(context.Background(), channel, joined) }
+	for i := 0; i < 2; i++ {
+		if err := send(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := send(); err != ErrRateLimited {
+		t.Fatalf("third message got %v, want ErrRateLimited", err)
+	}
+
+	// other channels have their own limit
+	other := channel
+	other.Name = "default/other"
+	if err := notifier.Send(context.Background(), other, joined); err != nil {
+		t.Fatalf("other channel got %v", err)
+	}
+
+	// half a minute refills one message
+	now = now.Add(30 * time.Second)
+	if err := send(); err != nil {
+		t.Fatalf("after 30s got %v", err)
+	}
+	if err := send(); err != ErrRateLimited {
+		t.Fatalf("second message after 30s got %v, want ErrRateLimited", err)
+	}
+
+	if len(*requests) != 4 {
+		t.Errorf("stub got %d requests, want 4", len(*requests))
+	}
+}
+
+func TestRenderDefaults(t *testing.T) {
+	tests := map[v1.NotificationEvent]string{
+		v1.NotificationPlayerJoined:  "Steve joined survival",
+		v1.NotificationPlayerLeft:    "Steve left survival",
+		v1.NotificationServerOnline:  "survival is online",
+		v1.NotificationServerOffline: "survival is offline",
+		v1.NotificationIdleShutdown:  "survival is stopped, as nobody played on it",
+		v1.NotificationCrash:         "survival crashed",
+		v1.NotificationBackup:        "Backup of survival",
+	}
+	for event, want := range tests {
+		got, err := Render(Channel{}, Event{Type: event, Server: "survival", Player: "Steve"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", event, got, want)
+		}
+	}
+
+	got, err := Render(Channel{}, Event{Type: v1.NotificationCrash, Server: "survival", Message: "exit code 1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "survival crashed: exit code 1" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,93 @@
+package rcon
+
+import (
+	"context"
+	"fmt"
+	mcnet "github.com/Tnze/go-mc/net"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"net"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+)
+
+// Port is the port the Server's RCON listener binds to
+const Port = 25575
+
+// PasswordKey is the key in the RCON Secret that holds the password
+const PasswordKey = "password"
+
+// SecretName returns the name of the Secret that holds the RCON password for the Server
+func SecretName(server *v1.Server) string {
+	return server.Name + "-rcon"
+}
+
+// Address returns the RCON address on the running Pod of the Server.
+// The Service doesn't expose RCON, so it can't be reached through the Server's name.
+func Address(ctx context.Context, kClient client.Client, server *v1.Server) (string, error) {
+	var podList corev1.PodList
+	err := kClient.List(ctx, &podList, client.InNamespace(server.Namespace),
+		client.MatchingLabels{"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name)})
+	if err != nil {
+		return "", errors.Wrap(err, "listing pods")
+	}
+
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" {
+			return net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(Port)), nil
+		}
+	}
+	return "", errors.New("server has no running pod")
+}
+
+// Password fetches the RCON password for the Server from its Secret
+func Password(ctx context.Context, kClient client.Client, server *v1.Server) (string, error) {
+	var secret corev1.Secret
+	err := kClient.Get(ctx, client.ObjectKey{Name: SecretName(server), Namespace: server.Namespace}, &secret)
+	if err != nil {
+		return "", errors.Wrap(err, "fetching RCON secret")
+	}
+
+	password, ok := secret.Data[PasswordKey]
+	if !ok || len(password) == 0 {
+		return "", errors.New("RCON secret has no password")
+	}
+	return string(password), nil
+}
+
+// Command runs the commands on the Server, in order, and returns the responses
+func Command(ctx context.Context, kClient client.Client, server *v1.Server, commands ...string) ([]string, error) {
+	password, err := Password(ctx, kClient, server)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := Address(ctx, kClient, server)
+	if err != nil {
+		return nil, err
+	}
+	return CommandAt(addr, password, commands...)
+}
+
+// CommandAt runs the commands on the RCON listener at addr, in order, and returns the responses
+func CommandAt(addr, password string, commands ...string) ([]string, error) {
+	conn, err := mcnet.DialRCON(addr, password)
+	if err != nil {
+		return nil, errors.Wrap(err, "connecting to RCON")
+	}
+	defer conn.Close()
+
+	var responses []string
+	for _, command := range commands {
+		if err := conn.Cmd(command); err != nil {
+			return responses, errors.Wrapf(err, "sending command %q", command)
+		}
+		response, err := conn.Resp()
+		if err != nil {
+			return responses, errors.Wrapf(err, "reading response to %q", command)
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
@@ -0,0 +1,68 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// JarsHelperPodName returns the name of the helper Pod for the jars PVC
+func JarsHelperPodName(claim *corev1.PersistentVolumeClaim) string {
+	return claim.Name + "-upload"
+}
+
+// UploadJars extracts the tar stream onto the jars PVC, through a helper Pod that mounts it on /jars.
+// Existing files with the same name are replaced.
+func (t *Transfer) UploadJars(ctx context.Context, claim *corev1.PersistentVolumeClaim, r io.Reader) error {
+	t.Log.V(loglevels.Verbose).Info("uploading jars", "pvc", claim.Name)
+	if t.HelperImage == "" {
+		return errors.New("no helper image configured")
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      JarsHelperPodName(claim),
+			Namespace: claim.Namespace,
+			Labels: map[string]string{
+				"app": fmt.Sprintf("minecraft-operator-jars-transfer-%s", claim.Name),
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Volumes: []corev1.Volume{
+				{
+					Name: "jars",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: claim.Name,
+						},
+					},
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:    "transfer",
+					Image:   t.HelperImage,
+					Command: []string{"sleep", "3600"},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "jars",
+							MountPath: "/jars",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := t.startPod(ctx, pod); err != nil {
+		return err
+	}
+	defer t.deletePod(context.Background(), pod)
+
+	return errors.Wrap(t.Exec(ctx, pod, "transfer", []string{"tar", "-C", "/jars", "-xf", "-"}, r, nil), "extracting jars")
+}
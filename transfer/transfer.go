@@ -0,0 +1,194 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-logr/logr"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/hsmade/minecraft-operator/rcon"
+	"github.com/pkg/errors"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// Transfer moves world data in and out of the volumes of a Server.
+// When the Server is running we work in its Pod, otherwise a short-lived helper Pod is started that mounts the world.
+type Transfer struct {
+	Client client.Client
+	Config *rest.Config
+	Log    logr.Logger
+
+	// HelperImage is the image used for the helper Pod, it needs a shell and tar
+	HelperImage string
+	// RCON runs commands on the Server, defaults to rcon.Command which needs to run in the cluster
+	RCON func(ctx context.Context, server *v1.Server, commands ...string) ([]string, error)
+}
+
+// HelperPodName returns the name of the helper Pod for the named world of the Server
+func HelperPodName(server *v1.Server, world string) string {
+	return server.Name + "-" + world + "-transfer"
+}
+
+// runRCON runs the commands on the Server with the RCON function of the Transfer
+func (t *Transfer) runRCON(ctx context.Context, server *v1.Server, commands ...string) ([]string, error) {
+	if t.RCON != nil {
+		return t.RCON(ctx, server, commands...)
+	}
+	return rcon.Command(ctx, t.Client, server, commands...)
+}
+
+// ServerPod returns the running Pod for the Server, or nil when it isn't running
+func (t *Transfer) ServerPod(ctx context.Context, server *v1.Server) (*corev1.Pod, error) {
+	var podList corev1.PodList
+	err := t.Client.List(ctx, &podList, client.InNamespace(server.Namespace),
+		client.MatchingLabels{"app": fmt.Sprintf("minecraft-operator-server-%s", server.Name)})
+	if err != nil {
+		return nil, errors.Wrap(err, "listing pods")
+	}
+
+	for index, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			return &podList.Items[index], nil
+		}
+	}
+	return nil, nil
+}
+
+// StartHelperPod starts a Pod that mounts the named world of the Server on /world, and waits for it to be running
+func (t *Transfer) StartHelperPod(ctx context.Context, server *v1.Server, world string) (*corev1.Pod, error) {
+	if t.HelperImage == "" {
+		return nil, errors.New("no helper image configured")
+	}
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      HelperPodName(server, world),
+			Namespace: server.Namespace,
+			Labels: map[string]string{
+				"app": fmt.Sprintf("minecraft-operator-world-transfer-%s", server.Name),
+			},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			Volumes: []corev1.Volume{
+				{
+					Name: "world",
+					VolumeSource: corev1.VolumeSource{
+						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: server.Name,
+						},
+					},
+				},
+			},
+			Containers: []corev1.Container{
+				{
+					Name:    "transfer",
+					Image:   t.HelperImage,
+					Command: []string{"sleep", "3600"},
+					VolumeMounts: []corev1.VolumeMount{
+						{
+							Name:      "world",
+							MountPath: "/world",
+							SubPath:   helpers.WorldPath(server, world),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := t.startPod(ctx, pod); err != nil {
+		return nil, err
+	}
+	return pod, nil
+}
+
+// startPod creates the helper Pod and waits for it to be running. It's removed again when it doesn't start.
+func (t *Transfer) startPod(ctx context.Context, pod *corev1.Pod) error {
+	t.Log.V(loglevels.Verbose).Info("creating helper pod", "pod", pod.Name)
+	err := t.Client.Create(ctx, pod)
+	if err != nil {
+		return errors.Wrap(err, "creating helper pod")
+	}
+
+	err = wait.PollImmediate(time.Second, 2*time.Minute, func() (bool, error) {
+		if err := t.Client.Get(ctx, client.ObjectKeyFromObject(pod), pod); err != nil {
+			return false, err
+		}
+		switch pod.Status.Phase {
+		case corev1.PodRunning:
+			return true, nil
+		case corev1.PodFailed, corev1.PodSucceeded:
+			return false, errors.Errorf("helper pod stopped with phase %s", pod.Status.Phase)
+		}
+		return false, nil
+	})
+	if err != nil {
+		t.deletePod(context.Background(), pod)
+		return errors.Wrap(err, "waiting for helper pod")
+	}
+	return nil
+}
+
+// deletePod removes a helper Pod
+func (t *Transfer) deletePod(ctx context.Context, pod *corev1.Pod) {
+	err := t.Client.Delete(ctx, pod, client.GracePeriodSeconds(0))
+	if err != nil && !apierrors.IsNotFound(err) {
+		// non-critical error
+		t.Log.Info("ERROR failed to delete helper pod", "pod", pod.Name, "error", err)
+	}
+}
+
+// StopHelperPod removes the helper Pod for the named world of the Server
+func (t *Transfer) StopHelperPod(ctx context.Context, server *v1.Server, world string) {
+	t.deletePod(ctx, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: HelperPodName(server, world), Namespace: server.Namespace}})
+}
+
+// Exec runs the command in the container of the Pod, streaming stdin and stdout
+func (t *Transfer) Exec(ctx context.Context, pod *corev1.Pod, container string, command []string, stdin io.Reader, stdout io.Writer) error {
+	clientSet, err := kubernetes.NewForConfig(t.Config)
+	if err != nil {
+		return errors.Wrap(err, "create client")
+	}
+
+	req := clientSet.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod.Name).
+		Namespace(pod.Namespace).
+		SubResource("exec")
+	req.VersionedParams(&corev1.PodExecOptions{
+		Container: container,
+		Command:   command,
+		Stdin:     stdin != nil,
+		Stdout:    stdout != nil,
+		Stderr:    true,
+	}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(t.Config, "POST", req.URL())
+	if err != nil {
+		return errors.Wrap(err, "creating executor")
+	}
+
+	var stderr bytes.Buffer
+	err = exec.Stream(remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "running %v: %s", command, stderr.String())
+	}
+	return nil
+}
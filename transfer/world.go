@@ -0,0 +1,265 @@
+package transfer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/loglevels"
+	"github.com/pkg/errors"
+	"io"
+	corev1 "k8s.io/api/core/v1"
+	"path"
+	"strings"
+)
+
+// ImportMode defines what to do with an existing world when importing
+type ImportMode string
+
+const (
+	// ImportReplace removes the existing world before importing
+	ImportReplace ImportMode = "replace"
+	// ImportSeed only imports when there is no world yet
+	ImportSeed ImportMode = "seed"
+)
+
+// ExportWorld writes a zip of the named world of the Server to w.
+// When the Server is running the world, it's flushed to disk first and saving is paused during the export.
+func (t *Transfer) ExportWorld(ctx context.Context, server *v1.Server, world string, w io.Writer) error {
+	t.Log.V(loglevels.Verbose).Info("exporting world", "server", server.Name, "world", world)
+
+	if _, err := helpers.WorldSpec(server, world); err != nil {
+		return err
+	}
+
+	var pod *corev1.Pod
+	var err error
+	if world == helpers.RunningWorldName(server) {
+		pod, err = t.ServerPod(ctx, server)
+		if err != nil {
+			return errors.Wrap(err, "looking up server pod")
+		}
+	}
+
+	container, dir := "minecraft", "/data/world"
+	if pod != nil {
+		t.Log.V(loglevels.Flow).Info("server is running, saving world before export")
+		if _, err := t.runRCON(ctx, server, "save-off", "save-all flush"); err != nil {
+			return errors.Wrap(err, "saving world")
+		}
+		defer func() {
+			if _, err := t.runRCON(context.Background(), server, "save-on"); err != nil {
+				t.Log.Info("ERROR failed to re-enable saving", "server", server.Name, "error", err)
+			}
+		}()
+	} else {
+		t.Log.V(loglevels.Flow).Info("world is not in use, starting helper pod")
+		pod, err = t.StartHelperPod(ctx, server, world)
+		if err != nil {
+			return err
+		}
+		defer t.StopHelperPod(context.Background(), server, world)
+		container, dir = "transfer", "/world"
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(t.Exec(ctx, pod, container, []string{"tar", "-C", dir, "-cf", "-", "."}, nil, writer))
+	}()
+	defer reader.Close()
+
+	return errors.Wrap(TarToZip(reader, w), "converting world to zip")
+}
+
+// ImportWorld puts the world from the zip archive on the Server's volume as the named world.
+// When it's the active world, the Server must be stopped.
+func (t *Transfer) ImportWorld(ctx context.Context, server *v1.Server, world string, archive *zip.Reader, mode ImportMode) error {
+	t.Log.V(loglevels.Verbose).Info("importing world", "server", server.Name, "world", world, "mode", mode)
+
+	if _, err := helpers.WorldSpec(server, world); err != nil {
+		return err
+	}
+
+	if mode != ImportReplace && mode != ImportSeed {
+		return errors.Errorf("unknown import mode %q", mode)
+	}
+
+	prefix, err := FindWorldRoot(archive)
+	if err != nil {
+		return err
+	}
+	t.Log.V(loglevels.Flow).Info("found world in archive", "prefix", prefix)
+
+	if world == helpers.RunningWorldName(server) {
+		if server.Spec.Enabled {
+			return errors.New("server must be disabled to import its active world")
+		}
+		pod, err := t.ServerPod(ctx, server)
+		if err != nil {
+			return errors.Wrap(err, "looking up server pod")
+		}
+		if pod != nil {
+			return errors.New("server is still running")
+		}
+	}
+
+	pod, err := t.StartHelperPod(ctx, server, world)
+	if err != nil {
+		return err
+	}
+	defer t.StopHelperPod(context.Background(), server, world)
+
+	switch mode {
+	case ImportSeed:
+		var listing bytes.Buffer
+		if err := t.Exec(ctx, pod, "transfer", []string{"ls", "-A", "/world"}, nil, &listing); err != nil {
+			return errors.Wrap(err, "checking for existing world")
+		}
+		if strings.TrimSpace(listing.String()) != "" {
+			return errors.New("server already has a world")
+		}
+	case ImportReplace:
+		t.Log.V(loglevels.Flow).Info("removing existing world")
+		err := t.Exec(ctx, pod, "transfer", []string{"sh", "-c", "rm -rf /world/* /world/.[!.]* /world/..?*"}, nil, nil)
+		if err != nil {
+			return errors.Wrap(err, "removing existing world")
+		}
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(ZipToTar(archive, prefix, writer))
+	}()
+	defer reader.Close()
+
+	return errors.Wrap(t.Exec(ctx, pod, "transfer", []string{"tar", "-C", "/world", "-xf", "-"}, reader, nil), "extracting world")
+}
+
+// FindWorldRoot returns the directory in the archive that holds level.dat.
+// Single player worlds are usually zipped with their folder, so we take the shallowest one.
+func FindWorldRoot(archive *zip.Reader) (string, error) {
+	root, found := "", false
+	for _, file := range archive.File {
+		name := path.Clean(file.Name)
+		if path.Base(name) != "level.dat" {
+			continue
+		}
+		dir := path.Dir(name)
+		if dir == "." {
+			dir = ""
+		}
+		if !found || strings.Count(dir, "/") < strings.Count(root, "/") {
+			root, found = dir, true
+		}
+	}
+	if !found {
+		return "", errors.New("archive has no level.dat")
+	}
+	return root, nil
+}
+
+// safePath makes sure the name stays within the world directory
+func safePath(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.Errorf("invalid path %q", name)
+	}
+	return cleaned, nil
+}
+
+// TarToZip converts a tar stream to a zip stream
+func TarToZip(r io.Reader, w io.Writer) error {
+	tarReader := tar.NewReader(r)
+	zipWriter := zip.NewWriter(w)
+
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "reading tar")
+		}
+
+		name, err := safePath(header.Name)
+		if err != nil {
+			return err
+		}
+		if name == "." {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			_, err = zipWriter.CreateHeader(&zip.FileHeader{Name: name + "/", Modified: header.ModTime})
+		case tar.TypeReg:
+			var entry io.Writer
+			entry, err = zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: header.ModTime})
+			if err == nil {
+				_, err = io.Copy(entry, tarReader)
+			}
+		default:
+			// links and devices have no place in a world
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "writing %s", name)
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+// ZipToTar converts the files under prefix in the zip archive to a tar stream, relative to prefix
+func ZipToTar(archive *zip.Reader, prefix string, w io.Writer) error {
+	tarWriter := tar.NewWriter(w)
+
+	for _, file := range archive.File {
+		name, err := safePath(file.Name)
+		if err != nil {
+			return err
+		}
+		if prefix != "" {
+			if !strings.HasPrefix(name, prefix+"/") {
+				continue
+			}
+			name = strings.TrimPrefix(name, prefix+"/")
+		}
+		if name == "." {
+			continue
+		}
+
+		if file.FileInfo().IsDir() {
+			err = tarWriter.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name + "/", Mode: 0o755, ModTime: file.Modified})
+			if err != nil {
+				return errors.Wrapf(err, "writing %s", name)
+			}
+			continue
+		}
+
+		err = tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0o644,
+			Size:     int64(file.UncompressedSize64),
+			ModTime:  file.Modified,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "writing %s", name)
+		}
+
+		reader, err := file.Open()
+		if err != nil {
+			return errors.Wrapf(err, "opening %s", name)
+		}
+		_, err = io.Copy(tarWriter, reader)
+		reader.Close()
+		if err != nil {
+			return errors.Wrapf(err, "copying %s", name)
+		}
+	}
+
+	return tarWriter.Close()
+}
@@ -0,0 +1,185 @@
+package transfer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+// tarFile is an entry in a test tar, a directory when its name ends with a slash
+type tarFile struct {
+	name    string
+	content string
+}
+
+// buildTar returns a tar stream with the files
+func buildTar(t *testing.T, files []tarFile) *bytes.Buffer {
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+	for _, file := range files {
+		header := &tar.Header{Typeflag: tar.TypeReg, Name: file.name, Mode: 0o644, Size: int64(len(file.content))}
+		if file.name[len(file.name)-1] == '/' {
+			header = &tar.Header{Typeflag: tar.TypeDir, Name: file.name, Mode: 0o755}
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tarWriter.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buffer
+}
+
+// buildZip returns a zip archive with the files
+func buildZip(t *testing.T, files []tarFile) *zip.Reader {
+	var buffer bytes.Buffer
+	zipWriter := zip.NewWriter(&buffer)
+	for _, file := range files {
+		entry, err := zipWriter.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := entry.Write([]byte(file.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+// readTar returns the entries of the tar stream
+func readTar(t *testing.T, r io.Reader) []tarFile {
+	var files []tarFile
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(tarReader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, tarFile{name: header.Name, content: string(content)})
+	}
+}
+
+func TestSafePath(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "level.dat", expected: "level.dat"},
+		{name: "./region/r.0.0.mca", expected: "region/r.0.0.mca"},
+		{name: "region/../level.dat", expected: "level.dat"},
+		{name: "region/", expected: "region"},
+		{name: "./", expected: "."},
+		{name: "..", wantErr: true},
+		{name: "../level.dat", wantErr: true},
+		{name: "region/../../level.dat", wantErr: true},
+		{name: "/etc/passwd", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cleaned, err := safePath(test.name)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("expected error %v, got %v", test.wantErr, err)
+			}
+			if cleaned != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, cleaned)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	world := []tarFile{
+		{name: "./"},
+		{name: "./level.dat", content: "level"},
+		{name: "./region/"},
+		{name: "./region/r.0.0.mca", content: "region"},
+	}
+
+	var zipped bytes.Buffer
+	if err := TarToZip(buildTar(t, world), &zipped); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(zipped.Bytes()), int64(zipped.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := FindWorldRoot(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != "" {
+		t.Errorf("expected the root of the archive, got %q", root)
+	}
+
+	var tarred bytes.Buffer
+	if err := ZipToTar(archive, root, &tarred); err != nil {
+		t.Fatal(err)
+	}
+	expected := []tarFile{
+		{name: "level.dat", content: "level"},
+		{name: "region/"},
+		{name: "region/r.0.0.mca", content: "region"},
+	}
+	if found := readTar(t, &tarred); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v, got %v", expected, found)
+	}
+}
+
+func TestZipToTarPrefix(t *testing.T) {
+	archive := buildZip(t, []tarFile{
+		{name: "MyWorld/level.dat", content: "level"},
+		{name: "MyWorld/region/r.0.0.mca", content: "region"},
+		{name: "readme.txt", content: "readme"},
+	})
+
+	var tarred bytes.Buffer
+	if err := ZipToTar(archive, "MyWorld", &tarred); err != nil {
+		t.Fatal(err)
+	}
+	expected := []tarFile{
+		{name: "level.dat", content: "level"},
+		{name: "region/r.0.0.mca", content: "region"},
+	}
+	if found := readTar(t, &tarred); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v, got %v", expected, found)
+	}
+}
+
+func TestZipSlip(t *testing.T) {
+	for _, name := range []string{"../../etc/cron.d/evil", "/etc/cron.d/evil", "world/../../evil"} {
+		t.Run(name, func(t *testing.T) {
+			archive := buildZip(t, []tarFile{{name: "level.dat", content: "level"}, {name: name, content: "evil"}})
+			if err := ZipToTar(archive, "", ioutil.Discard); err == nil {
+				t.Error("expected ZipToTar to reject the path")
+			}
+
+			tarred := buildTar(t, []tarFile{{name: "level.dat", content: "level"}, {name: name, content: "evil"}})
+			if err := TarToZip(tarred, ioutil.Discard); err == nil {
+				t.Error("expected TarToZip to reject the path")
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package webui
+
+import (
+	"context"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"net/http"
+	"strings"
+)
+
+// errForbidden is returned when the user may not do the request
+var errForbidden = errors.New("forbidden")
+
+// user returns the user and groups of the request, as set by the authenticating proxy in front of the web UI
+func (a *Api) user(r *http.Request) (string, []string) {
+	user := r.Header.Get(a.UserHeader)
+	var groups []string
+	if a.GroupsHeader != "" {
+		for _, value := range r.Header.Values(a.GroupsHeader) {
+			for _, group := range strings.Split(value, ",") {
+				if group = strings.TrimSpace(group); group != "" {
+					groups = append(groups, group)
+				}
+			}
+		}
+	}
+	return user, groups
+}
+
+// allowed tells if the user of the request may do the verb on the resource in the namespace, by asking the API
+// server with a SubjectAccessReview. Without a UserHeader, there are no users and everything is allowed.
+func (a *Api) allowed(r *http.Request, verb, resource, namespace string) (bool, error) {
+	if a.UserHeader == "" {
+		return true, nil
+	}
+	user, groups := a.user(r)
+	if user == "" {
+		return false, nil
+	}
+
+	review := authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user,
+			Groups: groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Group:     v1.GroupVersion.Group,
+				Resource:  resource,
+			},
+		},
+	}
+	if err := a.Client.Create(context.Background(), &review); err != nil {
+		return false, errors.Wrap(err, "reviewing access")
+	}
+	return review.Status.Allowed, nil
+}
+
+// checkAllowed returns errForbidden when the user of the request may not do the verb on the resource in the namespace
+func (a *Api) checkAllowed(r *http.Request, verb, resource, namespace string) error {
+	allowed, err := a.allowed(r, verb, resource, namespace)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		user, _ := a.user(r)
+		a.Log.Info("denied request", "user", user, "verb", verb, "resource", resource, "namespace", namespace)
+		return errors.Wrapf(errForbidden, "%s %s in namespace %s", verb, resource, namespace)
+	}
+	return nil
+}
+
+// namespaceFilter remembers per namespace if the user of the request may list the resource there,
+// to only show what the user may see
+type namespaceFilter struct {
+	api      *Api
+	request  *http.Request
+	resource string
+	allowed  map[string]bool
+}
+
+// newNamespaceFilter returns a filter for listing the resource for the user of the request
+func (a *Api) newNamespaceFilter(r *http.Request, resource string) *namespaceFilter {
+	return &namespaceFilter{api: a, request: r, resource: resource, allowed: make(map[string]bool)}
+}
+
+// Allowed tells if the user may list the resource in the namespace
+func (f *namespaceFilter) Allowed(namespace string) (bool, error) {
+	if allowed, ok := f.allowed[namespace]; ok {
+		return allowed, nil
+	}
+	allowed, err := f.api.allowed(f.request, "list", f.resource, namespace)
+	if err != nil {
+		return false, err
+	}
+	f.allowed[namespace] = allowed
+	return allowed, nil
+}
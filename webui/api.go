@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/go-logr/logr"
 	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
 	"github.com/pkg/errors"
 	"io"
 	corev1 "k8s.io/api/core/v1"
@@ -20,14 +21,20 @@ import (
 	"net/http"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strconv"
+	"time"
 )
 
 type Api struct {
 	Client client.Client
 	Log    logr.Logger
+
+	// UserHeader and GroupsHeader are the headers with the user and groups, set by the authenticating proxy
+	// in front of the web UI. When set, users only see and change the Servers that their RBAC permits.
+	UserHeader   string
+	GroupsHeader string
 }
 
-// getServers gets the manifests for all Servers
+// getServers gets the manifests for all Servers the user may list
 func (a *Api) getServers(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var servers v1.ServerList
@@ -39,8 +46,22 @@ func (a *Api) getServers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	filter := a.newNamespaceFilter(r, "servers")
+	visible := make([]v1.Server, 0, len(servers.Items))
+	for _, server := range servers.Items {
+		allowed, err := filter.Allowed(server.Namespace)
+		if err != nil {
+			a.Log.Info("ERROR failed to check access to Servers", "error", err)
+			returnError(err, w)
+			return
+		}
+		if allowed {
+			visible = append(visible, server)
+		}
+	}
+
 	//a.Log.Info("got servers", "servers", servers.Items)
-	err = json.NewEncoder(w).Encode(servers.Items)
+	err = json.NewEncoder(w).Encode(visible)
 	if err != nil {
 		a.Log.Info("ERROR failed to serialize Servers", "error", err)
 		returnError(errors.Wrap(err, "failed to serialize Servers"), w)
@@ -68,7 +89,7 @@ func (a *Api) setServer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	server, err := a.getServerObject(r)
+	server, err := a.getServerObject(r, "update")
 	if err != nil {
 		a.Log.Info("ERROR", "error", err)
 		returnError(err, w)
@@ -77,6 +98,22 @@ func (a *Api) setServer(w http.ResponseWriter, r *http.Request) {
 
 	a.Log.Info("Got request to set server state", "server", server.Name, "enabled", enabled)
 
+	if enabled {
+		allowed, err := helpers.ScheduleAllowsRunning(helpers.EffectiveServer(server), time.Now())
+		if err != nil {
+			err := errors.Wrap(err, "checking schedule")
+			a.Log.Info("ERROR", "error", err)
+			returnError(err, w)
+			return
+		}
+		if !allowed {
+			err := errors.New("server is outside of its schedule")
+			a.Log.Info("ERROR", "error", err)
+			returnError(err, w)
+			return
+		}
+	}
+
 	server.Spec.Enabled = enabled
 	a.Log.Info("storing server manifest")
 	err = a.Client.Update(context.Background(), server)
@@ -93,7 +130,7 @@ func (a *Api) setServer(w http.ResponseWriter, r *http.Request) {
 
 func (a *Api) postServerCommand(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	server, err := a.getServerObject(r)
+	server, err := a.getServerObject(r, "update")
 	if err != nil {
 		a.Log.Info("ERROR", "error", err)
 		returnError(err, w)
@@ -167,7 +204,7 @@ func (a *Api) postServerCommand(w http.ResponseWriter, r *http.Request) {
 
 func (a *Api) getServerLogs(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	server, err := a.getServerObject(r)
+	server, err := a.getServerObject(r, "get")
 	if err != nil {
 		a.Log.Info("ERROR", "error", err)
 		returnError(err, w)
@@ -256,7 +293,7 @@ func (a *Api) getApiClient() (*kubernetes.Clientset, error){
 	return clientSet, nil
 }
 
-func (a *Api) getServerObject(r *http.Request) (*v1.Server, error) {
+func (a *Api) getServerObject(r *http.Request, verb string) (*v1.Server, error) {
 	serverName, ok := r.URL.Query()["server"]
 	if !ok || len(serverName[0]) < 1 {
 		err := errors.New("missing server parameter")
@@ -271,6 +308,10 @@ func (a *Api) getServerObject(r *http.Request) (*v1.Server, error) {
 		return nil, err
 	}
 
+	if err := a.checkAllowed(r, verb, "servers", nameSpace[0]); err != nil {
+		return nil, err
+	}
+
 	var server v1.Server
 	err := a.Client.Get(context.Background(), types.NamespacedName{
 		Name:      serverName[0],
@@ -0,0 +1,47 @@
+package webui
+
+import (
+	"context"
+	"github.com/hsmade/minecraft-operator/controllers"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getServerCrash gets the log or the crash report of the last crash of a Server, as text
+func (a *Api) getServerCrash(w http.ResponseWriter, r *http.Request) {
+	server, err := a.getServerObject(r, "get")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	key := r.URL.Query().Get("file")
+	if key != controllers.CrashLogKey && key != controllers.CrashReportKey {
+		err := errors.Errorf("file should be %s or %s", controllers.CrashLogKey, controllers.CrashReportKey)
+		a.Log.Info("ERROR parsing parameters", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	var configMap corev1.ConfigMap
+	err = a.Client.Get(context.Background(), client.ObjectKey{Name: controllers.CrashConfigMapName(server), Namespace: server.Namespace}, &configMap)
+	if err != nil {
+		err := errors.Wrap(err, "getting crash configMap")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	content, ok := configMap.Data[key]
+	if !ok || content == "" {
+		err := errors.Errorf("the last crash has no %s (yet)", key)
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(content))
+}
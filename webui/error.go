@@ -2,12 +2,17 @@ package webui
 
 import (
 	"encoding/json"
+	"github.com/pkg/errors"
 	"net/http"
 )
 
 func returnError(err error, w http.ResponseWriter) {
-	w.WriteHeader(500)
+	if errors.Is(err, errForbidden) {
+		w.WriteHeader(http.StatusForbidden)
+	} else {
+		w.WriteHeader(500)
+	}
 	json.NewEncoder(w).Encode(struct {
-		Error error
-	}{err})
+		Error string `json:"error"`
+	}{err.Error()})
 }
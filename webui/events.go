@@ -0,0 +1,86 @@
+package webui
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// serverEvent is an Event of a Server, as shown in the timeline
+type serverEvent struct {
+	Type    string    `json:"type"`
+	Reason  string    `json:"reason"`
+	Message string    `json:"message"`
+	Count   int32     `json:"count"`
+	First   time.Time `json:"first"`
+	Last    time.Time `json:"last"`
+}
+
+// getServerEvents gets the Events of a Server, newest first
+func (a *Api) getServerEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	server, err := a.getServerObject(r, "get")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	// the Events are listed from the API, as caching all Events of the cluster isn't worth it for this
+	clientSet, err := a.getApiClient()
+	if err != nil {
+		err := errors.Wrap(err, "creating k8s api client")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	events, err := clientSet.CoreV1().Events(server.Namespace).List(context.Background(), metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Server,involvedObject.uid=%s", server.UID),
+	})
+	if err != nil {
+		err := errors.Wrap(err, "listing events")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	timeline := make([]serverEvent, 0, len(events.Items))
+	for _, event := range events.Items {
+		timeline = append(timeline, serverEvent{
+			Type:    event.Type,
+			Reason:  event.Reason,
+			Message: event.Message,
+			Count:   event.Count,
+			First:   eventTime(event, event.FirstTimestamp),
+			Last:    eventTime(event, event.LastTimestamp),
+		})
+	}
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Last.After(timeline[j].Last)
+	})
+
+	err = json.NewEncoder(w).Encode(timeline)
+	if err != nil {
+		a.Log.Info("ERROR failed to serialize events", "error", err)
+		returnError(errors.Wrap(err, "failed to serialize events"), w)
+		return
+	}
+}
+
+// eventTime returns the timestamp, or the time of the Event when the timestamp isn't set
+func eventTime(event corev1.Event, timestamp metav1.Time) time.Time {
+	switch {
+	case !timestamp.IsZero():
+		return timestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	}
+	return event.CreationTimestamp.Time
+}
@@ -0,0 +1,45 @@
+package webui
+
+import (
+	"encoding/json"
+	"github.com/hsmade/minecraft-operator/logparse"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// getServerLogEvents gets the events in the logs of the Server, like players joining, deaths and lag
+func (a *Api) getServerLogEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	server, err := a.getServerObject(r, "get")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	logs, err := a.getLogsForServer(server)
+	if err != nil {
+		err := errors.Wrap(err, "getting pod logs")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	events := []logparse.Event{}
+	err = logparse.Parse(logs, func(event logparse.Event) {
+		events = append(events, event)
+	})
+	if err != nil {
+		err := errors.Wrap(err, "parsing pod logs")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(events)
+	if err != nil {
+		a.Log.Info("ERROR failed to serialize log events", "error", err)
+		returnError(errors.Wrap(err, "failed to serialize log events"), w)
+		return
+	}
+}
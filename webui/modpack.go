@@ -0,0 +1,133 @@
+package webui
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"github.com/hsmade/minecraft-operator/controllers"
+	"github.com/hsmade/minecraft-operator/modpack"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// maxModpackUploadSize limits the size of an uploaded modpack, the mods are downloaded separately
+const maxModpackUploadSize = 512 << 20
+
+// postModpackImport creates a new Server from an uploaded Modrinth .mrpack or CurseForge modpack zip
+func (a *Api) postModpackImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		returnError(errors.New("modpack import needs a POST"), w)
+		return
+	}
+
+	nameSpace, ok := r.URL.Query()["namespace"]
+	if !ok || len(nameSpace[0]) < 1 {
+		err := errors.New("missing namespace parameter")
+		a.Log.Info("ERROR parsing parameters", "error", err)
+		returnError(err, w)
+		return
+	}
+	options := modpack.Options{Namespace: nameSpace[0], MemoryMB: 4096}
+	if name, ok := r.URL.Query()["server"]; ok {
+		options.Name = name[0]
+	}
+	if memoryString, ok := r.URL.Query()["memory"]; ok && len(memoryString[0]) > 0 {
+		memory, err := strconv.ParseInt(memoryString[0], 10, 32)
+		if err != nil {
+			err := errors.Wrap(err, "parsing memory parameter")
+			a.Log.Info("ERROR parsing parameters", "error", err)
+			returnError(err, w)
+			return
+		}
+		options.MemoryMB = int32(memory)
+	}
+	a.Log.Info("Got request to import modpack", "server", options.Name, "namespace", options.Namespace)
+
+	if err := a.checkAllowed(r, "create", "servers", options.Namespace); err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	config := controllers.ConfigFor(options.Namespace)
+	if config.ModJarsPVC == nil {
+		err := errors.New("Operator config isn't initialised (yet)")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	upload, _, err := r.FormFile("modpack")
+	if err != nil {
+		err := errors.Wrap(err, "reading uploaded modpack")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	defer upload.Close()
+
+	// zip needs random access, so park the upload on disk
+	tmp, err := ioutil.TempFile("", "modpack-*.zip")
+	if err != nil {
+		err := errors.Wrap(err, "creating temporary file")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.LimitReader(upload, maxModpackUploadSize+1))
+	if err != nil {
+		err := errors.Wrap(err, "storing uploaded modpack")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	if size > maxModpackUploadSize {
+		err := errors.New("uploaded modpack is too large")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	archive, err := zip.NewReader(tmp, size)
+	if err != nil {
+		err := errors.Wrap(err, "opening uploaded modpack as zip")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	t, err := a.getTransfer(options.Namespace)
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	importer := &modpack.Importer{
+		Downloader: &modpack.Downloader{Mirror: os.Getenv("MODPACK_MIRROR")},
+		CurseForge: &modpack.CurseForge{URL: os.Getenv("CURSEFORGE_URL"), APIKey: os.Getenv("CURSEFORGE_API_KEY")},
+		Log:        a.Log.WithName("modpack"),
+	}
+	server, pack, err := importer.Import(context.Background(), a.Client, t, config.ModJarsPVC, archive, options)
+	if err != nil {
+		err := errors.Wrap(err, "importing modpack")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"server":  server,
+		"skipped": pack.Skipped,
+	})
+}
@@ -0,0 +1,106 @@
+package webui
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/hsmade/minecraft-operator/controllers"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/pkg/errors"
+	"net/http"
+	"time"
+)
+
+// recentSessions is the number of sessions the playtime returns, newest first
+const recentSessions = 50
+
+// dayPlaytime is the playtime per player on a day, in seconds
+type dayPlaytime struct {
+	Date     string           `json:"date"`
+	Playtime map[string]int64 `json:"playtime"`
+}
+
+// playtime is the playtime of the players on a Server
+type playtime struct {
+	Timezone string `json:"timezone"`
+	// Days are the playtime of today and the 6 days before, today first
+	Days []dayPlaytime `json:"days"`
+	// Week is the playtime since Monday
+	Week map[string]int64 `json:"week"`
+	// Limits are the daily limits of the players that played this week, in seconds
+	Limits   map[string]int64  `json:"limits,omitempty"`
+	Sessions []helpers.Session `json:"sessions"`
+}
+
+// getServerPlaytime gets the daily and weekly playtime of the players on a Server, with their last sessions
+func (a *Api) getServerPlaytime(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	server, err := a.getServerObject(r, "get")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	server = helpers.EffectiveServer(server)
+
+	sessions, err := controllers.ReadSessions(context.Background(), a.Client, server)
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	location, err := controllers.PlaytimeLocation(server)
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	now := time.Now().In(location)
+	today := helpers.StartOfDay(now)
+	result := playtime{
+		Timezone: location.String(),
+		Week:     seconds(helpers.Playtime(sessions, helpers.StartOfWeek(now), now)),
+		Limits:   make(map[string]int64),
+	}
+	for day := 0; day < 7; day++ {
+		from := today.AddDate(0, 0, -day)
+		to := now
+		if day > 0 {
+			to = from.AddDate(0, 0, 1)
+		}
+		result.Days = append(result.Days, dayPlaytime{
+			Date:     from.Format("2006-01-02"),
+			Playtime: seconds(helpers.Playtime(sessions, from, to)),
+		})
+	}
+	for player := range result.Week {
+		if limit := controllers.DailyPlaytimeLimit(server, player); limit > 0 {
+			result.Limits[player] = int64(limit.Seconds())
+		}
+	}
+
+	helpers.SortSessions(sessions)
+	if len(sessions) > recentSessions {
+		sessions = sessions[:recentSessions]
+	}
+	result.Sessions = sessions
+	if result.Sessions == nil {
+		result.Sessions = []helpers.Session{}
+	}
+
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		a.Log.Info("ERROR failed to serialize playtime", "error", err)
+		returnError(errors.Wrap(err, "failed to serialize playtime"), w)
+		return
+	}
+}
+
+// seconds converts the playtime to seconds
+func seconds(playtime map[string]time.Duration) map[string]int64 {
+	result := make(map[string]int64, len(playtime))
+	for player, duration := range playtime {
+		result[player] = int64(duration.Seconds())
+	}
+	return result
+}
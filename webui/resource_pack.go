@@ -0,0 +1,52 @@
+package webui
+
+import (
+	"context"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"k8s.io/apimachinery/pkg/types"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// getResourcePack serves the resource pack of a Server on /resourcepacks/<namespace>/<server>.zip, for the players' clients
+func (a *Api) getResourcePack(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/resourcepacks/"), "/")
+	if len(parts) != 2 || !strings.HasSuffix(parts[1], ".zip") {
+		http.NotFound(w, r)
+		return
+	}
+
+	var server v1.Server
+	err := a.Client.Get(context.Background(), types.NamespacedName{
+		Name:      strings.TrimSuffix(parts[1], ".zip"),
+		Namespace: parts[0],
+	}, &server)
+	if err != nil {
+		a.Log.Info("ERROR failed to get Server for resource pack", "path", r.URL.Path, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+	if server.Status.ResourcePackSHA1 == "" {
+		// only packs the operator offers are served
+		http.NotFound(w, r)
+		return
+	}
+
+	// the pack can come from the ServerTemplate
+	effective := helpers.EffectiveServer(&server)
+	file, _, err := controllers.OpenResourcePack(context.Background(), a.Client, effective)
+	if err != nil {
+		a.Log.Info("ERROR failed to open resource pack", "server", server.Name, "error", err)
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	a.Log.Info("Serving resource pack", "server", server.Name, "namespace", server.Namespace)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("ETag", `"`+server.Status.ResourcePackSHA1+`"`)
+	http.ServeContent(w, r, server.Name+".zip", time.Time{}, file)
+}
@@ -12,16 +12,31 @@ import (
 //go:embed assets
 var webroot embed.FS
 
-func Run(addr string, kClient client.Client, Log logr.Logger) error {
+// Run serves the web UI. The userHeader and groupsHeader are the headers with the user of the request, set by the
+// authenticating proxy in front of the web UI, leave them empty to allow everyone everything.
+func Run(addr string, kClient client.Client, Log logr.Logger, userHeader, groupsHeader string) error {
 	sub, err := fs.Sub(webroot, "assets")
 	if err != nil {
 		return errors.Wrap(err, "getting FS to assets/")
 	}
 
-	api := Api{Client: kClient, Log: Log.WithName("api")}
+	api := Api{Client: kClient, Log: Log.WithName("api"), UserHeader: userHeader, GroupsHeader: groupsHeader}
 	http.HandleFunc("/api/server/logs", api.getServerLogs)
+	http.HandleFunc("/api/server/logs/events", api.getServerLogEvents)
+	http.HandleFunc("/api/server/crash", api.getServerCrash)
 	http.HandleFunc("/api/server/command", api.postServerCommand)
+	http.HandleFunc("/api/server/events", api.getServerEvents)
+	http.HandleFunc("/api/server/playtime", api.getServerPlaytime)
+	http.HandleFunc("/api/server/rollback", api.setRollback)
+	http.HandleFunc("/api/server/world/export", api.getWorldExport)
+	http.HandleFunc("/api/server/world/import", api.postWorldImport)
+	http.HandleFunc("/api/server/world/regenerate", api.setWorldRegenerate)
+	http.HandleFunc("/api/server/world/activate", api.setActiveWorld)
+	http.HandleFunc("/api/modpack/import", api.postModpackImport)
+	http.HandleFunc("/resourcepacks/", api.getResourcePack)
+	http.HandleFunc("/api/server/create", api.createServer)
 	http.HandleFunc("/api/server", api.setServer)
+	http.HandleFunc("/api/templates", api.getTemplates)
 	http.HandleFunc("/api/servers", api.getServers)
 	http.Handle("/", http.FileServer(http.FS(sub)))
 	return http.ListenAndServe(addr, nil)
@@ -0,0 +1,101 @@
+package webui
+
+import (
+	"context"
+	"encoding/json"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"net/http"
+)
+
+// getTemplates gets the ServerTemplates the user may list, to add Servers from
+func (a *Api) getTemplates(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var templates v1.ServerTemplateList
+	err := a.Client.List(context.Background(), &templates)
+	if err != nil {
+		err = errors.Wrap(err, "failed to get ServerTemplates")
+		a.Log.Info("ERROR failed to get ServerTemplates", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	filter := a.newNamespaceFilter(r, "servertemplates")
+	visible := make([]v1.ServerTemplate, 0, len(templates.Items))
+	for _, template := range templates.Items {
+		allowed, err := filter.Allowed(template.Namespace)
+		if err != nil {
+			a.Log.Info("ERROR failed to check access to ServerTemplates", "error", err)
+			returnError(err, w)
+			return
+		}
+		if allowed {
+			visible = append(visible, template)
+		}
+	}
+
+	err = json.NewEncoder(w).Encode(visible)
+	if err != nil {
+		a.Log.Info("ERROR failed to serialize ServerTemplates", "error", err)
+		returnError(errors.Wrap(err, "failed to serialize ServerTemplates"), w)
+		return
+	}
+}
+
+// createServer adds a disabled Server that takes its spec from a ServerTemplate in its namespace
+func (a *Api) createServer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	parameters := make(map[string]string)
+	for _, name := range []string{"server", "namespace", "template"} {
+		value, ok := r.URL.Query()[name]
+		if !ok || len(value[0]) < 1 {
+			err := errors.Errorf("missing %s parameter", name)
+			a.Log.Info("ERROR parsing parameters", "error", err)
+			returnError(err, w)
+			return
+		}
+		parameters[name] = value[0]
+	}
+
+	a.Log.Info("Got request to create server", "server", parameters["server"], "namespace", parameters["namespace"],
+		"template", parameters["template"])
+
+	if err := a.checkAllowed(r, "create", "servers", parameters["namespace"]); err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	var template v1.ServerTemplate
+	err := a.Client.Get(context.Background(), types.NamespacedName{
+		Name:      parameters["template"],
+		Namespace: parameters["namespace"],
+	}, &template)
+	if err != nil {
+		err := errors.Wrap(err, "retrieving ServerTemplate")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	server := v1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: parameters["server"], Namespace: parameters["namespace"]},
+		Spec:       v1.ServerSpec{TemplateRef: template.Name},
+	}
+	if motd, ok := r.URL.Query()["motd"]; ok && len(motd[0]) > 0 {
+		server.Spec.Properties = map[string]string{"motd": motd[0]}
+	}
+	err = a.Client.Create(context.Background(), &server)
+	if err != nil {
+		err := errors.Wrap(err, "creating server")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(server)
+}
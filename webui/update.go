@@ -0,0 +1,50 @@
+package webui
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+// setRollback rolls the Server back to the server version and world of a snapshot from its version history
+func (a *Api) setRollback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, err := a.getServerObject(r, "update")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	snapshot := r.URL.Query().Get("snapshot")
+	a.Log.Info("Got request to roll back", "server", server.Name, "snapshot", snapshot)
+
+	version := ""
+	for _, change := range server.Status.VersionHistory {
+		if snapshot != "" && change.Snapshot == snapshot {
+			version = change.From
+		}
+	}
+	if version == "" {
+		err := errors.Errorf("snapshot %q not found in the version history", snapshot)
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	server.Spec.ServerVersion = version
+	server.Spec.Rollback = snapshot
+
+	a.Log.Info("storing server manifest")
+	err = a.Client.Update(context.Background(), server)
+	if err != nil {
+		err := errors.Wrap(err, "storing server manifest")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(nil)
+}
@@ -0,0 +1,239 @@
+package webui
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	v1 "github.com/hsmade/minecraft-operator/api/v1"
+	"github.com/hsmade/minecraft-operator/controllers"
+	"github.com/hsmade/minecraft-operator/controllers/helpers"
+	"github.com/hsmade/minecraft-operator/transfer"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"k8s.io/client-go/rest"
+	"net/http"
+	"os"
+	"time"
+)
+
+// maxWorldUploadSize limits the size of an uploaded world archive
+const maxWorldUploadSize = 4 << 30
+
+// getWorldName returns the world from the request, defaulting to the active world of the Server
+func getWorldName(r *http.Request, server *v1.Server) string {
+	worldName, ok := r.URL.Query()["world"]
+	if !ok || len(worldName[0]) < 1 {
+		return helpers.RunningWorldName(server)
+	}
+	return worldName[0]
+}
+
+// getTransfer returns a Transfer with the helper image of the OperatorConfig for the namespace
+func (a *Api) getTransfer(namespace string) (*transfer.Transfer, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "get cluster config")
+	}
+
+	return &transfer.Transfer{
+		Client:      a.Client,
+		Config:      config,
+		Log:         a.Log.WithName("transfer"),
+		HelperImage: controllers.ConfigFor(namespace).InitContainerImage,
+	}, nil
+}
+
+// getWorldExport streams a zip of the Server's world
+func (a *Api) getWorldExport(w http.ResponseWriter, r *http.Request) {
+	server, err := a.getServerObject(r, "get")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	// the worlds can come from the template
+	server = helpers.EffectiveServer(server)
+
+	world := getWorldName(r, server)
+	a.Log.Info("Got request to export world", "server", server.Name, "world", world)
+
+	t, err := a.getTransfer(server.Namespace)
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", server.Name+"-"+world+".zip"))
+	err = t.ExportWorld(context.Background(), server, world, w)
+	if err != nil {
+		// the headers are already out, so all we can do is log and cut the download short
+		a.Log.Info("ERROR failed to export world", "server", server.Name, "error", err)
+		return
+	}
+}
+
+// postWorldImport replaces or seeds the Server's world from an uploaded zip
+func (a *Api) postWorldImport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		returnError(errors.New("world import needs a POST"), w)
+		return
+	}
+
+	server, err := a.getServerObject(r, "update")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	// the worlds can come from the template
+	server = helpers.EffectiveServer(server)
+
+	mode := transfer.ImportSeed
+	if modeString, ok := r.URL.Query()["mode"]; ok && len(modeString[0]) > 0 {
+		mode = transfer.ImportMode(modeString[0])
+	}
+
+	world := getWorldName(r, server)
+	a.Log.Info("Got request to import world", "server", server.Name, "world", world, "mode", mode)
+
+	upload, _, err := r.FormFile("world")
+	if err != nil {
+		err := errors.Wrap(err, "reading uploaded world")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	defer upload.Close()
+
+	// zip needs random access, so park the upload on disk
+	tmp, err := ioutil.TempFile("", "world-*.zip")
+	if err != nil {
+		err := errors.Wrap(err, "creating temporary file")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	size, err := io.Copy(tmp, io.LimitReader(upload, maxWorldUploadSize+1))
+	if err != nil {
+		err := errors.Wrap(err, "storing uploaded world")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	if size > maxWorldUploadSize {
+		err := errors.New("uploaded world is too large")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	archive, err := zip.NewReader(tmp, size)
+	if err != nil {
+		err := errors.Wrap(err, "opening uploaded world as zip")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	t, err := a.getTransfer(server.Namespace)
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	err = t.ImportWorld(context.Background(), server, world, archive, mode)
+	if err != nil {
+		err := errors.Wrap(err, "importing world")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(nil)
+}
+
+// setWorldRegenerate requests a new world for the Server, the current one gets archived on the next start
+func (a *Api) setWorldRegenerate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, err := a.getServerObject(r, "update")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	world := getWorldName(r, server)
+	a.Log.Info("Got request to regenerate world", "server", server.Name, "world", world)
+
+	if world == helpers.DefaultWorldName && server.Spec.World == nil {
+		server.Spec.World = &v1.WorldSpec{}
+	}
+	spec, err := helpers.WorldSpec(server, world)
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	spec.Regenerate = time.Now().UTC().Format("20060102-150405")
+
+	a.Log.Info("storing server manifest")
+	err = a.Client.Update(context.Background(), server)
+	if err != nil {
+		err := errors.Wrap(err, "storing server manifest")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(nil)
+}
+
+// setActiveWorld switches the Server to another world, which restarts it
+func (a *Api) setActiveWorld(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	server, err := a.getServerObject(r, "update")
+	if err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	world := getWorldName(r, server)
+	a.Log.Info("Got request to activate world", "server", server.Name, "world", world)
+
+	if _, err := helpers.WorldSpec(server, world); err != nil {
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+	server.Spec.ActiveWorld = world
+	if world == helpers.DefaultWorldName {
+		server.Spec.ActiveWorld = ""
+	}
+
+	a.Log.Info("storing server manifest")
+	err = a.Client.Update(context.Background(), server)
+	if err != nil {
+		err := errors.Wrap(err, "storing server manifest")
+		a.Log.Info("ERROR", "error", err)
+		returnError(err, w)
+		return
+	}
+
+	w.WriteHeader(200)
+	json.NewEncoder(w).Encode(nil)
+}